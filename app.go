@@ -5,9 +5,13 @@ import (
 	"errors"
 	"os"
 	"sync"
+	"time"
 
+	"Rewinder/internal/events"
 	"Rewinder/internal/ipcapi"
+	"Rewinder/internal/policy"
 	"Rewinder/internal/services"
+	"Rewinder/internal/snapshot"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
@@ -28,7 +32,7 @@ func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
 
 	a.start.Do(func() {
-		a.svc = services.New(services.Dependencies{
+		svc, err := services.New(services.Dependencies{
 			EmitEvent: func(name string, data any) {
 				runtime.EventsEmit(ctx, name, data)
 				if name == "onExitRequested" {
@@ -46,6 +50,20 @@ func (a *App) startup(ctx context.Context) {
 				a.ShowTimelineWindow()
 			},
 		})
+		if err != nil {
+			msg := "Rewinder could not start: " + err.Error()
+			if errors.Is(err, snapshot.ErrStoreLocked) {
+				msg = "Rewinder is already running: " + err.Error()
+			}
+			_, _ = runtime.MessageDialog(ctx, runtime.MessageDialogOptions{
+				Type:    runtime.ErrorDialog,
+				Title:   "Rewinder",
+				Message: msg,
+			})
+			runtime.Quit(ctx)
+			return
+		}
+		a.svc = svc
 		a.svc.Start(ctx)
 	})
 }
@@ -125,6 +143,55 @@ func (a *App) GetTimeline(appID string) ([]ipcapi.SnapshotMeta, error) {
 	return timeline, nil
 }
 
+func (a *App) GetBranches(appID string) ([]ipcapi.BranchInfo, error) {
+	if a.svc == nil {
+		return nil, errors.New("backend not ready")
+	}
+	return a.svc.GetBranches(appID), nil
+}
+
+func (a *App) ExplainTracking(appID string) (ipcapi.TrackingExplanation, error) {
+	if a.svc == nil {
+		return ipcapi.TrackingExplanation{}, errors.New("backend not ready")
+	}
+	return a.svc.ExplainTracking(appID), nil
+}
+
+func (a *App) Search(query string, filter ipcapi.SearchFilter) ([]ipcapi.SearchHit, error) {
+	if a.svc == nil {
+		return nil, errors.New("backend not ready")
+	}
+	return a.svc.Search(query, filter), nil
+}
+
+func (a *App) GetStorageStats() (ipcapi.StorageStats, error) {
+	if a.svc == nil {
+		return ipcapi.StorageStats{}, errors.New("backend not ready")
+	}
+	return a.svc.GetStorageStats(), nil
+}
+
+func (a *App) GetEventStats() (events.BusStats, error) {
+	if a.svc == nil {
+		return events.BusStats{}, errors.New("backend not ready")
+	}
+	return a.svc.GetEventStats(), nil
+}
+
+func (a *App) ForgetApp(appID string) error {
+	if a.svc == nil {
+		return errors.New("backend not ready")
+	}
+	return a.svc.ForgetApp(appID)
+}
+
+func (a *App) PurgeHistory(appID string, fromUTC int64, toUTC int64) (int, error) {
+	if a.svc == nil {
+		return 0, errors.New("backend not ready")
+	}
+	return a.svc.PurgeHistory(appID, time.UnixMilli(fromUTC), time.UnixMilli(toUTC))
+}
+
 func (a *App) Restore(appID string, snapshotID string) error {
 	if a.svc == nil {
 		return errors.New("backend not ready")
@@ -140,6 +207,15 @@ func (a *App) PauseTracking(appID *string) error {
 	return nil
 }
 
+// PauseTrackingFor pauses for durationMs milliseconds, then resumes on its own.
+func (a *App) PauseTrackingFor(appID *string, durationMs int64) error {
+	if a.svc == nil {
+		return errors.New("backend not ready")
+	}
+	a.svc.PauseTrackingFor(appID, time.Duration(durationMs)*time.Millisecond)
+	return nil
+}
+
 func (a *App) ResumeTracking(appID *string) error {
 	if a.svc == nil {
 		return errors.New("backend not ready")
@@ -173,6 +249,52 @@ func (a *App) ExitApp() {
 	os.Exit(0)
 }
 
+func (a *App) GetConfig() (policy.Config, error) {
+	if a.svc == nil {
+		return policy.Config{}, errors.New("backend not ready")
+	}
+	return a.svc.GetConfig(), nil
+}
+
+func (a *App) GetDefaultConfig() policy.Config {
+	return *policy.DefaultConfig()
+}
+
+func (a *App) UpdateConfig(cfg policy.Config) (ipcapi.ConfigUpdateResult, error) {
+	if a.svc == nil {
+		return ipcapi.ConfigUpdateResult{}, errors.New("backend not ready")
+	}
+	return a.svc.UpdateConfig(cfg)
+}
+
+func (a *App) ResetConfig() (ipcapi.ConfigUpdateResult, error) {
+	if a.svc == nil {
+		return ipcapi.ConfigUpdateResult{}, errors.New("backend not ready")
+	}
+	return a.svc.ResetConfig()
+}
+
+// IsReadOnly reports whether another instance owns the snapshot store and
+// this one can only browse it.
+func (a *App) IsReadOnly() bool {
+	return a.svc != nil && a.svc.ReadOnly()
+}
+
+func (a *App) GetProfiles() (ipcapi.ProfilesInfo, error) {
+	if a.svc == nil {
+		return ipcapi.ProfilesInfo{}, errors.New("backend not ready")
+	}
+	return a.svc.GetProfiles(), nil
+}
+
+// SetActiveProfile switches profiles; "" goes back to the base settings.
+func (a *App) SetActiveProfile(name string) (ipcapi.ConfigUpdateResult, error) {
+	if a.svc == nil {
+		return ipcapi.ConfigUpdateResult{}, errors.New("backend not ready")
+	}
+	return a.svc.SetActiveProfile(name)
+}
+
 func (a *App) GetAutostart() bool {
 	return services.IsAutostartEnabled()
 }
@@ -0,0 +1,84 @@
+// Command rewinder-replay runs a session recorded with -record through the
+// tracking and snapshot pipeline and prints every event the app would send
+// to its windows, one JSON line each. It runs on any platform, so a session
+// recorded on Windows can be replayed on Linux.
+//
+//	rewinder-replay [-speed 0] [-home dir] [-config config.json] session.rec
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"Rewinder/internal/policy"
+	"Rewinder/internal/replay"
+	"Rewinder/internal/services"
+)
+
+func main() {
+	os.Exit(run())
+}
+
+// run returns the exit code, so that its deferred cleanup, the temp home
+// among it, happens before the process exits.
+func run() int {
+	speed := flag.Float64("speed", 0, "1 replays in real time, 10 ten times faster, 0 without waiting")
+	home := flag.String("home", "", "directory for the replay's config and snapshot store (default: a new temp dir)")
+	config := flag.String("config", "", "config file to use, e.g. the one of the recorded session")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: rewinder-replay [flags] session.rec")
+		flag.PrintDefaults()
+		return 2
+	}
+
+	rec, err := replay.Open(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+
+	if *home == "" {
+		dir, err := os.MkdirTemp("", "rewinder-replay-")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return 1
+		}
+		defer os.RemoveAll(dir)
+		*home = dir
+	}
+	_ = os.Setenv(policy.EnvHome, *home)
+	if *config != "" {
+		_ = os.Setenv(policy.EnvConfigPath, *config)
+	}
+	_ = os.Setenv(policy.EnvLockMode, policy.LockFail)
+
+	out := json.NewEncoder(os.Stdout)
+	svc, err := services.New(services.Dependencies{
+		EmitEvent: func(name string, data any) {
+			_ = out.Encode(map[string]any{"event": name, "data": data})
+		},
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	defer svc.Stop()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	fmt.Fprintf(os.Stderr, "replaying %d events recorded on %s\n", len(rec.Steps), rec.Header.OS)
+	code := 0
+	if err := svc.Replay(ctx, rec, *speed); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		code = 1
+	}
+	for _, app := range svc.GetApps() {
+		fmt.Fprintf(os.Stderr, "%s: %d snapshots\n", app.AppID, app.SnapshotCount)
+	}
+	return code
+}
@@ -1,8 +1,11 @@
 package events
 
 import (
+	"context"
 	"errors"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type EventType string
@@ -18,7 +21,11 @@ const (
 	EventClipboardChanged  EventType = "clipboard_changed"
 )
 
+// SystemEvent is one OS event. Seq numbers the events a subscription has
+// accepted, starting at 1; a jump means the subscriber lost events to its
+// overflow policy.
 type SystemEvent struct {
+	Seq       uint64         `json:"seq"`
 	Type      EventType      `json:"type"`
 	Timestamp int64          `json:"timestampUTC"`
 	PID       int            `json:"pid"`
@@ -26,46 +33,299 @@ type SystemEvent struct {
 	Metadata  map[string]any `json:"metadata,omitempty"`
 }
 
+// Overflow decides what happens when a subscriber's buffer is full.
+type Overflow int
+
+const (
+	DropNewest Overflow = iota // discard the incoming event
+	DropOldest                 // discard the oldest queued event to make room
+	Block                      // wait up to Timeout, then discard the incoming event
+)
+
+// Filter selects events by type and PID. Empty lists match everything.
+type Filter struct {
+	Types []EventType
+	PIDs  []int
+}
+
+func (f Filter) match(ev SystemEvent) bool {
+	if len(f.Types) > 0 && !containsType(f.Types, ev.Type) {
+		return false
+	}
+	if len(f.PIDs) > 0 && !containsPID(f.PIDs, ev.PID) {
+		return false
+	}
+	return true
+}
+
+func containsType(list []EventType, t EventType) bool {
+	for _, x := range list {
+		if x == t {
+			return true
+		}
+	}
+	return false
+}
+
+func containsPID(list []int, pid int) bool {
+	for _, x := range list {
+		if x == pid {
+			return true
+		}
+	}
+	return false
+}
+
+type SubscribeOptions struct {
+	Name     string
+	Buffer   int // 0 uses the bus default
+	Filter   Filter
+	Overflow Overflow
+	Timeout  time.Duration // for Block; 0 means 100ms
+	Coalesce CoalesceOptions
+}
+
+// SubscriberStats counts what happened to the events a subscription accepted.
+// Delivered counts events put in the buffer; Dropped counts events lost,
+// including queued ones evicted by DropOldest. Coalesced counts events merged
+// into a later one of their burst.
+type SubscriberStats struct {
+	Name      string `json:"name"`
+	Delivered uint64 `json:"delivered"`
+	Dropped   uint64 `json:"dropped"`
+	Coalesced uint64 `json:"coalesced"`
+	Queued    int    `json:"queued"`
+	Capacity  int    `json:"capacity"`
+	LastSeq   uint64 `json:"lastSeq"`
+}
+
+// Subscription receives the matching events of a bus on its own buffer.
+type Subscription struct {
+	bus  *Bus
+	name string
+	opts SubscribeOptions
+	ch   chan SystemEvent
+	co   *coalescer
+
+	// sendMu orders deliveries so Seq increases in the channel; a Block
+	// wait holds only sendMu, so Stats and Close are not held up by it.
+	sendMu sync.Mutex
+	mu     sync.Mutex
+	seq    uint64
+	closed bool
+	quit   chan struct{} // closed by Close, ends a Block wait
+
+	delivered atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+func (s *Subscription) Events() <-chan SystemEvent { return s.ch }
+
+func (s *Subscription) Stats() SubscriberStats {
+	s.mu.Lock()
+	seq := s.seq
+	s.mu.Unlock()
+	return SubscriberStats{
+		Name:      s.name,
+		Delivered: s.delivered.Load(),
+		Dropped:   s.dropped.Load(),
+		Coalesced: s.co.mergedCount(),
+		Queued:    len(s.ch),
+		Capacity:  cap(s.ch),
+		LastSeq:   seq,
+	}
+}
+
+// SetCoalesce changes how bursts are merged. Bursts already pending keep
+// their timers.
+func (s *Subscription) SetCoalesce(opts CoalesceOptions) {
+	s.co.setOptions(opts)
+}
+
+// Close detaches the subscription from the bus and closes its channel.
+// Pending bursts and an event waiting for room under Block are counted as
+// dropped.
+func (s *Subscription) Close() {
+	s.bus.unsubscribe(s)
+	s.dropped.Add(uint64(s.co.close()))
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.quit)
+	s.mu.Unlock()
+	// Once a waiting push has given up nothing sends on ch any more.
+	s.sendMu.Lock()
+	close(s.ch)
+	s.sendMu.Unlock()
+}
+
+func (s *Subscription) deliver(ev SystemEvent) {
+	if !s.opts.Filter.match(ev) || s.co.add(ev) {
+		return
+	}
+	s.push(ev)
+}
+
+// push queues ev according to the overflow policy.
+func (s *Subscription) push(ev SystemEvent) {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.seq++
+	ev.Seq = s.seq
+	s.mu.Unlock()
+
+	select {
+	case s.ch <- ev:
+		s.delivered.Add(1)
+		return
+	default:
+	}
+	switch s.opts.Overflow {
+	case DropOldest:
+		for {
+			select {
+			case s.ch <- ev:
+				s.delivered.Add(1)
+				return
+			default:
+			}
+			select {
+			case <-s.ch:
+				s.dropped.Add(1)
+			default:
+			}
+		}
+	case Block:
+		timeout, stop := after(s.bus.clock, s.opts.Timeout)
+		defer stop()
+		select {
+		case s.ch <- ev:
+			s.delivered.Add(1)
+		case <-timeout:
+			s.dropped.Add(1)
+		case <-s.quit:
+			s.dropped.Add(1)
+		case <-s.bus.ctx.Done():
+			s.dropped.Add(1)
+		}
+	default:
+		s.dropped.Add(1)
+	}
+}
+
 type Bus struct {
-	ch     chan SystemEvent
-	stopCh chan struct{}
-	once   sync.Once
+	buffer int
+	clock  clock
+	ctx    context.Context // done when the bus stops
+	cancel context.CancelFunc
 
-	win *windowsSources
+	mu      sync.RWMutex
+	subs    []*Subscription
+	emitted atomic.Uint64
+
+	srcMu   sync.Mutex
+	sources []*sourceRun
 }
 
+// NewBus creates a bus whose subscriptions get buffer slots by default.
 func NewBus(buffer int) *Bus {
+	return newBus(buffer, systemClock{})
+}
+
+func newBus(buffer int, c clock) *Bus {
+	if buffer <= 0 {
+		buffer = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Bus{
-		ch:     make(chan SystemEvent, buffer),
-		stopCh: make(chan struct{}),
+		buffer: buffer,
+		clock:  c,
+		ctx:    ctx,
+		cancel: cancel,
 	}
 }
 
-func (b *Bus) Events() <-chan SystemEvent { return b.ch }
+func (b *Bus) Subscribe(opts SubscribeOptions) *Subscription {
+	if opts.Buffer <= 0 {
+		opts.Buffer = b.buffer
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 100 * time.Millisecond
+	}
+	s := &Subscription{bus: b, name: opts.Name, opts: opts, ch: make(chan SystemEvent, opts.Buffer), quit: make(chan struct{})}
+	s.co = newCoalescer(b.clock, s.push)
+	s.co.setOptions(opts.Coalesce)
+	b.mu.Lock()
+	b.subs = append(b.subs, s)
+	b.mu.Unlock()
+	return s
+}
 
-func (b *Bus) Emit(ev SystemEvent) {
-	select {
-	case b.ch <- ev:
-	default:
+func (b *Bus) unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, x := range b.subs {
+		if x == s {
+			b.subs = append(b.subs[:i:i], b.subs[i+1:]...)
+			return
+		}
 	}
 }
 
-func (b *Bus) StartWindowsSources() error {
-	if b.win != nil {
-		return nil
+// Emit hands ev to every matching subscriber. It only waits for subscribers
+// with the Block policy, and serves them last so the others are not held up.
+func (b *Bus) Emit(ev SystemEvent) {
+	if ev.Timestamp == 0 {
+		ev.Timestamp = b.clock.Now().UTC().UnixMilli()
+	}
+	b.emitted.Add(1)
+	b.mu.RLock()
+	subs := b.subs
+	b.mu.RUnlock()
+	for _, s := range subs {
+		if s.opts.Overflow != Block {
+			s.deliver(ev)
+		}
 	}
-	ws, err := newWindowsSources(b.Emit, b.stopCh)
-	if err != nil {
-		return err
+	for _, s := range subs {
+		if s.opts.Overflow == Block {
+			s.deliver(ev)
+		}
+	}
+}
+
+// BusStats reports how many events were emitted, what each subscriber made
+// of them and how the sources are doing.
+type BusStats struct {
+	Emitted     uint64            `json:"emitted"`
+	Subscribers []SubscriberStats `json:"subscribers"`
+	Sources     []SourceStatus    `json:"sources"`
+}
+
+func (b *Bus) Stats() BusStats {
+	b.mu.RLock()
+	subs := b.subs
+	b.mu.RUnlock()
+	st := BusStats{Emitted: b.emitted.Load(), Subscribers: make([]SubscriberStats, 0, len(subs))}
+	for _, s := range subs {
+		st.Subscribers = append(st.Subscribers, s.Stats())
 	}
-	b.win = ws
-	return ws.start()
+	st.Sources = b.Sources()
+	return st
 }
 
+// Stop stops the sources without waiting for them and releases subscribers
+// blocked in Emit.
 func (b *Bus) Stop() {
-	b.once.Do(func() {
-		close(b.stopCh)
-	})
+	b.cancel()
 }
 
 var ErrNotSupported = errors.New("not supported")
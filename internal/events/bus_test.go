@@ -0,0 +1,282 @@
+package events
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock only moves on Advance, which runs the timers that come due in
+// the calling goroutine.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	c      *fakeClock
+	d      time.Duration
+	at     time.Time
+	f      func()
+	active bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{c: c, d: d, at: c.now.Add(d), f: f, active: true}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	was := t.active
+	t.active = false
+	return was
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	was := t.active
+	t.active, t.d, t.at = true, d, t.c.now.Add(d)
+	return was
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	var due []*fakeTimer
+	for _, t := range c.timers {
+		if t.active && !t.at.After(c.now) {
+			t.active = false
+			due = append(due, t)
+		}
+	}
+	c.mu.Unlock()
+	sort.SliceStable(due, func(i, j int) bool { return due[i].at.Before(due[j].at) })
+	for _, t := range due {
+		t.f()
+	}
+}
+
+// pending lists the durations of the timers that have not fired or been
+// stopped, in the order they were set.
+func (c *fakeClock) pending() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var out []time.Duration
+	for _, t := range c.timers {
+		if t.active {
+			out = append(out, t.d)
+		}
+	}
+	return out
+}
+
+// waitPending waits until n timers are pending, i.e. until the goroutines
+// under test are parked on the clock.
+func waitPending(t *testing.T, c *fakeClock, n int) []time.Duration {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		p := c.pending()
+		if len(p) == n {
+			return p
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d timers pending, want %d", len(p), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func recv(t *testing.T, sub *Subscription) SystemEvent {
+	t.Helper()
+	select {
+	case ev := <-sub.Events():
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event")
+		return SystemEvent{}
+	}
+}
+
+func expectEmpty(t *testing.T, sub *Subscription) {
+	t.Helper()
+	select {
+	case ev := <-sub.Events():
+		t.Fatalf("unexpected event %+v", ev)
+	default:
+	}
+}
+
+func checkStats(t *testing.T, sub *Subscription, delivered, dropped, lastSeq uint64) {
+	t.Helper()
+	st := sub.Stats()
+	if st.Delivered != delivered || st.Dropped != dropped || st.LastSeq != lastSeq {
+		t.Fatalf("%s: delivered %d, dropped %d, last seq %d; want %d, %d, %d",
+			st.Name, st.Delivered, st.Dropped, st.LastSeq, delivered, dropped, lastSeq)
+	}
+}
+
+func emitPIDs(b *Bus, pids ...int) {
+	for _, pid := range pids {
+		b.Emit(SystemEvent{Type: EventProcessStarted, PID: pid})
+	}
+}
+
+func TestOverflowDropNewest(t *testing.T) {
+	b := newBus(8, newFakeClock())
+	defer b.Stop()
+	sub := b.Subscribe(SubscribeOptions{Name: "newest", Buffer: 2, Overflow: DropNewest})
+	emitPIDs(b, 1, 2, 3, 4, 5)
+	checkStats(t, sub, 2, 3, 5)
+	for i, want := range []int{1, 2} {
+		if ev := recv(t, sub); ev.PID != want || ev.Seq != uint64(i+1) {
+			t.Fatalf("got pid %d seq %d, want pid %d seq %d", ev.PID, ev.Seq, want, i+1)
+		}
+	}
+	expectEmpty(t, sub)
+
+	// The dropped events leave a gap in Seq.
+	emitPIDs(b, 6)
+	if ev := recv(t, sub); ev.PID != 6 || ev.Seq != 6 {
+		t.Fatalf("got pid %d seq %d after the gap", ev.PID, ev.Seq)
+	}
+	checkStats(t, sub, 3, 3, 6)
+}
+
+func TestOverflowDropOldest(t *testing.T) {
+	b := newBus(8, newFakeClock())
+	defer b.Stop()
+	sub := b.Subscribe(SubscribeOptions{Name: "oldest", Buffer: 2, Overflow: DropOldest})
+	emitPIDs(b, 1, 2, 3, 4, 5)
+	// Evicted events were delivered to the buffer first, then dropped.
+	checkStats(t, sub, 5, 3, 5)
+	for _, want := range []uint64{4, 5} {
+		if ev := recv(t, sub); ev.Seq != want || ev.PID != int(want) {
+			t.Fatalf("got pid %d seq %d, want %d", ev.PID, ev.Seq, want)
+		}
+	}
+	expectEmpty(t, sub)
+}
+
+func TestOverflowBlock(t *testing.T) {
+	clock := newFakeClock()
+	b := newBus(8, clock)
+	defer b.Stop()
+	slow := b.Subscribe(SubscribeOptions{Name: "slow", Buffer: 1, Overflow: Block, Timeout: 100 * time.Millisecond})
+	fast := b.Subscribe(SubscribeOptions{Name: "fast", Buffer: 8})
+	emitPIDs(b, 1)
+	recv(t, fast)
+
+	emitted := make(chan struct{})
+	emitAsync := func(pid int) {
+		go func() {
+			emitPIDs(b, pid)
+			emitted <- struct{}{}
+		}()
+		if p := waitPending(t, clock, 1); p[0] != 100*time.Millisecond {
+			t.Fatalf("block timeout %v", p[0])
+		}
+	}
+
+	// Waiting for room: the other subscriber already has the event, and
+	// Stats and Unsubscribe of others are not held up.
+	emitAsync(2)
+	if ev := recv(t, fast); ev.PID != 2 {
+		t.Fatalf("fast subscriber got pid %d", ev.PID)
+	}
+	checkStats(t, slow, 1, 0, 2)
+	other := b.Subscribe(SubscribeOptions{Name: "other"})
+	other.Close()
+	// Room is made: the event goes in.
+	if ev := recv(t, slow); ev.PID != 1 {
+		t.Fatalf("got pid %d", ev.PID)
+	}
+	<-emitted
+	checkStats(t, slow, 2, 0, 2)
+
+	// No room within the timeout: dropped.
+	emitAsync(3)
+	clock.Advance(99 * time.Millisecond)
+	select {
+	case <-emitted:
+		t.Fatal("gave up before the timeout")
+	default:
+	}
+	clock.Advance(time.Millisecond)
+	<-emitted
+	checkStats(t, slow, 2, 1, 3)
+
+	// Close ends a wait and the event counts as dropped.
+	emitAsync(4)
+	slow.Close()
+	<-emitted
+	checkStats(t, slow, 2, 2, 4)
+	if ev := recv(t, slow); ev.PID != 2 || ev.Seq != 2 {
+		t.Fatalf("got pid %d seq %d", ev.PID, ev.Seq)
+	}
+	if _, ok := <-slow.Events(); ok {
+		t.Fatal("channel open after Close")
+	}
+}
+
+func TestOverflowBlockBusStop(t *testing.T) {
+	clock := newFakeClock()
+	b := newBus(8, clock)
+	sub := b.Subscribe(SubscribeOptions{Buffer: 1, Overflow: Block})
+	emitPIDs(b, 1)
+	done := make(chan struct{})
+	go func() {
+		emitPIDs(b, 2)
+		close(done)
+	}()
+	waitPending(t, clock, 1)
+	b.Stop()
+	<-done
+	checkStats(t, sub, 1, 1, 2)
+}
+
+func TestSubscriberFilter(t *testing.T) {
+	b := newBus(8, newFakeClock())
+	defer b.Stop()
+	procs := b.Subscribe(SubscribeOptions{Filter: Filter{Types: []EventType{EventProcessStarted, EventProcessExited}}})
+	pid7 := b.Subscribe(SubscribeOptions{Filter: Filter{PIDs: []int{7}}})
+	b.Emit(SystemEvent{Type: EventProcessStarted, PID: 7})
+	b.Emit(SystemEvent{Type: EventWindowMoved, PID: 7})
+	b.Emit(SystemEvent{Type: EventProcessExited, PID: 8})
+
+	if ev := recv(t, procs); ev.PID != 7 || ev.Seq != 1 {
+		t.Fatalf("got %+v", ev)
+	}
+	// Filtered out events take no Seq.
+	if ev := recv(t, procs); ev.PID != 8 || ev.Seq != 2 {
+		t.Fatalf("got %+v", ev)
+	}
+	for _, want := range []EventType{EventProcessStarted, EventWindowMoved} {
+		if ev := recv(t, pid7); ev.Type != want {
+			t.Fatalf("got %+v", ev)
+		}
+	}
+	expectEmpty(t, procs)
+	expectEmpty(t, pid7)
+	if st := b.Stats(); st.Emitted != 3 || len(st.Subscribers) != 2 {
+		t.Fatalf("stats %+v", st)
+	}
+}
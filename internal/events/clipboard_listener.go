@@ -1,12 +1,35 @@
+//go:build windows
+
 package events
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
 	"time"
 	"unsafe"
 
 	"golang.org/x/sys/windows"
 )
 
+// clipboardSource reports clipboard changes.
+type clipboardSource struct{}
+
+func (clipboardSource) Name() string { return "clipboard" }
+
+func (clipboardSource) Start(ctx context.Context, emit func(SystemEvent)) error {
+	// Окно и его цикл сообщений должны жить в одном потоке
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	c, err := newClipboardListener(emit)
+	if err != nil {
+		return err
+	}
+	return c.Run(ctx.Done())
+}
+
 type clipboardListener struct {
 	emit func(SystemEvent)
 
@@ -17,29 +40,61 @@ func newClipboardListener(emit func(SystemEvent)) (*clipboardListener, error) {
 	return &clipboardListener{emit: emit}, nil
 }
 
-func (c *clipboardListener) Run(stopCh <-chan struct{}) {
-	clsName, _ := windows.UTF16PtrFromString("AppTimeMachineClipboardListener")
-	hInstance := getModuleHandle()
+const clipboardClassName = "AppTimeMachineClipboardListener"
+
+// Оконная процедура и класс окна общие для всех запусков: слоты NewCallback
+// не освобождаются, а класс регистрируется один раз на процесс.
+var (
+	clipboardWndProc = sync.OnceValue(func() uintptr { return windows.NewCallback(clipboardWindowProc) })
+	clipboardWindows sync.Map // hwnd -> *clipboardListener
 
-	wndProc := windows.NewCallback(func(hwnd uintptr, msg uint32, wParam, lParam uintptr) uintptr {
-		switch msg {
-		case WM_CLIPBOARDUPDATE:
-			c.emit(SystemEvent{Type: EventClipboardChanged, Timestamp: time.Now().UTC().UnixMilli()})
-			return 0
-		case WM_DESTROY:
-			postQuitMessage(0)
-			return 0
-		default:
-			return defWindowProc(hwnd, msg, wParam, lParam)
+	clipboardClassMu  sync.Mutex
+	clipboardClassReg bool
+)
+
+func clipboardWindowProc(hwnd uintptr, msg uint32, wParam, lParam uintptr) uintptr {
+	switch msg {
+	case WM_CLIPBOARDUPDATE:
+		if c, ok := clipboardWindows.Load(hwnd); ok {
+			c.(*clipboardListener).emit(SystemEvent{Type: EventClipboardChanged, Timestamp: time.Now().UTC().UnixMilli()})
 		}
-	})
+		return 0
+	case WM_DESTROY:
+		postQuitMessage(0)
+		return 0
+	default:
+		return defWindowProc(hwnd, msg, wParam, lParam)
+	}
+}
 
+// registerClipboardClass registers the listener's window class unless an
+// earlier run already did.
+func registerClipboardClass(clsName *uint16, hInstance windows.Handle) error {
+	clipboardClassMu.Lock()
+	defer clipboardClassMu.Unlock()
+	if clipboardClassReg {
+		return nil
+	}
 	var wc WNDCLASSEXW
 	wc.CbSize = uint32(unsafe.Sizeof(wc))
-	wc.LpfnWndProc = wndProc
+	wc.LpfnWndProc = clipboardWndProc()
 	wc.HInstance = hInstance
 	wc.LpszClassName = clsName
-	_, _ = registerClassEx(&wc)
+	if atom, err := registerClassEx(&wc); atom == 0 && !errors.Is(err, windows.ERROR_CLASS_ALREADY_EXISTS) {
+		return fmt.Errorf("register clipboard window class: %w", err)
+	}
+	clipboardClassReg = true
+	return nil
+}
+
+// Run returns nil once stopCh is closed, or an error if the listener could
+// not be set up or its message loop ended on its own.
+func (c *clipboardListener) Run(stopCh <-chan struct{}) error {
+	clsName, _ := windows.UTF16PtrFromString(clipboardClassName)
+	hInstance := getModuleHandle()
+	if err := registerClipboardClass(clsName, hInstance); err != nil {
+		return err
+	}
 
 	hwnd := createWindowEx(
 		0,
@@ -53,32 +108,51 @@ func (c *clipboardListener) Run(stopCh <-chan struct{}) {
 		0,
 	)
 	if hwnd == 0 {
-		return
+		return errors.New("create clipboard window failed")
 	}
 	c.hwnd = hwnd
+	clipboardWindows.Store(hwnd, c)
+	defer clipboardWindows.Delete(hwnd)
+	defer destroyWindow(hwnd)
 
-	_ = addClipboardFormatListener(hwnd)
+	if err := addClipboardFormatListener(hwnd); err != nil {
+		return err
+	}
+	defer removeClipboardFormatListener(hwnd)
 
-	var msg MSG
-	for {
+	// GetMessage блокирует, поэтому при остановке будим цикл сообщением
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
 		select {
 		case <-stopCh:
-			_ = removeClipboardFormatListener(hwnd)
-			_ = destroyWindow(hwnd)
-			return
-		default:
-			ret, _ := getMessage(&msg, 0, 0, 0)
-			if !ret {
-				return
+			_ = postMessage(hwnd, WM_CLOSE, 0, 0)
+		case <-done:
+		}
+	}()
+
+	var msg MSG
+	for {
+		ret, err := getMessage(&msg, 0, 0, 0)
+		if !ret {
+			select {
+			case <-stopCh:
+				return nil
+			default:
+			}
+			if err != nil {
+				return err
 			}
-			translateMessage(&msg)
-			dispatchMessage(&msg)
+			return errors.New("clipboard message loop ended")
 		}
+		translateMessage(&msg)
+		dispatchMessage(&msg)
 	}
 }
 
 const (
 	WM_DESTROY                 = 0x0002
+	WM_CLOSE                   = 0x0010
 	WM_CLIPBOARDUPDATE         = 0x031D
 	HWND_MESSAGE       uintptr = ^uintptr(2)
 )
@@ -104,6 +178,7 @@ var (
 	procDefWindowProcW                = user32.NewProc("DefWindowProcW")
 	procGetMessageW                   = user32.NewProc("GetMessageW")
 	procPostQuitMessage               = user32.NewProc("PostQuitMessage")
+	procPostMessageW                  = user32.NewProc("PostMessageW")
 	procDestroyWindow                 = user32.NewProc("DestroyWindow")
 	procAddClipboardFormatListener    = user32.NewProc("AddClipboardFormatListener")
 	procRemoveClipboardFormatListener = user32.NewProc("RemoveClipboardFormatListener")
@@ -149,6 +224,14 @@ func postQuitMessage(code int32) {
 	_, _, _ = procPostQuitMessage.Call(uintptr(code))
 }
 
+func postMessage(hwnd uintptr, msg uint32, wParam, lParam uintptr) error {
+	r1, _, e1 := procPostMessageW.Call(hwnd, uintptr(msg), wParam, lParam)
+	if r1 == 0 {
+		return e1
+	}
+	return nil
+}
+
 func destroyWindow(hwnd uintptr) error {
 	r1, _, e1 := procDestroyWindow.Call(hwnd)
 	if r1 == 0 {
@@ -0,0 +1,30 @@
+package events
+
+import "time"
+
+// clock is what the bus measures time with: Block timeouts, coalescing and
+// source backoff. Tests replace it with a fake one.
+type clock interface {
+	Now() time.Time
+	// AfterFunc calls f in its own goroutine once d has passed.
+	AfterFunc(d time.Duration, f func()) timer
+}
+
+type timer interface {
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) AfterFunc(d time.Duration, f func()) timer { return time.AfterFunc(d, f) }
+
+// after returns a channel that is closed once d has passed, and a function
+// that releases the timer early.
+func after(c clock, d time.Duration) (<-chan struct{}, func()) {
+	ch := make(chan struct{})
+	t := c.AfterFunc(d, func() { close(ch) })
+	return ch, func() { t.Stop() }
+}
@@ -0,0 +1,130 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// MetaCoalesced is set on an event that stands for a burst: the number of
+// events merged into it. The event itself is the last one of the burst.
+const MetaCoalesced = "coalesced"
+
+// CoalesceOptions merge bursts of events with the same PID, HWND and type.
+// A burst is delivered as its last event once no new event arrived for
+// Quiet, so the final state (e.g. where a dragged window came to rest) is
+// never lost.
+type CoalesceOptions struct {
+	Quiet    time.Duration // 0 turns coalescing off
+	MaxDelay time.Duration // deliver a burst after this long even if it goes on; 0 waits for quiet
+	Types    []EventType   // types to coalesce; empty means all
+}
+
+type coalesceKey struct {
+	pid  int
+	hwnd uintptr
+	typ  EventType
+}
+
+type burst struct {
+	ev    SystemEvent
+	n     int
+	first time.Time
+	timer timer
+}
+
+type coalescer struct {
+	clock   clock
+	mu      sync.Mutex
+	opts    CoalesceOptions
+	pending map[coalesceKey]*burst
+	out     func(SystemEvent)
+	merged  uint64
+}
+
+func newCoalescer(c clock, out func(SystemEvent)) *coalescer {
+	return &coalescer{clock: c, pending: map[coalesceKey]*burst{}, out: out}
+}
+
+func (c *coalescer) setOptions(opts CoalesceOptions) {
+	c.mu.Lock()
+	c.opts = opts
+	c.mu.Unlock()
+}
+
+// add holds ev back as part of a burst. It returns false when ev is not
+// coalesced and must be passed on directly.
+func (c *coalescer) add(ev SystemEvent) bool {
+	c.mu.Lock()
+	opts := c.opts
+	if opts.Quiet <= 0 || (len(opts.Types) > 0 && !containsType(opts.Types, ev.Type)) {
+		c.mu.Unlock()
+		return false
+	}
+	k := coalesceKey{pid: ev.PID, hwnd: ev.HWND, typ: ev.Type}
+	now := c.clock.Now()
+	b := c.pending[k]
+	if b == nil {
+		b = &burst{first: now}
+		c.pending[k] = b
+		b.timer = c.clock.AfterFunc(opts.Quiet, func() { c.fire(k, b) })
+	} else {
+		c.merged++
+		b.timer.Reset(opts.Quiet)
+	}
+	b.ev = ev
+	b.n++
+	if opts.MaxDelay > 0 && now.Sub(b.first) >= opts.MaxDelay {
+		b.timer.Stop()
+		delete(c.pending, k)
+		c.mu.Unlock()
+		c.out(b.event())
+		return true
+	}
+	c.mu.Unlock()
+	return true
+}
+
+func (c *coalescer) fire(k coalesceKey, b *burst) {
+	c.mu.Lock()
+	if c.pending[k] != b {
+		// Delivered by MaxDelay or close in the meantime.
+		c.mu.Unlock()
+		return
+	}
+	delete(c.pending, k)
+	ev := b.event()
+	c.mu.Unlock()
+	c.out(ev)
+}
+
+func (b *burst) event() SystemEvent {
+	ev := b.ev
+	if b.n > 1 {
+		// Metadata may be shared with other subscribers.
+		md := make(map[string]any, len(ev.Metadata)+1)
+		for k, v := range ev.Metadata {
+			md[k] = v
+		}
+		md[MetaCoalesced] = b.n
+		ev.Metadata = md
+	}
+	return ev
+}
+
+func (c *coalescer) mergedCount() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.merged
+}
+
+// close discards the pending bursts and returns how many there were.
+func (c *coalescer) close() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := len(c.pending)
+	for k, b := range c.pending {
+		b.timer.Stop()
+		delete(c.pending, k)
+	}
+	return n
+}
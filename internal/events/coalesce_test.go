@@ -0,0 +1,124 @@
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+func move(hwnd uintptr, step int) SystemEvent {
+	return SystemEvent{Type: EventWindowMoved, PID: 1, HWND: hwnd, Metadata: map[string]any{"step": step}}
+}
+
+func coalescedCount(ev SystemEvent) int {
+	n, _ := ev.Metadata[MetaCoalesced].(int)
+	return n
+}
+
+// A burst is delivered once, as its last event, when it has been quiet.
+func TestCoalesceTrailing(t *testing.T) {
+	clock := newFakeClock()
+	b := newBus(8, clock)
+	defer b.Stop()
+	sub := b.Subscribe(SubscribeOptions{Coalesce: CoalesceOptions{Quiet: 100 * time.Millisecond, Types: []EventType{EventWindowMoved}}})
+
+	last := move(10, 3)
+	b.Emit(move(10, 1))
+	clock.Advance(50 * time.Millisecond)
+	b.Emit(move(10, 2))
+	b.Emit(move(11, 1)) // another window is its own burst
+	clock.Advance(50 * time.Millisecond)
+	b.Emit(last)
+	// Other types pass straight through.
+	b.Emit(SystemEvent{Type: EventForegroundChanged, PID: 1, HWND: 10})
+	if ev := recv(t, sub); ev.Type != EventForegroundChanged || ev.Seq != 1 {
+		t.Fatalf("got %+v", ev)
+	}
+	expectEmpty(t, sub)
+
+	clock.Advance(49 * time.Millisecond) // window 11 has been quiet for 99ms
+	expectEmpty(t, sub)
+	clock.Advance(time.Millisecond)
+	if ev := recv(t, sub); ev.HWND != 11 || coalescedCount(ev) != 0 || ev.Seq != 2 {
+		t.Fatalf("single move delivered as %+v", ev)
+	}
+	expectEmpty(t, sub)
+	clock.Advance(50 * time.Millisecond)
+	ev := recv(t, sub)
+	if ev.HWND != 10 || ev.Metadata["step"] != 3 || coalescedCount(ev) != 3 || ev.Seq != 3 {
+		t.Fatalf("burst delivered as %+v", ev)
+	}
+	// The emitter's metadata is not changed.
+	if _, ok := last.Metadata[MetaCoalesced]; ok {
+		t.Fatal("coalesced count written into the emitted event")
+	}
+	if st := sub.Stats(); st.Coalesced != 2 || st.Delivered != 3 || st.Dropped != 0 {
+		t.Fatalf("stats %+v", st)
+	}
+}
+
+// A burst that goes on is delivered every MaxDelay, and its tail once quiet.
+func TestCoalesceMaxDelay(t *testing.T) {
+	clock := newFakeClock()
+	b := newBus(8, clock)
+	defer b.Stop()
+	sub := b.Subscribe(SubscribeOptions{Coalesce: CoalesceOptions{Quiet: 100 * time.Millisecond, MaxDelay: 250 * time.Millisecond}})
+
+	var got []SystemEvent
+	for step := 0; step <= 12; step++ {
+		if step > 0 {
+			clock.Advance(50 * time.Millisecond)
+		}
+		b.Emit(move(10, step))
+		select {
+		case ev := <-sub.Events():
+			got = append(got, ev)
+		default:
+		}
+	}
+	// Bursts start at steps 0 and 6 and reach MaxDelay 250ms later.
+	if len(got) != 2 || got[0].Metadata["step"] != 5 || coalescedCount(got[0]) != 6 ||
+		got[1].Metadata["step"] != 11 || coalescedCount(got[1]) != 6 {
+		t.Fatalf("capped deliveries %+v", got)
+	}
+	clock.Advance(99 * time.Millisecond)
+	expectEmpty(t, sub)
+	clock.Advance(time.Millisecond)
+	if ev := recv(t, sub); ev.Metadata["step"] != 12 || coalescedCount(ev) != 0 {
+		t.Fatalf("tail delivered as %+v", ev)
+	}
+	// The capped bursts' timers do not deliver them again.
+	clock.Advance(time.Second)
+	expectEmpty(t, sub)
+}
+
+func TestCoalesceCloseAndOff(t *testing.T) {
+	clock := newFakeClock()
+	b := newBus(8, clock)
+	defer b.Stop()
+	sub := b.Subscribe(SubscribeOptions{Coalesce: CoalesceOptions{Quiet: 100 * time.Millisecond}})
+
+	// Quiet 0 turns coalescing off; bursts already pending keep their timer.
+	b.Emit(move(10, 1))
+	sub.SetCoalesce(CoalesceOptions{})
+	b.Emit(move(11, 1))
+	if ev := recv(t, sub); ev.HWND != 11 {
+		t.Fatalf("got %+v", ev)
+	}
+	clock.Advance(100 * time.Millisecond)
+	if ev := recv(t, sub); ev.HWND != 10 {
+		t.Fatalf("got %+v", ev)
+	}
+
+	// Closing drops what is pending.
+	sub.SetCoalesce(CoalesceOptions{Quiet: 100 * time.Millisecond})
+	b.Emit(move(10, 2))
+	b.Emit(move(12, 1))
+	sub.Close()
+	clock.Advance(time.Second)
+	if st := sub.Stats(); st.Dropped != 2 || st.Delivered != 2 {
+		t.Fatalf("stats %+v", st)
+	}
+	if _, ok := <-sub.Events(); ok {
+		t.Fatal("event after Close")
+	}
+}
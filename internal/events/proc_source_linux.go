@@ -0,0 +1,163 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const DefaultProcessScanInterval = 2 * time.Second
+
+// procSource reports process starts and exits by comparing scans of /proc.
+// A PID is only the same process if its start time is unchanged, so a reused
+// PID shows up as an exit followed by a start. Processes already running at
+// the first scan are not reported.
+type procSource struct {
+	root     string
+	interval func() time.Duration
+}
+
+func newProcSource(interval func() time.Duration) *procSource {
+	return &procSource{root: "/proc", interval: interval}
+}
+
+func (p *procSource) Name() string { return "proc" }
+
+// procInfo is what is known about a process seen in a scan. The metadata is
+// read once, when the process first shows up, because after the exit /proc
+// has nothing left to read.
+type procInfo struct {
+	start uint64 // clock ticks after boot
+	meta  map[string]any
+}
+
+func (p *procSource) Start(ctx context.Context, emit func(SystemEvent)) error {
+	if _, err := os.Stat(filepath.Join(p.root, "self", "stat")); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrNotSupported
+		}
+		return err
+	}
+	known, err := p.scan(nil)
+	if err != nil {
+		return err
+	}
+	for {
+		t := time.NewTimer(p.scanInterval())
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil
+		case <-t.C:
+		}
+		cur, err := p.scan(known)
+		if err != nil {
+			return err
+		}
+		now := time.Now().UTC().UnixMilli()
+		for pid, old := range known {
+			if c, ok := cur[pid]; !ok || c.start != old.start {
+				emit(SystemEvent{Type: EventProcessExited, Timestamp: now, PID: pid, Metadata: old.meta})
+			}
+		}
+		for pid, c := range cur {
+			if old, ok := known[pid]; !ok || old.start != c.start {
+				emit(SystemEvent{Type: EventProcessStarted, Timestamp: now, PID: pid, Metadata: c.meta})
+			}
+		}
+		known = cur
+	}
+}
+
+func (p *procSource) scanInterval() time.Duration {
+	if p.interval != nil {
+		if d := p.interval(); d > 0 {
+			return d
+		}
+	}
+	return DefaultProcessScanInterval
+}
+
+// scan lists the running processes. Metadata is carried over from prev for
+// processes that were already there.
+func (p *procSource) scan(prev map[int]procInfo) (map[int]procInfo, error) {
+	entries, err := os.ReadDir(p.root)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[int]procInfo, len(entries))
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || pid <= 0 {
+			continue
+		}
+		ppid, start, ok := p.readStat(pid)
+		if !ok {
+			// Ушёл между ReadDir и чтением stat
+			continue
+		}
+		if old, ok := prev[pid]; ok && old.start == start {
+			out[pid] = old
+			continue
+		}
+		out[pid] = procInfo{start: start, meta: p.readMeta(pid, ppid)}
+	}
+	return out, nil
+}
+
+// readStat returns the parent PID and start time from /proc/<pid>/stat.
+func (p *procSource) readStat(pid int) (ppid int, start uint64, ok bool) {
+	b, err := os.ReadFile(filepath.Join(p.root, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0, 0, false
+	}
+	// comm is in parentheses and may itself contain spaces and ")".
+	i := bytes.LastIndexByte(b, ')')
+	if i < 0 {
+		return 0, 0, false
+	}
+	// Fields after comm start at field 3 (state); ppid is field 4 and
+	// starttime field 22.
+	f := strings.Fields(string(b[i+1:]))
+	if len(f) < 20 {
+		return 0, 0, false
+	}
+	ppid, err = strconv.Atoi(f[1])
+	if err != nil {
+		return 0, 0, false
+	}
+	start, err = strconv.ParseUint(f[19], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return ppid, start, true
+}
+
+// readMeta fills exe, cmdline, ppid and uid. exe is empty for processes of
+// other users unless we run as root; cmdline is empty for kernel threads.
+func (p *procSource) readMeta(pid, ppid int) map[string]any {
+	dir := filepath.Join(p.root, strconv.Itoa(pid))
+	meta := map[string]any{"ppid": ppid}
+
+	exe, _ := os.Readlink(filepath.Join(dir, "exe"))
+	meta["exe"] = strings.TrimSuffix(exe, " (deleted)")
+	if comm, err := os.ReadFile(filepath.Join(dir, "comm")); err == nil {
+		meta["name"] = strings.TrimSpace(string(comm))
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil {
+		args := strings.Split(strings.TrimRight(string(b), "\x00"), "\x00")
+		meta["cmdline"] = strings.TrimSpace(strings.Join(args, " "))
+	}
+	if fi, err := os.Stat(dir); err == nil {
+		if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+			meta["uid"] = int(st.Uid)
+		}
+	}
+	return meta
+}
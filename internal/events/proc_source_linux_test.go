@@ -0,0 +1,71 @@
+package events
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// waitEvent returns the first event of type typ for pid.
+func waitEvent(t *testing.T, ch <-chan SystemEvent, typ EventType, pid int) SystemEvent {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev := <-ch:
+			if ev.Type == typ && ev.PID == pid {
+				return ev
+			}
+		case <-timeout:
+			t.Fatalf("no %s event for pid %d", typ, pid)
+		}
+	}
+}
+
+func TestProcSourceChildProcess(t *testing.T) {
+	src := newProcSource(func() time.Duration { return 20 * time.Millisecond })
+	ch := make(chan SystemEvent, 4096)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- src.Start(ctx, func(ev SystemEvent) {
+			select {
+			case ch <- ev:
+			default:
+			}
+		})
+	}()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Start: %v", err)
+		}
+	}()
+	// Processes running at the first scan are not reported.
+	time.Sleep(100 * time.Millisecond)
+
+	cmd := exec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start sleep: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+	pid := cmd.Process.Pid
+	started := waitEvent(t, ch, EventProcessStarted, pid)
+	if started.Metadata["name"] != "sleep" {
+		t.Errorf("name = %v", started.Metadata["name"])
+	}
+	if started.Metadata["cmdline"] != "sleep 30" {
+		t.Errorf("cmdline = %v", started.Metadata["cmdline"])
+	}
+
+	_ = cmd.Process.Kill()
+	_ = cmd.Wait()
+	exited := waitEvent(t, ch, EventProcessExited, pid)
+	if exited.Metadata["name"] != "sleep" {
+		t.Errorf("exit event lost the metadata: %v", exited.Metadata)
+	}
+}
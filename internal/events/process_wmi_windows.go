@@ -3,56 +3,75 @@
 package events
 
 import (
+	"context"
 	"fmt"
+	"runtime"
 	"time"
 
 	"github.com/go-ole/go-ole"
 	"github.com/go-ole/go-ole/oleutil"
 )
 
-func runProcessWMIWatcher(emit func(SystemEvent), stopCh <-chan struct{}) {
-	go wmiTraceLoop("Win32_ProcessStartTrace", func(pid int, name string) {
-		emit(SystemEvent{
-			Type:      EventProcessStarted,
-			Timestamp: time.Now().UTC().UnixMilli(),
-			PID:       pid,
-			Metadata: map[string]any{
-				"name": name,
-			},
-		})
-	}, stopCh)
+// wmiProcessSource reports process starts and exits through WMI traces.
+type wmiProcessSource struct{}
 
-	go wmiTraceLoop("Win32_ProcessStopTrace", func(pid int, name string) {
-		emit(SystemEvent{
-			Type:      EventProcessExited,
-			Timestamp: time.Now().UTC().UnixMilli(),
-			PID:       pid,
-			Metadata: map[string]any{
-				"name": name,
-			},
-		})
-	}, stopCh)
+func (wmiProcessSource) Name() string { return "wmi_process" }
+
+func (wmiProcessSource) Start(ctx context.Context, emit func(SystemEvent)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	trace := func(className string, typ EventType) func() error {
+		return func() error {
+			return wmiTraceLoop(className, func(pid int, name string) {
+				emit(SystemEvent{
+					Type:      typ,
+					Timestamp: time.Now().UTC().UnixMilli(),
+					PID:       pid,
+					Metadata: map[string]any{
+						"name": name,
+					},
+				})
+			}, ctx.Done())
+		}
+	}
+	errCh := make(chan error, 2)
+	for _, run := range []func() error{
+		trace("Win32_ProcessStartTrace", EventProcessStarted),
+		trace("Win32_ProcessStopTrace", EventProcessExited),
+	} {
+		go func(run func() error) { errCh <- run() }(run)
+	}
+	// Если одна трасса упала, останавливаем и вторую: перезапуск поднимет обе
+	err := <-errCh
+	cancel()
+	if err2 := <-errCh; err == nil {
+		err = err2
+	}
+	return err
 }
 
-func wmiTraceLoop(className string, onEvent func(pid int, name string), stopCh <-chan struct{}) {
+func wmiTraceLoop(className string, onEvent func(pid int, name string), stopCh <-chan struct{}) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
 	_ = ole.CoInitializeEx(0, ole.COINIT_MULTITHREADED)
 	defer ole.CoUninitialize()
 
 	locatorObj, err := oleutil.CreateObject("WbemScripting.SWbemLocator")
 	if err != nil {
-		return
+		return fmt.Errorf("%s: %w", className, err)
 	}
 	defer locatorObj.Release()
 
 	locator, err := locatorObj.QueryInterface(ole.IID_IDispatch)
 	if err != nil {
-		return
+		return fmt.Errorf("%s: %w", className, err)
 	}
 	defer locator.Release()
 
 	svcRaw, err := oleutil.CallMethod(locator, "ConnectServer", nil, "root\\cimv2")
 	if err != nil {
-		return
+		return fmt.Errorf("%s: %w", className, err)
 	}
 	svc := svcRaw.ToIDispatch()
 	defer svc.Release()
@@ -60,7 +79,7 @@ func wmiTraceLoop(className string, onEvent func(pid int, name string), stopCh <
 	query := fmt.Sprintf("SELECT * FROM %s", className)
 	srcRaw, err := oleutil.CallMethod(svc, "ExecNotificationQuery", query)
 	if err != nil {
-		return
+		return fmt.Errorf("%s: %w", className, err)
 	}
 	src := srcRaw.ToIDispatch()
 	defer src.Release()
@@ -68,7 +87,7 @@ func wmiTraceLoop(className string, onEvent func(pid int, name string), stopCh <
 	for {
 		select {
 		case <-stopCh:
-			return
+			return nil
 		default:
 			evRaw, err := oleutil.CallMethod(src, "NextEvent", 1000)
 			if err != nil {
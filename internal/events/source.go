@@ -0,0 +1,228 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Source produces events for a bus. Start runs until ctx is done and then
+// returns nil. Returning earlier is a failure: the bus starts the source again
+// after a backoff, unless the error is ErrNotSupported.
+type Source interface {
+	Name() string
+	Start(ctx context.Context, emit func(SystemEvent)) error
+}
+
+type funcSource struct {
+	name string
+	run  func(ctx context.Context, emit func(SystemEvent)) error
+}
+
+// SourceFunc makes a Source out of a function, e.g. a timer or a test fake.
+func SourceFunc(name string, run func(ctx context.Context, emit func(SystemEvent)) error) Source {
+	return funcSource{name: name, run: run}
+}
+
+func (f funcSource) Name() string { return f.name }
+
+func (f funcSource) Start(ctx context.Context, emit func(SystemEvent)) error {
+	return f.run(ctx, emit)
+}
+
+// SourceOptions tune the platform sources. Funcs are called on every use,
+// so they can follow config changes.
+type SourceOptions struct {
+	ProcessScanInterval func() time.Duration // Linux /proc scans
+}
+
+// Source states reported by SourceStatus.
+const (
+	SourceRunning     = "running"
+	SourceRestarting  = "restarting" // failed, waiting for the next attempt
+	SourceUnsupported = "unsupported"
+	SourceStopped     = "stopped"
+)
+
+const (
+	sourceMinBackoff = time.Second
+	sourceMaxBackoff = 30 * time.Second
+	// A source that ran this long before failing starts over with the
+	// shortest backoff.
+	sourceHealthyRun = time.Minute
+)
+
+// SourceStatus is the health of one registered source.
+type SourceStatus struct {
+	Name         string `json:"name"`
+	State        string `json:"state"`
+	Restarts     int    `json:"restarts"`
+	LastError    string `json:"lastError,omitempty"`
+	LastErrorUTC int64  `json:"lastErrorUTC,omitempty"`
+	StartedUTC   int64  `json:"startedUTC,omitempty"`
+}
+
+type sourceRun struct {
+	src    Source
+	clock  clock
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu     sync.Mutex
+	status SourceStatus
+}
+
+func (r *sourceRun) update(fn func(st *SourceStatus)) {
+	r.mu.Lock()
+	fn(&r.status)
+	r.mu.Unlock()
+}
+
+func (r *sourceRun) snapshot() SourceStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+func (r *sourceRun) loop(ctx context.Context, emit func(SystemEvent)) {
+	defer close(r.done)
+	backoff := sourceMinBackoff
+	for {
+		began := r.clock.Now()
+		r.update(func(st *SourceStatus) {
+			st.State = SourceRunning
+			st.StartedUTC = began.UTC().UnixMilli()
+		})
+		err := startSource(ctx, r.src, emit)
+		if ctx.Err() != nil {
+			r.update(func(st *SourceStatus) { st.State = SourceStopped })
+			return
+		}
+		if err == nil {
+			err = errors.New("source stopped on its own")
+		}
+		now := r.clock.Now()
+		if errors.Is(err, ErrNotSupported) {
+			r.update(func(st *SourceStatus) {
+				st.State = SourceUnsupported
+				st.LastError = err.Error()
+				st.LastErrorUTC = now.UTC().UnixMilli()
+			})
+			return
+		}
+		if now.Sub(began) >= sourceHealthyRun {
+			backoff = sourceMinBackoff
+		}
+		slog.Warn("event source failed", "source", r.src.Name(), "restartIn", backoff, "err", err)
+		r.update(func(st *SourceStatus) {
+			st.State = SourceRestarting
+			st.Restarts++
+			st.LastError = err.Error()
+			st.LastErrorUTC = now.UTC().UnixMilli()
+		})
+
+		wait, stop := after(r.clock, backoff)
+		select {
+		case <-ctx.Done():
+			stop()
+			r.update(func(st *SourceStatus) { st.State = SourceStopped })
+			return
+		case <-wait:
+		}
+		backoff *= 2
+		if backoff > sourceMaxBackoff {
+			backoff = sourceMaxBackoff
+		}
+	}
+}
+
+// startSource runs src, turning a panic into a failure so one broken source
+// does not take the app down.
+func startSource(ctx context.Context, src Source, emit func(SystemEvent)) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return src.Start(ctx, emit)
+}
+
+// AddSource starts src and restarts it whenever it fails, until it is removed
+// or the bus stops. Names must be unique.
+func (b *Bus) AddSource(src Source) error {
+	name := src.Name()
+	b.srcMu.Lock()
+	defer b.srcMu.Unlock()
+	if b.ctx.Err() != nil {
+		return errors.New("bus stopped")
+	}
+	for _, r := range b.sources {
+		if r.src.Name() == name {
+			return fmt.Errorf("event source %q already added", name)
+		}
+	}
+	b.sources = append(b.sources, b.runSource(src, 0))
+	return nil
+}
+
+func (b *Bus) runSource(src Source, restarts int) *sourceRun {
+	ctx, cancel := context.WithCancel(b.ctx)
+	r := &sourceRun{
+		src:    src,
+		clock:  b.clock,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		status: SourceStatus{Name: src.Name(), Restarts: restarts},
+	}
+	go r.loop(ctx, b.Emit)
+	return r
+}
+
+// RemoveSource stops the named source and waits for it to return.
+func (b *Bus) RemoveSource(name string) error {
+	b.srcMu.Lock()
+	defer b.srcMu.Unlock()
+	for i, r := range b.sources {
+		if r.src.Name() == name {
+			r.cancel()
+			<-r.done
+			b.sources = append(b.sources[:i:i], b.sources[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("no event source %q", name)
+}
+
+// RestartSource stops the named source and starts it again right away, also
+// when it was given up on as unsupported.
+func (b *Bus) RestartSource(name string) error {
+	b.srcMu.Lock()
+	defer b.srcMu.Unlock()
+	if b.ctx.Err() != nil {
+		return errors.New("bus stopped")
+	}
+	for i, r := range b.sources {
+		if r.src.Name() == name {
+			r.cancel()
+			<-r.done
+			b.sources[i] = b.runSource(r.src, r.snapshot().Restarts+1)
+			return nil
+		}
+	}
+	return fmt.Errorf("no event source %q", name)
+}
+
+// Sources reports the health of the registered sources, in the order they
+// were added.
+func (b *Bus) Sources() []SourceStatus {
+	b.srcMu.Lock()
+	defer b.srcMu.Unlock()
+	out := make([]SourceStatus, 0, len(b.sources))
+	for _, r := range b.sources {
+		out = append(out, r.snapshot())
+	}
+	return out
+}
@@ -0,0 +1,191 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSource runs until ctx is done or the test hands it an error to fail
+// with. starts counts its runs.
+type fakeSource struct {
+	name   string
+	fail   chan error
+	starts chan struct{}
+}
+
+func newFakeSource(name string) *fakeSource {
+	return &fakeSource{name: name, fail: make(chan error), starts: make(chan struct{}, 100)}
+}
+
+func (f *fakeSource) Name() string { return f.name }
+
+func (f *fakeSource) Start(ctx context.Context, emit func(SystemEvent)) error {
+	f.starts <- struct{}{}
+	emit(SystemEvent{Type: EventProcessStarted, Metadata: map[string]any{"source": f.name}})
+	select {
+	case err := <-f.fail:
+		if err != nil && err.Error() == "panic" {
+			panic("source broke")
+		}
+		return err
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+func sourceStatus(b *Bus, name string) (SourceStatus, bool) {
+	for _, st := range b.Sources() {
+		if st.Name == name {
+			return st, true
+		}
+	}
+	return SourceStatus{}, false
+}
+
+func waitState(t *testing.T, b *Bus, name, state string) SourceStatus {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		st, _ := sourceStatus(b, name)
+		if st.State == state {
+			return st
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("source %s is %q, want %q", name, st.State, state)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// A failing source is restarted after a backoff that doubles up to the cap
+// and starts over once the source has run for a while.
+func TestSourceRestartBackoff(t *testing.T) {
+	clock := newFakeClock()
+	b := newBus(8, clock)
+	defer b.Stop()
+	src := newFakeSource("flaky")
+	if err := b.AddSource(src); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.AddSource(newFakeSource("flaky")); err == nil {
+		t.Fatal("added a second source with the same name")
+	}
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 30 * time.Second, 30 * time.Second}
+	for i, d := range want {
+		<-src.starts
+		src.fail <- fmt.Errorf("failure %d", i+1)
+		if p := waitPending(t, clock, 1); p[0] != d {
+			t.Fatalf("backoff after failure %d is %v, want %v", i+1, p[0], d)
+		}
+		st := waitState(t, b, "flaky", SourceRestarting)
+		if st.Restarts != i+1 || st.LastError != fmt.Sprintf("failure %d", i+1) || st.LastErrorUTC != clock.Now().UnixMilli() {
+			t.Fatalf("status %+v", st)
+		}
+		clock.Advance(d - time.Millisecond)
+		if len(src.starts) != 0 {
+			t.Fatal("restarted before the backoff")
+		}
+		clock.Advance(time.Millisecond)
+	}
+
+	// A healthy run resets the backoff.
+	<-src.starts
+	waitState(t, b, "flaky", SourceRunning)
+	clock.Advance(sourceHealthyRun)
+	src.fail <- errors.New("late failure")
+	if p := waitPending(t, clock, 1); p[0] != sourceMinBackoff {
+		t.Fatalf("backoff after a healthy run is %v", p[0])
+	}
+
+	// Returning early without an error and panicking are failures too.
+	clock.Advance(sourceMinBackoff)
+	<-src.starts
+	src.fail <- nil
+	waitPending(t, clock, 1)
+	if st := waitState(t, b, "flaky", SourceRestarting); st.LastError != "source stopped on its own" {
+		t.Fatalf("status %+v", st)
+	}
+	clock.Advance(2 * sourceMinBackoff)
+	<-src.starts
+	src.fail <- errors.New("panic")
+	waitPending(t, clock, 1)
+	if st := waitState(t, b, "flaky", SourceRestarting); !strings.HasPrefix(st.LastError, "panic: source broke") {
+		t.Fatalf("status %+v", st)
+	}
+}
+
+func TestSourceNotSupported(t *testing.T) {
+	clock := newFakeClock()
+	b := newBus(8, clock)
+	defer b.Stop()
+	src := newFakeSource("hooks")
+	if err := b.AddSource(src); err != nil {
+		t.Fatal(err)
+	}
+	<-src.starts
+	src.fail <- fmt.Errorf("no display: %w", ErrNotSupported)
+	st := waitState(t, b, "hooks", SourceUnsupported)
+	if st.Restarts != 0 || !strings.Contains(st.LastError, "not supported") {
+		t.Fatalf("status %+v", st)
+	}
+	if p := clock.pending(); len(p) != 0 {
+		t.Fatalf("restart scheduled for an unsupported source: %v", p)
+	}
+
+	// An explicit restart tries again.
+	if err := b.RestartSource("hooks"); err != nil {
+		t.Fatal(err)
+	}
+	<-src.starts
+	if st := waitState(t, b, "hooks", SourceRunning); st.Restarts != 1 {
+		t.Fatalf("status %+v", st)
+	}
+}
+
+func TestSourceCancel(t *testing.T) {
+	clock := newFakeClock()
+	b := newBus(8, clock)
+	sub := b.Subscribe(SubscribeOptions{})
+	a, c := newFakeSource("a"), newFakeSource("c")
+	for _, src := range []*fakeSource{a, c} {
+		if err := b.AddSource(src); err != nil {
+			t.Fatal(err)
+		}
+		<-src.starts
+	}
+	got := map[any]bool{}
+	for i := 0; i < 2; i++ {
+		got[recv(t, sub).Metadata["source"]] = true
+	}
+	if !got["a"] || !got["c"] {
+		t.Fatalf("events from %v", got)
+	}
+
+	// RemoveSource cancels the running source and waits for it.
+	if err := b.RemoveSource("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sourceStatus(b, "a"); ok {
+		t.Fatal("removed source still listed")
+	}
+	if err := b.RemoveSource("a"); err == nil {
+		t.Fatal("removed a source twice")
+	}
+
+	// Stopping the bus ends a backoff wait.
+	c.fail <- errors.New("broken")
+	waitPending(t, clock, 1)
+	b.Stop()
+	waitState(t, b, "c", SourceStopped)
+	if len(c.starts) != 0 {
+		t.Fatal("restarted after Stop")
+	}
+	if err := b.AddSource(newFakeSource("late")); err == nil {
+		t.Fatal("added a source to a stopped bus")
+	}
+}
@@ -0,0 +1,7 @@
+package events
+
+// DefaultSources are the event sources of this platform. Linux has process
+// starts and exits from /proc.
+func DefaultSources(opts SourceOptions) []Source {
+	return []Source{newProcSource(opts.ProcessScanInterval)}
+}
@@ -0,0 +1,8 @@
+//go:build !windows && !linux
+
+package events
+
+// DefaultSources are the event sources of this platform; there are none yet.
+func DefaultSources(opts SourceOptions) []Source {
+	return nil
+}
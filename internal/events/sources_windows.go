@@ -0,0 +1,9 @@
+//go:build windows
+
+package events
+
+// DefaultSources are the event sources of this platform: window events,
+// clipboard changes and process starts and exits.
+func DefaultSources(opts SourceOptions) []Source {
+	return []Source{winEventSource{}, clipboardSource{}, wmiProcessSource{}}
+}
@@ -1,28 +1,63 @@
+//go:build windows
+
 package events
 
 import (
+	"context"
+	"runtime"
+	"sync"
 	"time"
 	"unsafe"
 
 	"golang.org/x/sys/windows"
 )
 
+// winEventSource reports foreground changes and windows being moved, shown,
+// hidden and destroyed.
+type winEventSource struct{}
+
+func (winEventSource) Name() string { return "winevent" }
+
+func (winEventSource) Start(ctx context.Context, emit func(SystemEvent)) error {
+	// Хук вне контекста доставляет события в очередь потока, который его поставил
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	w, err := newWinEventHook(emit)
+	if err != nil {
+		return err
+	}
+	w.Run(ctx.Done())
+	return nil
+}
+
 type winEventHook struct {
 	emit func(SystemEvent)
 
 	hHook windows.Handle
-	cb    uintptr
+}
+
+// Слоты под колбэки NewCallback не освобождаются, поэтому колбэк один на
+// процесс, а хук находится по своему хэндлу.
+var (
+	winEventProc  = sync.OnceValue(func() uintptr { return windows.NewCallback(winEventCallback) })
+	winEventHooks sync.Map // windows.Handle -> *winEventHook
+)
+
+func winEventCallback(hWinEventHook windows.Handle, event uint32, hwnd uintptr, idObject int32, idChild int32, dwEventThread uint32, dwmsEventTime uint32) uintptr {
+	if w, ok := winEventHooks.Load(hWinEventHook); ok {
+		return w.(*winEventHook).callback(hWinEventHook, event, hwnd, idObject, idChild, dwEventThread, dwmsEventTime)
+	}
+	return 0
 }
 
 func newWinEventHook(emit func(SystemEvent)) (*winEventHook, error) {
 	w := &winEventHook{emit: emit}
-	w.cb = windows.NewCallback(w.callback)
 
 	h, err := setWinEventHook(
 		EVENT_SYSTEM_FOREGROUND,
 		EVENT_OBJECT_LOCATIONCHANGE,
 		0,
-		w.cb,
+		winEventProc(),
 		0,
 		0,
 		WINEVENT_OUTOFCONTEXT|WINEVENT_SKIPOWNPROCESS,
@@ -31,6 +66,7 @@ func newWinEventHook(emit func(SystemEvent)) (*winEventHook, error) {
 		return nil, err
 	}
 	w.hHook = h
+	winEventHooks.Store(h, w)
 	return w, nil
 }
 
@@ -41,6 +77,7 @@ func (w *winEventHook) Run(stopCh <-chan struct{}) {
 		case <-stopCh:
 			if w.hHook != 0 {
 				_ = unhookWinEvent(w.hHook)
+				winEventHooks.Delete(w.hHook)
 			}
 			return
 		default:
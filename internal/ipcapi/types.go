@@ -1,6 +1,10 @@
 package ipcapi
 
-import "time"
+import (
+	"time"
+
+	"Rewinder/internal/policy"
+)
 
 type AppSummary struct {
 	AppID           string `json:"appID"`
@@ -9,16 +13,81 @@ type AppSummary struct {
 	LastActivityUTC int64  `json:"lastActivityUTC"`
 	SnapshotCount   int    `json:"snapshotCount"`
 	TrackingState   string `json:"trackingState"`
+	TrackingDetail  string `json:"trackingDetail,omitempty"`
 	RetentionStatus string `json:"retentionStatus"`
+	RetentionDetail string `json:"retentionDetail,omitempty"`
+
+	Policy *EffectivePolicy `json:"policy,omitempty"`
+}
+
+// EffectivePolicy is the config that applies to one app once its overrides
+// are merged in. Durations use Go syntax such as "336h0m0s".
+type EffectivePolicy struct {
+	Retention           string   `json:"retention"`
+	MaxSnapshots        int      `json:"maxSnapshots"`
+	CaptureMinInterval  string   `json:"captureMinInterval"`
+	SnapshotMinInterval string   `json:"snapshotMinInterval"`
+	Threshold           float64  `json:"threshold"`
+	BurstThreshold      float64  `json:"burstThreshold"`
+	DisabledPlugins     []string `json:"disabledPlugins,omitempty"`
+	Overrides           []string `json:"overrides,omitempty"`
+}
+
+// TrackingExplanation tells why an app is or is not being tracked. State is
+// "active", "paused", "excluded", "error" or "unseen"; Reason is a stable code
+// and Detail a sentence for the user.
+type TrackingExplanation struct {
+	AppID          string        `json:"appID"`
+	Tracked        bool          `json:"tracked"`
+	State          string        `json:"state"`
+	Reason         string        `json:"reason"`
+	Detail         string        `json:"detail"`
+	Rule           *TrackingRule `json:"rule,omitempty"`
+	LastSeenUTC    int64         `json:"lastSeenUTC,omitempty"`
+	LastOutcome    string        `json:"lastOutcome,omitempty"`
+	LastSnapshotAt int64         `json:"lastSnapshotUTC,omitempty"`
+}
+
+type TrackingRule struct {
+	Index   int    `json:"index"`
+	Action  string `json:"action"`
+	Field   string `json:"field"`
+	Match   string `json:"match"`
+	Pattern string `json:"pattern"`
 }
 
 type SnapshotMeta struct {
-	SnapshotID   string `json:"snapshotID"`
-	AppID        string `json:"appID"`
-	Timestamp    int64  `json:"timestampUTC"`
-	WindowsCount int    `json:"windowsCount"`
-	FilesAdded   int    `json:"filesAdded"`
-	FilesRemoved int    `json:"filesRemoved"`
+	SnapshotID       string `json:"snapshotID"`
+	AppID            string `json:"appID"`
+	BranchID         string `json:"branchID"`
+	ParentSnapshotID string `json:"parentSnapshotID,omitempty"`
+	Timestamp        int64  `json:"timestampUTC"`
+	WindowsCount     int    `json:"windowsCount"`
+	FilesAdded       int    `json:"filesAdded"`
+	FilesRemoved     int    `json:"filesRemoved"`
+	// Redactions counts replaced secrets per field: windowTitle, commandLine, pluginData.
+	Redactions map[string]int `json:"redactions,omitempty"`
+}
+
+// BranchInfo describes one branch of a timeline. Branches other than "main"
+// fork from ParentSnapshotID, the snapshot restored when they were started.
+// The parent may be missing from the timeline once it has expired.
+type BranchInfo struct {
+	BranchID         string `json:"branchID"`
+	ParentBranchID   string `json:"parentBranchID,omitempty"`
+	ParentSnapshotID string `json:"parentSnapshotID,omitempty"`
+	CreatedAtUTC     int64  `json:"createdAtUTC"`
+	SnapshotCount    int    `json:"snapshotCount"`
+	HeadSnapshotID   string `json:"headSnapshotID,omitempty"`
+	HeadUTC          int64  `json:"headUTC,omitempty"`
+	Active           bool   `json:"active"`
+}
+
+type BranchCreatedEvent struct {
+	AppID            string `json:"appID"`
+	BranchID         string `json:"branchID"`
+	ParentSnapshotID string `json:"parentSnapshotID"`
+	AtUTC            int64  `json:"atUTC"`
 }
 
 type SnapshotCreatedEvent struct {
@@ -41,11 +110,126 @@ type RestoreErrorEvent struct {
 	Error      string `json:"error"`
 }
 
+// TrackingStateChangedEvent announces a pause or resume. For timed pauses
+// UntilUTC is when tracking resumes and RemainingMs the time left when the
+// event was sent.
 type TrackingStateChangedEvent struct {
-	AppID  *string `json:"appID,omitempty"`
-	State  string  `json:"state"`
-	Reason string  `json:"reason,omitempty"`
-	AtUTC  int64   `json:"atUTC"`
+	AppID       *string `json:"appID,omitempty"`
+	State       string  `json:"state"`
+	Reason      string  `json:"reason,omitempty"`
+	AtUTC       int64   `json:"atUTC"`
+	UntilUTC    int64   `json:"untilUTC,omitempty"`
+	RemainingMs int64   `json:"remainingMs,omitempty"`
+}
+
+type HistoryDeletedEvent struct {
+	AppID   string `json:"appID"`
+	FromUTC int64  `json:"fromUTC,omitempty"`
+	ToUTC   int64  `json:"toUTC,omitempty"`
+	Removed int    `json:"removed"`
+	AtUTC   int64  `json:"atUTC"`
+}
+
+// SearchFilter narrows a history search. Zero values mean no restriction;
+// Fields takes "title", "path", "cmdline" and "plugin".
+type SearchFilter struct {
+	AppID   string   `json:"appID,omitempty"`
+	FromUTC int64    `json:"fromUTC,omitempty"`
+	ToUTC   int64    `json:"toUTC,omitempty"`
+	Fields  []string `json:"fields,omitempty"`
+	Limit   int      `json:"limit,omitempty"`
+}
+
+type SearchHit struct {
+	AppID      string   `json:"appID"`
+	AppName    string   `json:"appName"`
+	SnapshotID string   `json:"snapshotID"`
+	Timestamp  int64    `json:"timestampUTC"`
+	Score      float64  `json:"score"`
+	Fields     []string `json:"fields"`
+	Terms      []string `json:"terms"`
+}
+
+// ConfigErrorEvent reports a config file change that was rejected; the
+// previous settings stay in effect.
+type ConfigErrorEvent struct {
+	Path   string             `json:"path"`
+	Errors []ConfigFieldError `json:"errors"`
+	AtUTC  int64              `json:"atUTC"`
+}
+
+type ConfigFieldError struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// ConfigUpdateResult is returned by UpdateConfig and ResetConfig. When Errors
+// is set the config was rejected and nothing changed.
+type ConfigUpdateResult struct {
+	Applied         bool               `json:"applied"`
+	Errors          []ConfigFieldError `json:"errors,omitempty"`
+	RestartRequired []string           `json:"restartRequired,omitempty"`
+}
+
+// ConfigChangedEvent carries the new config file and the settings now in
+// effect, which have the active profile applied. Source is "api", "reset",
+// "profile" or "file" for edits made to the config file directly.
+type ConfigChangedEvent struct {
+	Config          policy.Config `json:"config"`
+	Effective       policy.Config `json:"effective"`
+	Source          string        `json:"source"`
+	RestartRequired []string      `json:"restartRequired,omitempty"`
+	AtUTC           int64         `json:"atUTC"`
+}
+
+// ProfilesInfo lists the configured profiles; Active is "" when the base
+// settings are in use.
+type ProfilesInfo struct {
+	Profiles []string `json:"profiles"`
+	Active   string   `json:"active"`
+}
+
+type ProfileChangedEvent struct {
+	Profile  string `json:"profile"`
+	Previous string `json:"previous"`
+	AtUTC    int64  `json:"atUTC"`
 }
 
 func NowUTC() int64 { return time.Now().UTC().UnixMilli() }
+
+type StorageStats struct {
+	TotalSnapshots         int               `json:"totalSnapshots"`
+	TotalRAMBytes          int64             `json:"totalRAMBytes"`
+	TotalDiskBytes         int64             `json:"totalDiskBytes"`
+	TotalVaultDiskBytes    int64             `json:"totalVaultDiskBytes"`
+	TotalLaunchDiskBytes   int64             `json:"totalLaunchDiskBytes"`
+	TotalTimelineDiskBytes int64             `json:"totalTimelineDiskBytes"`
+	Apps                   []AppStorageStats `json:"apps"`
+	GeneratedAtUTC         int64             `json:"generatedAtUTC"`
+}
+
+// AppStorageStats splits an app's disk usage: DiskBytes is its spilled
+// snapshots, the others the clipboard vault, the launch vault of raw
+// command lines, and the timeline with its branches.
+
+type AppStorageStats struct {
+	AppID             string       `json:"appID"`
+	Name              string       `json:"name"`
+	SnapshotCount     int          `json:"snapshotCount"`
+	KeyframeCount     int          `json:"keyframeCount"`
+	RAMBytes          int64        `json:"ramBytes"`
+	DiskBytes         int64        `json:"diskBytes"`
+	VaultDiskBytes    int64        `json:"vaultDiskBytes"`
+	LaunchDiskBytes   int64        `json:"launchDiskBytes"`
+	TimelineDiskBytes int64        `json:"timelineDiskBytes"`
+	AvgChainLength    float64      `json:"avgChainLength"`
+	OldestUTC         int64        `json:"oldestUTC"`
+	NewestUTC         int64        `json:"newestUTC"`
+	Rate              []RateBucket `json:"rate"`
+}
+
+// RateBucket counts snapshots taken in the hour starting at StartUTC.
+type RateBucket struct {
+	StartUTC int64 `json:"startUTC"`
+	Count    int   `json:"count"`
+}
@@ -14,20 +14,23 @@ type Registry struct {
 }
 
 func DefaultRegistry() *Registry {
-	return &Registry{
-		list: []AppPlugin{
-			NewVSCodePlugin(),
-			NewChromePlugin(),
-			NewOfficePlugin(),
-		},
-	}
+	return &Registry{list: platformPlugins()}
 }
 
 func (r *Registry) Capture(app *state.AppState) {
+	r.CaptureEnabled(app, nil)
+}
+
+// CaptureEnabled runs only the plugins for which enabled returns true; a nil
+// enabled runs all of them.
+func (r *Registry) CaptureEnabled(app *state.AppState, enabled func(id string) bool) {
 	if app.PluginData == nil {
 		app.PluginData = map[string]any{}
 	}
 	for _, p := range r.list {
+		if enabled != nil && !enabled(p.ID()) {
+			continue
+		}
 		if !p.CanHandle(app) {
 			continue
 		}
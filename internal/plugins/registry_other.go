@@ -0,0 +1,7 @@
+//go:build !windows
+
+package plugins
+
+// The plugins know Windows executables only. Plugin data already present in
+// an app state, e.g. from a replayed recording, is kept as it is.
+func platformPlugins() []AppPlugin { return nil }
@@ -0,0 +1,9 @@
+package plugins
+
+func platformPlugins() []AppPlugin {
+	return []AppPlugin{
+		NewVSCodePlugin(),
+		NewChromePlugin(),
+		NewOfficePlugin(),
+	}
+}
@@ -0,0 +1,144 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AutoPause pauses all tracking on a schedule or while the foreground app
+// matches a condition.
+type AutoPause struct {
+	Schedules  []QuietHours     `json:"schedules"`
+	Conditions []PauseCondition `json:"conditions"`
+}
+
+// QuietHours is a daily window in local time. Days ("mon".."sun") limit it to
+// some weekdays, empty means every day. End before Start wraps past midnight
+// and the window belongs to the day it starts on; equal Start and End, or
+// both empty, cover the whole day.
+type QuietHours struct {
+	Name  string   `json:"name,omitempty"`
+	Days  []string `json:"days,omitempty"`
+	Start string   `json:"start,omitempty"` // "18:00"
+	End   string   `json:"end,omitempty"`   // "09:00"
+}
+
+// PauseCondition pauses tracking while the foreground app matches. Field,
+// Match and Pattern work as in Rule.
+type PauseCondition struct {
+	Name    string `json:"name,omitempty"`
+	Field   string `json:"field"`
+	Match   string `json:"match"`
+	Pattern string `json:"pattern"`
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Evaluate reports whether tracking should be paused at now with fg in the
+// foreground, and why. fg may be nil when the foreground is unknown.
+func (a AutoPause) Evaluate(now time.Time, fg *Target) (bool, string) {
+	for _, q := range a.Schedules {
+		if q.active(now) {
+			return true, "quiet hours: " + q.label()
+		}
+	}
+	if fg != nil {
+		for _, c := range a.Conditions {
+			if c.rule().matches(*fg) {
+				return true, "condition: " + c.label()
+			}
+		}
+	}
+	return false, ""
+}
+
+func (q QuietHours) active(now time.Time) bool {
+	start, _ := parseClock(q.Start)
+	end, _ := parseClock(q.End)
+	mins := now.Hour()*60 + now.Minute()
+	if start == end {
+		return q.onDay(now.Weekday())
+	}
+	if start < end {
+		return q.onDay(now.Weekday()) && mins >= start && mins < end
+	}
+	// Wraps past midnight: the evening part is today's window, the morning
+	// part is yesterday's.
+	if mins >= start {
+		return q.onDay(now.Weekday())
+	}
+	return mins < end && q.onDay((now.Weekday()+6)%7)
+}
+
+func (q QuietHours) onDay(d time.Weekday) bool {
+	if len(q.Days) == 0 {
+		return true
+	}
+	for _, s := range q.Days {
+		if wd, ok := weekdays[strings.ToLower(s)]; ok && wd == d {
+			return true
+		}
+	}
+	return false
+}
+
+func (q QuietHours) label() string {
+	if q.Name != "" {
+		return q.Name
+	}
+	days := "every day"
+	if len(q.Days) > 0 {
+		days = strings.Join(q.Days, ",")
+	}
+	if q.Start == q.End {
+		return days
+	}
+	return fmt.Sprintf("%s %s-%s", days, q.Start, q.End)
+}
+
+func (c PauseCondition) rule() Rule {
+	return Rule{Action: ActionExclude, Field: c.Field, Match: c.Match, Pattern: c.Pattern}
+}
+
+func (c PauseCondition) label() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return fmt.Sprintf("%s %s %q", c.Field, c.Match, c.Pattern)
+}
+
+// parseClock turns "HH:MM" into minutes after midnight; "" is midnight.
+func parseClock(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (a AutoPause) validate(bad func(field, format string, args ...any)) {
+	for i, q := range a.Schedules {
+		field := fmt.Sprintf("autoPause.schedules[%d]", i)
+		for j, d := range q.Days {
+			if _, ok := weekdays[strings.ToLower(d)]; !ok {
+				bad(fmt.Sprintf("%s.days[%d]", field, j), "must be one of mon, tue, wed, thu, fri, sat, sun")
+			}
+		}
+		if _, err := parseClock(q.Start); err != nil {
+			bad(field+".start", "must be a time like \"18:00\"")
+		}
+		if _, err := parseClock(q.End); err != nil {
+			bad(field+".end", "must be a time like \"09:00\"")
+		}
+	}
+	for i, c := range a.Conditions {
+		c.rule().validateMatcher(fmt.Sprintf("autoPause.conditions[%d]", i), bad)
+	}
+}
@@ -0,0 +1,109 @@
+package policy
+
+import (
+	"testing"
+	"time"
+)
+
+// at is clock on day of the week that starts on Sunday 2026-10-18, local
+// time; Saturday+1 is the Sunday after.
+func at(day time.Weekday, clock string) time.Time {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		panic(err)
+	}
+	return time.Date(2026, 10, 18+int(day), t.Hour(), t.Minute(), 0, 0, time.Local)
+}
+
+func TestQuietHoursActive(t *testing.T) {
+	night := QuietHours{Start: "22:00", End: "06:00"}
+	mondayNight := QuietHours{Days: []string{"mon"}, Start: "22:00", End: "06:00"}
+	weekend := QuietHours{Days: []string{"Sat", "sun"}, Start: "09:00", End: "09:00"}
+	allDay := QuietHours{}
+	friday := QuietHours{Days: []string{"fri"}, Start: "09:00", End: "17:00"}
+	saturdayNight := QuietHours{Days: []string{"sat"}, Start: "23:00", End: "01:00"}
+
+	cases := []struct {
+		name   string
+		q      QuietHours
+		now    time.Time
+		active bool
+	}{
+		{"before a night window", night, at(time.Monday, "21:59"), false},
+		{"night window starts", night, at(time.Monday, "22:00"), true},
+		{"night window before midnight", night, at(time.Monday, "23:59"), true},
+		{"night window at midnight", night, at(time.Tuesday, "00:00"), true},
+		{"night window last minute", night, at(time.Tuesday, "05:59"), true},
+		{"night window end is exclusive", night, at(time.Tuesday, "06:00"), false},
+		{"midday is outside a night window", night, at(time.Tuesday, "12:00"), false},
+
+		{"monday night on monday", mondayNight, at(time.Monday, "23:00"), true},
+		{"monday night continues on tuesday", mondayNight, at(time.Tuesday, "01:00"), true},
+		{"monday morning belongs to sunday", mondayNight, at(time.Monday, "01:00"), false},
+		{"tuesday night is not monday night", mondayNight, at(time.Tuesday, "23:00"), false},
+
+		{"start == end covers the day", weekend, at(time.Saturday, "00:00"), true},
+		{"start == end until the last minute", weekend, at(time.Sunday, "23:59"), true},
+		{"start == end only on its days", weekend, at(time.Monday, "00:00"), false},
+		{"friday before a start == end weekend", weekend, at(time.Friday, "23:59"), false},
+		{"no start or end is always", allDay, at(time.Wednesday, "13:37"), true},
+
+		{"same-day window before start", friday, at(time.Friday, "08:59"), false},
+		{"same-day window start", friday, at(time.Friday, "09:00"), true},
+		{"same-day window last minute", friday, at(time.Friday, "16:59"), true},
+		{"same-day window end", friday, at(time.Friday, "17:00"), false},
+		{"same-day window other day", friday, at(time.Thursday, "12:00"), false},
+
+		{"saturday night wraps into the next week", saturdayNight, at(time.Saturday, "23:30"), true},
+		{"saturday night ends on sunday", saturdayNight, at(time.Saturday+1, "00:30"), true},
+		{"saturday night is over", saturdayNight, at(time.Saturday+1, "01:00"), false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.q.active(c.now); got != c.active {
+				t.Fatalf("%+v at %s: active = %v", c.q, c.now.Format("Mon 15:04"), got)
+			}
+		})
+	}
+}
+
+func TestAutoPauseEvaluate(t *testing.T) {
+	ap := AutoPause{
+		Schedules: []QuietHours{
+			{Name: "sleep", Start: "23:00", End: "07:00"},
+			{Days: []string{"sun"}},
+		},
+		Conditions: []PauseCondition{
+			{Field: FieldExeName, Match: MatchExact, Pattern: "keepass.exe"},
+			{Name: "private browsing", Field: FieldWindowTitle, Match: MatchContains, Pattern: "InPrivate"},
+		},
+	}
+	keepass := &Target{ExePath: `C:\Program Files\KeePass\KeePass.exe`}
+	private := &Target{ExePath: `C:\Edge\msedge.exe`, WindowTitle: "News - InPrivate - Edge"}
+	editor := &Target{ExePath: "/usr/bin/nvim"}
+
+	cases := []struct {
+		name   string
+		now    time.Time
+		fg     *Target
+		paused bool
+		reason string
+	}{
+		{"working hours", at(time.Monday, "10:00"), editor, false, ""},
+		{"unknown foreground", at(time.Monday, "10:00"), nil, false, ""},
+		{"named schedule", at(time.Monday, "23:30"), editor, true, "quiet hours: sleep"},
+		{"schedule without foreground", at(time.Tuesday, "06:59"), nil, true, "quiet hours: sleep"},
+		{"unnamed schedule", at(time.Sunday, "12:00"), nil, true, "quiet hours: sun"},
+		{"schedule wins over a condition", at(time.Monday, "23:30"), keepass, true, "quiet hours: sleep"},
+		{"unnamed condition", at(time.Monday, "10:00"), keepass, true, `condition: exeName exact "keepass.exe"`},
+		{"named condition", at(time.Monday, "10:00"), private, true, "condition: private browsing"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			paused, reason := ap.Evaluate(c.now, c.fg)
+			if paused != c.paused || reason != c.reason {
+				t.Fatalf("Evaluate = %v, %q; want %v, %q", paused, reason, c.paused, c.reason)
+			}
+		})
+	}
+}
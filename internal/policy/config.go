@@ -1,55 +1,109 @@
 package policy
 
 import (
-	"os"
+	"encoding/json"
 	"path/filepath"
 	"strings"
 	"time"
 )
 
 type Config struct {
-	Retention      time.Duration
-	StorageDir     string
-	ResourceLimits ResourceLimits
-	Rules          Rules
+	Version        int             `json:"version"`
+	Retention      Duration        `json:"retention"`
+	StorageDir     string          `json:"storageDir"`
+	ResourceLimits ResourceLimits  `json:"resourceLimits"`
+	Rules          Rules           `json:"rules"`
+	Clipboard      ClipboardPolicy `json:"clipboard"`
+	Throttling     Throttling      `json:"throttling"`
+	Plugins        map[string]bool `json:"plugins"` // plugin ID -> enabled; missing means enabled
+	Overrides      []AppOverride   `json:"overrides"`
+	AutoPause      AutoPause       `json:"autoPause"`
+	Redaction      Redaction       `json:"redaction"`
+	Restore        RestorePolicy   `json:"restore"`
+
+	// Profiles are named partial configs; the active one is laid over the
+	// settings above. See Resolved.
+	Profiles      map[string]json.RawMessage `json:"profiles,omitempty"`
+	ActiveProfile string                     `json:"activeProfile,omitempty"`
 }
 
 type ResourceLimits struct {
-	MaxRAMBytes        int64
-	MaxDiskBytes       int64
-	MaxSnapshotsPerApp int
+	MaxRAMBytes        int64 `json:"maxRAMBytes"`
+	MaxDiskBytes       int64 `json:"maxDiskBytes"`
+	MaxSnapshotsPerApp int   `json:"maxSnapshotsPerApp"`
 }
 
-type Rules struct {
-	ExcludeExeNames  []string
-	ExcludePathSubstr []string
-	ExcludeWindowClasses []string
+// Throttling decides how often the foreground app is captured and which
+// changes are significant enough to become a snapshot. Each change in a delta
+// adds its weight to the score; the score must reach Threshold, and
+// BurstThreshold when the previous snapshot is younger than the app's
+// minimum interval.
+//
+// Window moves of one window are merged until it was still for
+// MoveQuietPeriod; the settled position is then captured even inside
+// CaptureMinInterval. 0 captures every move the interval lets through.
+//
+// ProcessScanInterval is how often /proc is scanned for started and exited
+// processes on Linux.
+type Throttling struct {
+	CaptureMinInterval  Duration            `json:"captureMinInterval"`
+	MoveQuietPeriod     Duration            `json:"moveQuietPeriod"`
+	ProcessScanInterval Duration            `json:"processScanInterval"`
+	SnapshotMinInterval Duration            `json:"snapshotMinInterval"`
+	AppMinIntervals     map[string]Duration `json:"appMinIntervals"` // keyed by appID or exe name
+	Threshold           float64             `json:"threshold"`
+	BurstThreshold      float64             `json:"burstThreshold"`
+	Weights             SignificanceWeights `json:"weights"`
 }
 
-func (r Rules) Allow(exePath string, windowClass string) bool {
-	exeLower := strings.ToLower(filepath.Base(exePath))
-	pathLower := strings.ToLower(exePath)
-	classLower := strings.ToLower(windowClass)
+type SignificanceWeights struct {
+	WindowMoved          float64 `json:"windowMoved"`
+	MovePixels           int32   `json:"movePixels"`
+	WindowOpened         float64 `json:"windowOpened"`
+	WindowClosed         float64 `json:"windowClosed"`
+	WindowStateChanged   float64 `json:"windowStateChanged"`
+	FocusChanged         float64 `json:"focusChanged"`
+	FileChanged          float64 `json:"fileChanged"`
+	PluginChanged        float64 `json:"pluginChanged"`
+	ClipboardChanged     float64 `json:"clipboardChanged"`
+	InputLanguageChanged float64 `json:"inputLanguageChanged"`
+	TitleChanged         float64 `json:"titleChanged"`
+}
 
-	for _, n := range r.ExcludeExeNames {
-		if exeLower == strings.ToLower(n) {
-			return false
-		}
+// RestorePolicy tunes restores. FileOpener is the command that reopens the
+// files of a snapshot on Linux: {file} stands for the path and {exe} for the
+// app's executable; without {file} the path is appended.
+type RestorePolicy struct {
+	FileOpener []string `json:"fileOpener"`
+}
+
+// ClipboardPolicy controls the opt-in clipboard vault. When the vault is off
+// only a hash of the clipboard text is kept.
+type ClipboardPolicy struct {
+	VaultEnabled    bool     `json:"vaultEnabled"`
+	MaxTextBytes    int      `json:"maxTextBytes"`
+	ExcludeExeNames []string `json:"excludeExeNames"`
+}
+
+// KeepText reports whether clipboard text may be stored. exePaths are the
+// apps involved, i.e. the one the text came from and the foreground one; the
+// text is dropped if any of them is excluded.
+func (c ClipboardPolicy) KeepText(text string, exePaths ...string) bool {
+	if !c.VaultEnabled || text == "" {
+		return false
 	}
-	for _, s := range r.ExcludePathSubstr {
-		if s == "" {
-			continue
-		}
-		if strings.Contains(pathLower, strings.ToLower(s)) {
-			return false
-		}
+	if c.MaxTextBytes > 0 && len(text) > c.MaxTextBytes {
+		return false
 	}
-	for _, c := range r.ExcludeWindowClasses {
-		if c == "" {
+	for _, exePath := range exePaths {
+		if exePath == "" {
 			continue
 		}
-		if classLower == strings.ToLower(c) {
-			return false
+		exeLower := strings.ToLower(filepath.Base(exePath))
+		for _, n := range c.ExcludeExeNames {
+			if exeLower == strings.ToLower(n) {
+				return false
+			}
 		}
 	}
 	return true
@@ -58,29 +112,55 @@ func (r Rules) Allow(exePath string, windowClass string) bool {
 func DefaultConfig() *Config {
 	base := defaultStorageDir()
 	return &Config{
-		Retention: 24 * time.Hour,
+		Version:    ConfigVersion,
+		Retention:  Duration(24 * time.Hour),
 		StorageDir: base,
 		ResourceLimits: ResourceLimits{
-			MaxRAMBytes:        256 * 1024 * 1024,   // 256MB in-memory target
+			MaxRAMBytes:        256 * 1024 * 1024,      // 256MB in-memory target
 			MaxDiskBytes:       2 * 1024 * 1024 * 1024, // 2GB spillover
 			MaxSnapshotsPerApp: 500,
 		},
 		Rules: Rules{
-			ExcludeExeNames:       []string{"keepass.exe"},
-			ExcludePathSubstr:     []string{`\\AppData\\Local\\Temp\\`},
-			ExcludeWindowClasses:  []string{},
+			Mode: ModeTrackAll,
+			Items: []Rule{
+				{Action: ActionExclude, Field: FieldExeName, Match: MatchExact, Pattern: "keepass.exe"},
+				{Action: ActionExclude, Field: FieldExePath, Match: MatchGlob, Pattern: `*\AppData\Local\Temp\*`},
+			},
+		},
+		Clipboard: ClipboardPolicy{
+			VaultEnabled:    false,
+			MaxTextBytes:    32 * 1024,
+			ExcludeExeNames: []string{"keepass.exe", "1password.exe", "bitwarden.exe"},
+		},
+		Plugins:   map[string]bool{},
+		Overrides: []AppOverride{},
+		AutoPause: AutoPause{Schedules: []QuietHours{}, Conditions: []PauseCondition{}},
+		Redaction: Redaction{
+			Enabled:   true,
+			Detectors: []string{DetectorEmail, DetectorURLToken, DetectorSecret},
+			Rules:     []ScrubRule{},
+		},
+		Restore: RestorePolicy{FileOpener: []string{"xdg-open", "{file}"}},
+		Throttling: Throttling{
+			CaptureMinInterval:  Duration(500 * time.Millisecond),
+			MoveQuietPeriod:     Duration(250 * time.Millisecond),
+			ProcessScanInterval: Duration(2 * time.Second),
+			SnapshotMinInterval: Duration(2 * time.Second),
+			AppMinIntervals:     map[string]Duration{},
+			Threshold:           1,
+			BurstThreshold:      4,
+			Weights: SignificanceWeights{
+				WindowMoved:          1,
+				MovePixels:           16,
+				WindowOpened:         4,
+				WindowClosed:         4,
+				WindowStateChanged:   2,
+				FocusChanged:         1,
+				FileChanged:          4,
+				PluginChanged:        1,
+				ClipboardChanged:     2,
+				InputLanguageChanged: 1,
+			},
 		},
 	}
 }
-
-func defaultStorageDir() string {
-	// %LOCALAPPDATA%\Rewinder2\Snapshots
-	if v := os.Getenv("LOCALAPPDATA"); v != "" {
-		return filepath.Join(v, "Rewinder2", "Snapshots")
-	}
-	if v := os.Getenv("APPDATA"); v != "" {
-		return filepath.Join(v, "Rewinder2", "Snapshots")
-	}
-	return filepath.Join(".", "snapshots")
-}
-
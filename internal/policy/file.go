@@ -0,0 +1,357 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	ConfigVersion  = 2
+	ConfigFileName = "config.json"
+)
+
+// Duration is a time.Duration written as a string such as "500ms" or "24h"
+// in the config file.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return errors.New("duration must be a string like \"30s\"")
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", s)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// FieldError points at one invalid value by its JSON path, e.g.
+// "throttling.weights.windowMoved".
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		if fe.Field == "" {
+			parts = append(parts, fe.Message)
+		} else {
+			parts = append(parts, fe.Field+": "+fe.Message)
+		}
+	}
+	return "invalid config: " + strings.Join(parts, "; ")
+}
+
+// Load reads the config file at path. Settings missing from the file keep
+// their defaults; a missing file is created with the defaults. The result is
+// validated, and a *ValidationError lists every invalid field.
+func Load(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		cfg := DefaultConfig()
+		return cfg, Save(path, cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	cfg, migrated, err := parse(b)
+	if err == nil && migrated {
+		_ = Save(path, cfg)
+	}
+	return cfg, err
+}
+
+// Parse decodes and validates a config document. Fields are checked against
+// the Config layout first so unknown keys and wrong types are reported with
+// their full path.
+func Parse(b []byte) (*Config, error) {
+	cfg, _, err := parse(b)
+	return cfg, err
+}
+
+func parse(b []byte) (*Config, bool, error) {
+	var raw any
+	if err := json.Unmarshal(b, &raw); err != nil {
+		var se *json.SyntaxError
+		if errors.As(err, &se) {
+			err = fmt.Errorf("syntax error at offset %d: %v", se.Offset, err)
+		}
+		return nil, false, &ValidationError{Errors: []FieldError{{Message: err.Error()}}}
+	}
+	if _, ok := raw.(map[string]any); !ok {
+		return nil, false, &ValidationError{Errors: []FieldError{{Message: "config must be a JSON object"}}}
+	}
+	if errs := checkShape("", raw, reflect.TypeOf(Config{})); len(errs) > 0 {
+		return nil, false, &ValidationError{Errors: errs}
+	}
+
+	cfg := DefaultConfig()
+	cfg.Version = 0
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return nil, false, &ValidationError{Errors: []FieldError{{Message: strings.TrimPrefix(err.Error(), "json: ")}}}
+	}
+	migrated := cfg.Migrate()
+	if err := cfg.Validate(); err != nil {
+		return nil, false, err
+	}
+	return cfg, migrated, nil
+}
+
+// Migrate upgrades a config of an older version in place and reports whether
+// anything changed. A missing version counts as the current one.
+func (c *Config) Migrate() bool {
+	changed := false
+	if c.Version == 0 {
+		c.Version = ConfigVersion
+	}
+	r := &c.Rules
+	if r.ExcludeExeNames != nil || r.ExcludePathSubstr != nil || r.ExcludeWindowClasses != nil {
+		r.migrateLegacy()
+		changed = true
+	}
+	if c.Version < ConfigVersion {
+		c.Version = ConfigVersion
+		changed = true
+	}
+	return changed
+}
+
+// Save writes cfg to path atomically.
+func Save(path string, cfg *Config) error {
+	b, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+var durationType = reflect.TypeOf(Duration(0))
+
+// checkShape compares a generic JSON value with the Go type it will be
+// decoded into.
+func checkShape(path string, v any, t reflect.Type) []FieldError {
+	join := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+	if v == nil {
+		return nil
+	}
+	if t == rawMessageType {
+		return checkOverlay(path, v)
+	}
+	if t == durationType {
+		s, ok := v.(string)
+		if !ok {
+			return []FieldError{{Field: path, Message: `expected a duration string like "30s"`}}
+		}
+		if _, err := time.ParseDuration(s); err != nil {
+			return []FieldError{{Field: path, Message: fmt.Sprintf("invalid duration %q", s)}}
+		}
+		return nil
+	}
+
+	var errs []FieldError
+	switch t.Kind() {
+	case reflect.Pointer:
+		return checkShape(path, v, t.Elem())
+	case reflect.Struct:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return []FieldError{{Field: path, Message: "expected an object"}}
+		}
+		fields := map[string]reflect.Type{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if name, _, _ := strings.Cut(f.Tag.Get("json"), ","); name != "" && name != "-" {
+				fields[name] = f.Type
+			}
+		}
+		for _, k := range sortedKeys(obj) {
+			ft, ok := fields[k]
+			if !ok {
+				errs = append(errs, FieldError{Field: join(k), Message: "unknown field"})
+				continue
+			}
+			errs = append(errs, checkShape(join(k), obj[k], ft)...)
+		}
+	case reflect.Map:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return []FieldError{{Field: path, Message: "expected an object"}}
+		}
+		for _, k := range sortedKeys(obj) {
+			errs = append(errs, checkShape(join(k), obj[k], t.Elem())...)
+		}
+	case reflect.Slice:
+		arr, ok := v.([]any)
+		if !ok {
+			return []FieldError{{Field: path, Message: "expected an array"}}
+		}
+		for i, x := range arr {
+			errs = append(errs, checkShape(fmt.Sprintf("%s[%d]", path, i), x, t.Elem())...)
+		}
+	case reflect.String:
+		if _, ok := v.(string); !ok {
+			errs = append(errs, FieldError{Field: path, Message: "expected a string"})
+		}
+	case reflect.Bool:
+		if _, ok := v.(bool); !ok {
+			errs = append(errs, FieldError{Field: path, Message: "expected true or false"})
+		}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		if n, ok := v.(float64); !ok || n != float64(int64(n)) {
+			errs = append(errs, FieldError{Field: path, Message: "expected an integer"})
+		}
+	case reflect.Float64:
+		if _, ok := v.(float64); !ok {
+			errs = append(errs, FieldError{Field: path, Message: "expected a number"})
+		}
+	}
+	return errs
+}
+
+func sortedKeys(m map[string]any) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Validate checks every field and reports all problems at once.
+func (c *Config) Validate() error {
+	var errs []FieldError
+	bad := func(field, format string, args ...any) {
+		errs = append(errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if c.Version > ConfigVersion {
+		bad("version", "unsupported version %d (max %d)", c.Version, ConfigVersion)
+	}
+	if time.Duration(c.Retention) < time.Minute {
+		bad("retention", "must be at least 1m")
+	}
+	if c.StorageDir == "" {
+		bad("storageDir", "must not be empty")
+	}
+
+	l := c.ResourceLimits
+	if l.MaxRAMBytes < 0 {
+		bad("resourceLimits.maxRAMBytes", "must not be negative")
+	}
+	if l.MaxDiskBytes < 0 {
+		bad("resourceLimits.maxDiskBytes", "must not be negative")
+	}
+	if l.MaxSnapshotsPerApp < 1 || l.MaxSnapshotsPerApp > 100000 {
+		bad("resourceLimits.maxSnapshotsPerApp", "must be between 1 and 100000")
+	}
+
+	checkList := func(field string, list []string) {
+		for i, v := range list {
+			if strings.TrimSpace(v) == "" {
+				bad(fmt.Sprintf("%s[%d]", field, i), "must not be empty")
+			}
+		}
+	}
+	c.Rules.validate(bad)
+
+	if c.Clipboard.MaxTextBytes < 0 || c.Clipboard.MaxTextBytes > 1<<20 {
+		bad("clipboard.maxTextBytes", "must be between 0 and 1048576")
+	}
+	checkList("clipboard.excludeExeNames", c.Clipboard.ExcludeExeNames)
+
+	t := c.Throttling
+	if t.CaptureMinInterval < 0 {
+		bad("throttling.captureMinInterval", "must not be negative")
+	}
+	if t.MoveQuietPeriod < 0 {
+		bad("throttling.moveQuietPeriod", "must not be negative")
+	}
+	if t.ProcessScanInterval < Duration(100*time.Millisecond) {
+		bad("throttling.processScanInterval", "must be at least 100ms")
+	}
+	if t.SnapshotMinInterval < 0 {
+		bad("throttling.snapshotMinInterval", "must not be negative")
+	}
+	for k, v := range t.AppMinIntervals {
+		if strings.TrimSpace(k) == "" {
+			bad("throttling.appMinIntervals", "app key must not be empty")
+		}
+		if v < 0 {
+			bad("throttling.appMinIntervals."+k, "must not be negative")
+		}
+	}
+	if t.Threshold < 0 {
+		bad("throttling.threshold", "must not be negative")
+	}
+	if t.BurstThreshold < t.Threshold {
+		bad("throttling.burstThreshold", "must not be below threshold")
+	}
+	w := t.Weights
+	for name, v := range map[string]float64{
+		"windowMoved":          w.WindowMoved,
+		"windowOpened":         w.WindowOpened,
+		"windowClosed":         w.WindowClosed,
+		"windowStateChanged":   w.WindowStateChanged,
+		"focusChanged":         w.FocusChanged,
+		"fileChanged":          w.FileChanged,
+		"pluginChanged":        w.PluginChanged,
+		"clipboardChanged":     w.ClipboardChanged,
+		"inputLanguageChanged": w.InputLanguageChanged,
+		"titleChanged":         w.TitleChanged,
+	} {
+		if v < 0 {
+			bad("throttling.weights."+name, "must not be negative")
+		}
+	}
+	if w.MovePixels < 0 {
+		bad("throttling.weights.movePixels", "must not be negative")
+	}
+
+	validateOverrides(c.Overrides, bad)
+	c.AutoPause.validate(bad)
+	c.Redaction.validate(bad)
+	if len(c.Restore.FileOpener) == 0 || strings.TrimSpace(c.Restore.FileOpener[0]) == "" {
+		bad("restore.fileOpener", "must name a command")
+	}
+	c.validateProfiles(bad)
+
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return &ValidationError{Errors: errs}
+}
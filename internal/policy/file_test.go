@@ -0,0 +1,157 @@
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const v1Config = `{
+  "version": 1,
+  "rules": {
+    "excludeExeNames": ["KeePass.exe", " "],
+    "excludePathSubstr": ["\\AppData\\Local\\Temp\\"],
+    "excludeWindowClasses": ["Shell_TrayWnd"]
+  }
+}`
+
+func TestMigrate(t *testing.T) {
+	cases := []struct {
+		name     string
+		doc      string
+		migrated bool
+		items    []Rule
+	}{
+		{
+			name:     "version 1 exclude lists",
+			doc:      v1Config,
+			migrated: true,
+			items: []Rule{
+				{Action: ActionExclude, Field: FieldExeName, Match: MatchExact, Pattern: "KeePass.exe"},
+				{Action: ActionExclude, Field: FieldExePath, Match: MatchContains, Pattern: `\AppData\Local\Temp\`},
+				{Action: ActionExclude, Field: FieldWindowClass, Match: MatchExact, Pattern: "Shell_TrayWnd"},
+			},
+		},
+		{
+			name:     "version 1 without exclude lists",
+			doc:      `{"version": 1}`,
+			migrated: true,
+			items:    DefaultConfig().Rules.Items,
+		},
+		{
+			name:     "empty legacy lists replace the default rules",
+			doc:      `{"version": 1, "rules": {"excludeExeNames": []}}`,
+			migrated: true,
+			items:    nil,
+		},
+		{
+			name:     "current version",
+			doc:      `{"version": 2, "rules": {"mode": "allowlist", "items": [{"action": "include", "field": "exeName", "match": "glob", "pattern": "code*"}]}}`,
+			migrated: false,
+			items:    []Rule{{Action: ActionInclude, Field: FieldExeName, Match: MatchGlob, Pattern: "code*"}},
+		},
+		{
+			name:     "missing version counts as current",
+			doc:      `{}`,
+			migrated: false,
+			items:    DefaultConfig().Rules.Items,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg, migrated, err := parse([]byte(c.doc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if migrated != c.migrated {
+				t.Errorf("migrated = %v, want %v", migrated, c.migrated)
+			}
+			if cfg.Version != ConfigVersion {
+				t.Errorf("version = %d, want %d", cfg.Version, ConfigVersion)
+			}
+			if len(cfg.Rules.Items) != 0 || len(c.items) != 0 {
+				if !reflect.DeepEqual(cfg.Rules.Items, c.items) {
+					t.Errorf("items = %+v, want %+v", cfg.Rules.Items, c.items)
+				}
+			}
+			r := cfg.Rules
+			if r.ExcludeExeNames != nil || r.ExcludePathSubstr != nil || r.ExcludeWindowClasses != nil {
+				t.Errorf("legacy lists kept: %+v", r)
+			}
+		})
+	}
+}
+
+func TestMigratedRulesKeepBehavior(t *testing.T) {
+	cfg, err := Parse([]byte(v1Config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Rules.Mode != ModeTrackAll {
+		t.Errorf("mode = %q", cfg.Rules.Mode)
+	}
+	cases := []struct {
+		t       Target
+		tracked bool
+	}{
+		{Target{ExePath: `C:\Apps\keepass.exe`}, false},
+		{Target{ExePath: `C:\Users\me\AppData\Local\Temp\x\setup.exe`}, false},
+		{Target{ExePath: `C:\Windows\explorer.exe`, WindowClass: "Shell_TrayWnd"}, false},
+		{Target{ExePath: `C:\Windows\explorer.exe`, WindowClass: "CabinetWClass"}, true},
+	}
+	for _, c := range cases {
+		if got := cfg.Rules.Allow(c.t); got != c.tracked {
+			t.Errorf("Allow(%+v) = %v, want %v", c.t, got, c.tracked)
+		}
+	}
+}
+
+func TestLoadSavesMigratedConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ConfigFileName)
+	if err := os.WriteFile(path, []byte(v1Config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Version int            `json:"version"`
+		Rules   map[string]any `json:"rules"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Version != ConfigVersion {
+		t.Errorf("saved version %d", doc.Version)
+	}
+	for _, k := range []string{"excludeExeNames", "excludePathSubstr", "excludeWindowClasses"} {
+		if _, ok := doc.Rules[k]; ok {
+			t.Errorf("legacy %s still on disk", k)
+		}
+	}
+	cfg, migrated, err := parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated {
+		t.Error("saved config migrated again")
+	}
+	if len(cfg.Rules.Items) != 3 {
+		t.Errorf("%d rule items after reload, want 3", len(cfg.Rules.Items))
+	}
+}
+
+func TestUnsupportedVersion(t *testing.T) {
+	_, err := Parse([]byte(`{"version": 99}`))
+	var ve *ValidationError
+	if !errors.As(err, &ve) || len(ve.Errors) == 0 || ve.Errors[0].Field != "version" {
+		t.Fatalf("Parse = %v, want a version error", err)
+	}
+}
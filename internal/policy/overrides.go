@@ -0,0 +1,137 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AppOverride replaces global settings for the apps it matches. Match is an
+// appID or exe name, or a glob (with * or ?) over the exe name or path. Unset
+// fields keep the global value; when several overrides match, later ones win.
+type AppOverride struct {
+	Match               string          `json:"match"`
+	Retention           *Duration       `json:"retention,omitempty"`
+	MaxSnapshotsPerApp  *int            `json:"maxSnapshotsPerApp,omitempty"`
+	CaptureMinInterval  *Duration       `json:"captureMinInterval,omitempty"`
+	SnapshotMinInterval *Duration       `json:"snapshotMinInterval,omitempty"`
+	Threshold           *float64        `json:"threshold,omitempty"`
+	BurstThreshold      *float64        `json:"burstThreshold,omitempty"`
+	Plugins             map[string]bool `json:"plugins,omitempty"`
+}
+
+// Effective is the policy that applies to one app after overrides.
+type Effective struct {
+	Retention           time.Duration
+	MaxSnapshotsPerApp  int
+	CaptureMinInterval  time.Duration
+	SnapshotMinInterval time.Duration
+	Threshold           float64
+	BurstThreshold      float64
+	Plugins             map[string]bool // plugin ID -> enabled; missing means enabled
+	Overrides           []string        // Match of every override applied
+}
+
+func (e Effective) PluginEnabled(id string) bool {
+	on, ok := e.Plugins[id]
+	return !ok || on
+}
+
+func (o AppOverride) matches(appID, exePath string) bool {
+	name := exePath[strings.LastIndexAny(exePath, `/\`)+1:]
+	if strings.ContainsAny(o.Match, "*?") {
+		r := Rule{Match: MatchGlob, Pattern: o.Match}
+		re, err := r.compile()
+		return err == nil && (re.MatchString(name) || re.MatchString(exePath))
+	}
+	return strings.EqualFold(o.Match, appID) || (name != "" && strings.EqualFold(o.Match, name))
+}
+
+// EffectiveFor merges the overrides matching appID or exePath into the global
+// settings.
+func (c *Config) EffectiveFor(appID, exePath string) Effective {
+	t := c.Throttling
+	eff := Effective{
+		Retention:           time.Duration(c.Retention),
+		MaxSnapshotsPerApp:  c.ResourceLimits.MaxSnapshotsPerApp,
+		CaptureMinInterval:  time.Duration(t.CaptureMinInterval),
+		SnapshotMinInterval: time.Duration(t.SnapshotMinInterval),
+		Threshold:           t.Threshold,
+		BurstThreshold:      t.BurstThreshold,
+		Plugins:             map[string]bool{},
+	}
+	for k, v := range c.Plugins {
+		eff.Plugins[k] = v
+	}
+	for _, o := range c.Overrides {
+		if !o.matches(appID, exePath) {
+			continue
+		}
+		if o.Retention != nil {
+			eff.Retention = time.Duration(*o.Retention)
+		}
+		if o.MaxSnapshotsPerApp != nil {
+			eff.MaxSnapshotsPerApp = *o.MaxSnapshotsPerApp
+		}
+		if o.CaptureMinInterval != nil {
+			eff.CaptureMinInterval = time.Duration(*o.CaptureMinInterval)
+		}
+		if o.SnapshotMinInterval != nil {
+			eff.SnapshotMinInterval = time.Duration(*o.SnapshotMinInterval)
+		}
+		if o.Threshold != nil {
+			eff.Threshold = *o.Threshold
+		}
+		if o.BurstThreshold != nil {
+			eff.BurstThreshold = *o.BurstThreshold
+		}
+		for k, v := range o.Plugins {
+			eff.Plugins[k] = v
+		}
+		eff.Overrides = append(eff.Overrides, o.Match)
+	}
+	return eff
+}
+
+// MinCaptureInterval is the shortest capture interval of any app, so the
+// capture loop can gate on it before it knows which app is in front.
+func (c *Config) MinCaptureInterval() time.Duration {
+	min := c.Throttling.CaptureMinInterval
+	for _, o := range c.Overrides {
+		if o.CaptureMinInterval != nil && *o.CaptureMinInterval < min {
+			min = *o.CaptureMinInterval
+		}
+	}
+	return time.Duration(min)
+}
+
+func validateOverrides(list []AppOverride, bad func(field, format string, args ...any)) {
+	for i, o := range list {
+		field := fmt.Sprintf("overrides[%d]", i)
+		if strings.TrimSpace(o.Match) == "" {
+			bad(field+".match", "must not be empty")
+		} else if strings.ContainsAny(o.Match, "*?") {
+			if _, err := (Rule{Match: MatchGlob, Pattern: o.Match}).compile(); err != nil {
+				bad(field+".match", "invalid glob: %v", err)
+			}
+		}
+		if o.Retention != nil && time.Duration(*o.Retention) < time.Minute {
+			bad(field+".retention", "must be at least 1m")
+		}
+		if o.MaxSnapshotsPerApp != nil && (*o.MaxSnapshotsPerApp < 1 || *o.MaxSnapshotsPerApp > 100000) {
+			bad(field+".maxSnapshotsPerApp", "must be between 1 and 100000")
+		}
+		if o.CaptureMinInterval != nil && *o.CaptureMinInterval < 0 {
+			bad(field+".captureMinInterval", "must not be negative")
+		}
+		if o.SnapshotMinInterval != nil && *o.SnapshotMinInterval < 0 {
+			bad(field+".snapshotMinInterval", "must not be negative")
+		}
+		if o.Threshold != nil && *o.Threshold < 0 {
+			bad(field+".threshold", "must not be negative")
+		}
+		if o.BurstThreshold != nil && *o.BurstThreshold < 0 {
+			bad(field+".burstThreshold", "must not be negative")
+		}
+	}
+}
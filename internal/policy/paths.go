@@ -0,0 +1,91 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// Environment settings, mostly overrides of the default locations. The flags
+// -home, -config, -lock and -record set the same variables.
+const (
+	EnvHome       = "REWINDER_HOME"   // holds config.json and the snapshot store
+	EnvConfigPath = "REWINDER_CONFIG" // config file, wins over REWINDER_HOME
+	EnvLockMode   = "REWINDER_LOCK"   // LockReadOnly or LockFail
+	EnvRecord     = "REWINDER_RECORD" // record the session to this file for replay
+)
+
+// What a second instance does when the store is locked by another one.
+const (
+	LockReadOnly = "readonly" // open the store read-only and do not capture
+	LockFail     = "fail"     // refuse to start
+)
+
+// DefaultConfigPath is REWINDER_CONFIG, or config.json in the platform config
+// dir: %LOCALAPPDATA%\Rewinder2 on Windows, $XDG_CONFIG_HOME/rewinder on
+// Linux.
+func DefaultConfigPath() string {
+	if v := os.Getenv(EnvConfigPath); v != "" {
+		return v
+	}
+	return filepath.Join(appDir(xdgConfig), ConfigFileName)
+}
+
+// LockMode returns REWINDER_LOCK, LockReadOnly when it is unset.
+func LockMode() string {
+	if os.Getenv(EnvLockMode) == LockFail {
+		return LockFail
+	}
+	return LockReadOnly
+}
+
+func defaultStorageDir() string {
+	if runtime.GOOS == "windows" {
+		// %LOCALAPPDATA%\Rewinder2\Snapshots
+		return filepath.Join(appDir(xdgData), "Snapshots")
+	}
+	return filepath.Join(appDir(xdgData), "snapshots")
+}
+
+type xdgKind int
+
+const (
+	xdgConfig xdgKind = iota
+	xdgData
+)
+
+// appDir is the per-user directory of the app. Windows and macOS keep config
+// and data together; elsewhere they follow the XDG base directory spec.
+// Without any usable location it is the working directory.
+func appDir(kind xdgKind) string {
+	if v := os.Getenv(EnvHome); v != "" {
+		return v
+	}
+	switch runtime.GOOS {
+	case "windows":
+		if v := os.Getenv("LOCALAPPDATA"); v != "" {
+			return filepath.Join(v, "Rewinder2")
+		}
+		if v := os.Getenv("APPDATA"); v != "" {
+			return filepath.Join(v, "Rewinder2")
+		}
+		return "."
+	case "darwin":
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, "Library", "Application Support", "Rewinder2")
+		}
+		return "."
+	}
+	env, rel := "XDG_CONFIG_HOME", ".config"
+	if kind == xdgData {
+		env, rel = "XDG_DATA_HOME", filepath.Join(".local", "share")
+	}
+	// The spec says relative values are invalid and must be ignored.
+	if v := os.Getenv(env); filepath.IsAbs(v) {
+		return filepath.Join(v, "rewinder")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, rel, "rewinder")
+	}
+	return "."
+}
@@ -0,0 +1,136 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// A profile is a partial config document laid over the rest of the file
+// while it is active, e.g. {"retention": "1h", "rules": {"mode": "allowlist"}}.
+// Objects merge key by key; lists and plain values replace the base value.
+// Profiles cannot set version, profiles or activeProfile.
+
+var rawMessageType = reflect.TypeOf(json.RawMessage{})
+
+// ProfileNames lists the profiles of c in name order.
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolved returns the settings in effect: c with its active profile applied.
+// Without an active profile it returns c itself.
+func (c *Config) Resolved() (*Config, error) {
+	if c.ActiveProfile == "" {
+		return c, nil
+	}
+	return c.WithProfile(c.ActiveProfile)
+}
+
+// WithProfile returns a copy of c with the profile name applied. The copy
+// keeps the profiles of c and has name as its active profile.
+func (c *Config) WithProfile(name string) (*Config, error) {
+	overlay, ok := c.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %q", name)
+	}
+	base, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	var doc, over map[string]any
+	if err := json.Unmarshal(base, &doc); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(overlay, &over); err != nil {
+		return nil, fmt.Errorf("profile %q: %v", name, err)
+	}
+	mergeJSON(doc, over)
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	out := &Config{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(out); err != nil {
+		return nil, fmt.Errorf("profile %q: %s", name, strings.TrimPrefix(err.Error(), "json: "))
+	}
+	out.ActiveProfile = name
+	return out, nil
+}
+
+func mergeJSON(dst, src map[string]any) {
+	for k, v := range src {
+		sub, ok := v.(map[string]any)
+		if cur, isObj := dst[k].(map[string]any); ok && isObj {
+			mergeJSON(cur, sub)
+			continue
+		}
+		dst[k] = v
+	}
+}
+
+// checkOverlay checks the shape of a profile document against Config.
+func checkOverlay(path string, v any) []FieldError {
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return []FieldError{{Field: path, Message: "expected an object"}}
+	}
+	var errs []FieldError
+	for _, k := range []string{"version", "profiles", "activeProfile"} {
+		if _, ok := obj[k]; ok {
+			errs = append(errs, FieldError{Field: path + "." + k, Message: "cannot be set by a profile"})
+		}
+	}
+	return append(errs, checkShape(path, v, reflect.TypeOf(Config{}))...)
+}
+
+func (c *Config) validateProfiles(bad func(field, format string, args ...any)) {
+	if c.ActiveProfile != "" {
+		if _, ok := c.Profiles[c.ActiveProfile]; !ok {
+			bad("activeProfile", "unknown profile %q", c.ActiveProfile)
+		}
+	}
+	for _, name := range c.ProfileNames() {
+		field := "profiles." + name
+		if strings.TrimSpace(name) == "" {
+			bad("profiles", "profile name must not be empty")
+			continue
+		}
+		var v any
+		if err := json.Unmarshal(c.Profiles[name], &v); err != nil {
+			bad(field, "invalid JSON: %v", err)
+			continue
+		}
+		if errs := checkOverlay(field, v); len(errs) > 0 {
+			for _, fe := range errs {
+				bad(fe.Field, "%s", fe.Message)
+			}
+			continue
+		}
+		r, err := c.WithProfile(name)
+		if err != nil {
+			bad(field, "%v", err)
+			continue
+		}
+		r.Profiles, r.ActiveProfile = nil, ""
+		if verr, ok := r.Validate().(*ValidationError); ok {
+			for _, fe := range verr.Errors {
+				f := field
+				if fe.Field != "" {
+					f += "." + fe.Field
+				}
+				bad(f, "%s", fe.Message)
+			}
+		}
+	}
+}
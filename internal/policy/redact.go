@@ -0,0 +1,244 @@
+package policy
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"Rewinder/internal/state"
+)
+
+const (
+	DetectorEmail    = "email"
+	DetectorURLToken = "urlToken"
+	DetectorSecret   = "secret"
+
+	RedactWindowTitle = "windowTitle"
+	RedactCommandLine = "commandLine"
+	RedactPluginData  = "pluginData"
+)
+
+// Redaction scrubs window titles, command lines and plugin data before a
+// capture is stored. Built-in detectors run first, then Rules in order.
+type Redaction struct {
+	Enabled   bool        `json:"enabled"`
+	Detectors []string    `json:"detectors"`
+	Rules     []ScrubRule `json:"rules"`
+}
+
+// ScrubRule replaces every match of the regex Pattern. Fields limits it to
+// some of "windowTitle", "commandLine" and "pluginData"; empty means all.
+type ScrubRule struct {
+	Name        string   `json:"name,omitempty"`
+	Pattern     string   `json:"pattern"`
+	Fields      []string `json:"fields,omitempty"`
+	Replacement string   `json:"replacement,omitempty"` // default "[redacted]"
+}
+
+var (
+	emailRe = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	// Only the value of a sensitive query parameter is replaced.
+	urlTokenRe = regexp.MustCompile(`(?i)([?&#](?:access_token|id_token|refresh_token|token|api_key|apikey|key|secret|sig|signature|code|auth|session|sessionid|password|pwd)=)[^&#\s"']+`)
+	hexRe      = regexp.MustCompile(`\b[0-9a-fA-F]{32,}\b`)
+	// Path separators are left out so a token never spans path components.
+	base64Re = regexp.MustCompile(`[A-Za-z0-9+_\-]{40,}={0,2}`)
+)
+
+var detectors = map[string]func(string) (string, int){
+	DetectorEmail: func(s string) (string, int) {
+		return replaceCount(emailRe, s, func(string) string { return "[email]" })
+	},
+	DetectorURLToken: func(s string) (string, int) {
+		n := 0
+		out := urlTokenRe.ReplaceAllStringFunc(s, func(m string) string {
+			n++
+			return urlTokenRe.ReplaceAllString(m, "${1}[token]")
+		})
+		return out, n
+	},
+	DetectorSecret: func(s string) (string, int) {
+		out, n := replaceCount(hexRe, s, func(tok string) string {
+			// Runs like 000...0 or deadbeef repeated are padding, not keys.
+			if entropy(tok) < 2.5 {
+				return tok
+			}
+			return "[secret]"
+		})
+		out, m := replaceCount(base64Re, out, func(tok string) string {
+			if !looksRandom(strings.TrimRight(tok, "=")) {
+				return tok
+			}
+			return "[secret]"
+		})
+		return out, n + m
+	},
+}
+
+// looksRandom tells a key or token from a long file, package or identifier
+// name. Names are letters only, words joined by - or _, or CamelCase, and
+// repeat characters far more than random data does.
+func looksRandom(tok string) bool {
+	if !strings.ContainsAny(tok, "0123456789") {
+		return false
+	}
+	if seps := strings.Count(tok, "-") + strings.Count(tok, "_"); seps*8 > len(tok) {
+		return false
+	}
+	upper, words := 0, 0
+	for i := 0; i < len(tok); i++ {
+		if tok[i] >= 'A' && tok[i] <= 'Z' {
+			upper++
+			if i+1 < len(tok) && tok[i+1] >= 'a' && tok[i+1] <= 'z' {
+				words++
+			}
+		}
+	}
+	if upper >= 2 && words >= upper-1 {
+		return false
+	}
+	// Random base64 stays close to the maximum entropy for its length.
+	return entropy(tok) >= 0.78*math.Log2(float64(min(len(tok), 64)))
+}
+
+// entropy returns the Shannon entropy of s in bits per byte.
+func entropy(s string) float64 {
+	var counts [256]int
+	for i := 0; i < len(s); i++ {
+		counts[s[i]]++
+	}
+	h := 0.0
+	for _, c := range counts {
+		if c > 0 {
+			p := float64(c) / float64(len(s))
+			h -= p * math.Log2(p)
+		}
+	}
+	return h
+}
+
+func replaceCount(re *regexp.Regexp, s string, repl func(string) string) (string, int) {
+	n := 0
+	out := re.ReplaceAllStringFunc(s, func(m string) string {
+		r := repl(m)
+		if r != m {
+			n++
+		}
+		return r
+	})
+	return out, n
+}
+
+// Apply redacts app in place and returns the number of replacements per
+// field, or nil when nothing was replaced.
+func (r Redaction) Apply(app *state.AppState) map[string]int {
+	if !r.Enabled {
+		return nil
+	}
+	counts := map[string]int{}
+	scrub := func(field, s string) string {
+		if s == "" {
+			return s
+		}
+		for _, name := range r.Detectors {
+			if det, ok := detectors[name]; ok {
+				var n int
+				s, n = det(s)
+				counts[field] += n
+			}
+		}
+		for _, rule := range r.Rules {
+			if !rule.appliesTo(field) {
+				continue
+			}
+			re, err := (Rule{Match: MatchRegex, Pattern: rule.Pattern}).compile()
+			if err != nil {
+				continue
+			}
+			repl := rule.Replacement
+			if repl == "" {
+				repl = "[redacted]"
+			}
+			var n int
+			s, n = replaceCount(re, s, func(string) string { return repl })
+			counts[field] += n
+		}
+		return s
+	}
+
+	for i := range app.Windows {
+		app.Windows[i].Title = scrub(RedactWindowTitle, app.Windows[i].Title)
+	}
+	app.CommandLine = scrub(RedactCommandLine, app.CommandLine)
+	if app.PluginData != nil {
+		app.PluginData = scrubValue(app.PluginData, func(s string) string { return scrub(RedactPluginData, s) }).(map[string]any)
+	}
+
+	for k, n := range counts {
+		if n == 0 {
+			delete(counts, k)
+		}
+	}
+	if len(counts) == 0 {
+		return nil
+	}
+	return counts
+}
+
+func scrubValue(v any, scrub func(string) string) any {
+	switch t := v.(type) {
+	case string:
+		return scrub(t)
+	case []string:
+		for i := range t {
+			t[i] = scrub(t[i])
+		}
+		return t
+	case []any:
+		for i := range t {
+			t[i] = scrubValue(t[i], scrub)
+		}
+		return t
+	case map[string]any:
+		for k, x := range t {
+			t[k] = scrubValue(x, scrub)
+		}
+		return t
+	}
+	return v
+}
+
+func (s ScrubRule) appliesTo(field string) bool {
+	if len(s.Fields) == 0 {
+		return true
+	}
+	for _, f := range s.Fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Redaction) validate(bad func(field, format string, args ...any)) {
+	for i, d := range r.Detectors {
+		if _, ok := detectors[d]; !ok {
+			bad(fmt.Sprintf("redaction.detectors[%d]", i), "must be one of %q, %q, %q", DetectorEmail, DetectorURLToken, DetectorSecret)
+		}
+	}
+	for i, rule := range r.Rules {
+		field := fmt.Sprintf("redaction.rules[%d]", i)
+		if rule.Pattern == "" {
+			bad(field+".pattern", "must not be empty")
+		} else if _, err := (Rule{Match: MatchRegex, Pattern: rule.Pattern}).compile(); err != nil {
+			bad(field+".pattern", "invalid regex: %v", err)
+		}
+		for j, f := range rule.Fields {
+			switch f {
+			case RedactWindowTitle, RedactCommandLine, RedactPluginData:
+			default:
+				bad(fmt.Sprintf("%s.fields[%d]", field, j), "must be %q, %q or %q", RedactWindowTitle, RedactCommandLine, RedactPluginData)
+			}
+		}
+	}
+}
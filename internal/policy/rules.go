@@ -0,0 +1,237 @@
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"Rewinder/internal/state"
+)
+
+const (
+	ModeTrackAll  = "all"       // track everything no rule excludes
+	ModeAllowlist = "allowlist" // track only what a rule includes
+
+	ActionInclude = "include"
+	ActionExclude = "exclude"
+
+	FieldExePath     = "exePath"
+	FieldExeName     = "exeName"
+	FieldWindowClass = "windowClass"
+	FieldWindowTitle = "windowTitle"
+	FieldCommandLine = "commandLine"
+
+	MatchExact    = "exact"
+	MatchContains = "contains"
+	MatchGlob     = "glob"
+	MatchRegex    = "regex"
+)
+
+// Rules decide which apps are tracked. Items are checked in order and the
+// first matching one wins; when none matches, Mode decides.
+//
+// The Exclude* lists are the rule format of config version 1. They are
+// converted to Items when such a config is loaded.
+type Rules struct {
+	Mode  string `json:"mode"`
+	Items []Rule `json:"items"`
+
+	ExcludeExeNames      []string `json:"excludeExeNames,omitempty"`
+	ExcludePathSubstr    []string `json:"excludePathSubstr,omitempty"`
+	ExcludeWindowClasses []string `json:"excludeWindowClasses,omitempty"`
+}
+
+// Rule matches one field of the target. All matching is case-insensitive;
+// glob patterns support * and ? and * also matches path separators.
+type Rule struct {
+	Action  string `json:"action"`
+	Field   string `json:"field"`
+	Match   string `json:"match"`
+	Pattern string `json:"pattern"`
+}
+
+// Target is what the rules are evaluated against.
+type Target struct {
+	ExePath     string
+	WindowClass string
+	WindowTitle string
+	CommandLine string
+}
+
+// TargetOf builds the rule target of a captured app.
+func TargetOf(app *state.AppState) Target {
+	t := Target{
+		ExePath:     app.ExecutablePath,
+		WindowClass: app.ForegroundWindowClass,
+		CommandLine: app.CommandLine,
+	}
+	for _, w := range app.Windows {
+		if w.IsForeground {
+			t.WindowTitle = w.Title
+			break
+		}
+	}
+	return t
+}
+
+// Decision is the outcome of the rules for one target. RuleIndex is -1 when
+// no rule matched and Mode decided.
+type Decision struct {
+	Tracked   bool
+	RuleIndex int
+	Rule      *Rule
+	Reason    string
+}
+
+func (r Rules) Allow(t Target) bool {
+	return r.Explain(t).Tracked
+}
+
+func (r Rules) Explain(t Target) Decision {
+	for i, it := range r.Items {
+		if it.matches(t) {
+			rule := it
+			return Decision{
+				Tracked:   it.Action == ActionInclude,
+				RuleIndex: i,
+				Rule:      &rule,
+				Reason:    fmt.Sprintf("rule %d: %s %s %s %q", i+1, it.Action, it.Field, it.Match, it.Pattern),
+			}
+		}
+	}
+	if r.Mode == ModeAllowlist {
+		return Decision{RuleIndex: -1, Reason: "allowlist mode and no include rule matched"}
+	}
+	return Decision{Tracked: true, RuleIndex: -1, Reason: "no rule matched"}
+}
+
+// Explain reports whether cfg's rules track app and why.
+func Explain(cfg *Config, app *state.AppState) Decision {
+	return cfg.Rules.Explain(TargetOf(app))
+}
+
+func (it Rule) matches(t Target) bool {
+	var v string
+	switch it.Field {
+	case FieldExePath:
+		v = t.ExePath
+	case FieldExeName:
+		v = t.ExePath[strings.LastIndexAny(t.ExePath, `/\`)+1:]
+	case FieldWindowClass:
+		v = t.WindowClass
+	case FieldWindowTitle:
+		v = t.WindowTitle
+	case FieldCommandLine:
+		v = t.CommandLine
+	default:
+		return false
+	}
+
+	switch it.Match {
+	case MatchExact:
+		return strings.EqualFold(v, it.Pattern)
+	case MatchContains:
+		return strings.Contains(strings.ToLower(v), strings.ToLower(it.Pattern))
+	case MatchGlob, MatchRegex:
+		re, err := it.compile()
+		return err == nil && re.MatchString(v)
+	}
+	return false
+}
+
+// Compiled patterns are shared by every config that uses them.
+var patternCache sync.Map // "glob:" or "regex:" + pattern -> *regexp.Regexp
+
+func (it Rule) compile() (*regexp.Regexp, error) {
+	key := it.Match + ":" + it.Pattern
+	if v, ok := patternCache.Load(key); ok {
+		return v.(*regexp.Regexp), nil
+	}
+	expr := it.Pattern
+	if it.Match == MatchGlob {
+		expr = globToRegex(it.Pattern)
+	}
+	re, err := regexp.Compile("(?i)" + expr)
+	if err != nil {
+		return nil, err
+	}
+	patternCache.Store(key, re)
+	return re, nil
+}
+
+func globToRegex(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+func (r Rules) validate(bad func(field, format string, args ...any)) {
+	switch r.Mode {
+	case "", ModeTrackAll, ModeAllowlist:
+	default:
+		bad("rules.mode", "must be %q or %q", ModeTrackAll, ModeAllowlist)
+	}
+	for i, it := range r.Items {
+		field := fmt.Sprintf("rules.items[%d]", i)
+		switch it.Action {
+		case ActionInclude, ActionExclude:
+		default:
+			bad(field+".action", "must be %q or %q", ActionInclude, ActionExclude)
+		}
+		it.validateMatcher(field, bad)
+	}
+}
+
+// validateMatcher checks the field, match and pattern of a rule.
+func (it Rule) validateMatcher(field string, bad func(field, format string, args ...any)) {
+	switch it.Field {
+	case FieldExePath, FieldExeName, FieldWindowClass, FieldWindowTitle, FieldCommandLine:
+	default:
+		bad(field+".field", "unknown field %q", it.Field)
+	}
+	switch it.Match {
+	case MatchExact, MatchContains:
+	case MatchGlob, MatchRegex:
+		if _, err := it.compile(); err != nil {
+			bad(field+".pattern", "invalid %s: %v", it.Match, err)
+		}
+	default:
+		bad(field+".match", "unknown match %q", it.Match)
+	}
+	if it.Pattern == "" {
+		bad(field+".pattern", "must not be empty")
+	}
+}
+
+// migrateLegacy turns the version 1 exclude lists into rule items, keeping
+// their order of evaluation: exe names, then path substrings, then classes.
+func (r *Rules) migrateLegacy() {
+	var items []Rule
+	add := func(field, match string, list []string) {
+		for _, p := range list {
+			if strings.TrimSpace(p) != "" {
+				items = append(items, Rule{Action: ActionExclude, Field: field, Match: match, Pattern: p})
+			}
+		}
+	}
+	add(FieldExeName, MatchExact, r.ExcludeExeNames)
+	add(FieldExePath, MatchContains, r.ExcludePathSubstr)
+	add(FieldWindowClass, MatchExact, r.ExcludeWindowClasses)
+	r.Items = items
+	r.Mode = ModeTrackAll
+	r.ExcludeExeNames = nil
+	r.ExcludePathSubstr = nil
+	r.ExcludeWindowClasses = nil
+}
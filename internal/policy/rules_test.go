@@ -0,0 +1,130 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRuleMatch(t *testing.T) {
+	notepad := Target{
+		ExePath:     `C:\Windows\System32\notepad.exe`,
+		WindowClass: "Notepad",
+		WindowTitle: "todo.txt - Notepad",
+		CommandLine: `notepad.exe C:\Users\me\todo.txt`,
+	}
+	temp := Target{ExePath: `C:\Users\me\AppData\Local\Temp\setup\install.exe`}
+	linux := Target{ExePath: "/usr/bin/nvim", CommandLine: "nvim /home/me/notes.md"}
+
+	cases := []struct {
+		name  string
+		rule  Rule
+		t     Target
+		match bool
+	}{
+		{"exact name", Rule{Field: FieldExeName, Match: MatchExact, Pattern: "NOTEPAD.EXE"}, notepad, true},
+		{"exact name is not a substring", Rule{Field: FieldExeName, Match: MatchExact, Pattern: "note"}, notepad, false},
+		{"name of a unix path", Rule{Field: FieldExeName, Match: MatchExact, Pattern: "nvim"}, linux, true},
+		{"contains", Rule{Field: FieldWindowTitle, Match: MatchContains, Pattern: "TODO"}, notepad, true},
+
+		{"glob star crosses separators", Rule{Field: FieldExePath, Match: MatchGlob, Pattern: `*\AppData\Local\Temp\*`}, temp, true},
+		{"glob is anchored", Rule{Field: FieldExePath, Match: MatchGlob, Pattern: `AppData\Local\Temp\*`}, temp, false},
+		{"glob ignores case", Rule{Field: FieldExePath, Match: MatchGlob, Pattern: `c:\windows\*`}, notepad, true},
+		{"glob question mark", Rule{Field: FieldExeName, Match: MatchGlob, Pattern: "note?ad.exe"}, notepad, true},
+		{"glob question mark is one char", Rule{Field: FieldExeName, Match: MatchGlob, Pattern: "note?d.exe"}, notepad, false},
+		{"glob dot is literal", Rule{Field: FieldExeName, Match: MatchGlob, Pattern: "notepad.ex?"}, Target{ExePath: "notepadxexe"}, false},
+		{"glob brackets are literal", Rule{Field: FieldWindowClass, Match: MatchGlob, Pattern: "[a-z]*"}, notepad, false},
+		{"glob unix path", Rule{Field: FieldExePath, Match: MatchGlob, Pattern: "/usr/*"}, linux, true},
+
+		{"regex", Rule{Field: FieldExeName, Match: MatchRegex, Pattern: `^n?vim$`}, linux, true},
+		{"regex is unanchored", Rule{Field: FieldCommandLine, Match: MatchRegex, Pattern: `\.md$`}, linux, true},
+		{"regex ignores case", Rule{Field: FieldWindowClass, Match: MatchRegex, Pattern: `^notepad$`}, notepad, true},
+		{"regex no match", Rule{Field: FieldExeName, Match: MatchRegex, Pattern: `^vim$`}, linux, false},
+		{"invalid regex never matches", Rule{Field: FieldExeName, Match: MatchRegex, Pattern: `(`}, notepad, false},
+
+		{"unknown field", Rule{Field: "user", Match: MatchContains, Pattern: "me"}, notepad, false},
+		{"unknown match", Rule{Field: FieldExeName, Match: "fuzzy", Pattern: "notepad"}, notepad, false},
+		{"empty field value", Rule{Field: FieldWindowTitle, Match: MatchContains, Pattern: "x"}, temp, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.rule.matches(c.t); got != c.match {
+				t.Errorf("matches = %v, want %v", got, c.match)
+			}
+		})
+	}
+}
+
+func TestRulesExplain(t *testing.T) {
+	code := Target{ExePath: `C:\Apps\Code\code.exe`, WindowTitle: "main.go - Code"}
+	shell := Target{ExePath: `C:\Windows\explorer.exe`, WindowClass: "Shell_TrayWnd"}
+	keepass := Target{ExePath: `C:\Apps\KeePass\KeePass.exe`}
+
+	includeCode := Rule{Action: ActionInclude, Field: FieldExeName, Match: MatchGlob, Pattern: "code*.exe"}
+	excludeTray := Rule{Action: ActionExclude, Field: FieldWindowClass, Match: MatchExact, Pattern: "shell_traywnd"}
+	excludeKeePass := Rule{Action: ActionExclude, Field: FieldExePath, Match: MatchRegex, Pattern: `\\keepass\\`}
+	includeAll := Rule{Action: ActionInclude, Field: FieldExePath, Match: MatchGlob, Pattern: "*"}
+
+	cases := []struct {
+		name    string
+		rules   Rules
+		t       Target
+		tracked bool
+		index   int
+	}{
+		{"track all, no rules", Rules{Mode: ModeTrackAll}, code, true, -1},
+		{"empty mode tracks all", Rules{}, code, true, -1},
+		{"track all, excluded", Rules{Mode: ModeTrackAll, Items: []Rule{excludeTray}}, shell, false, 0},
+		{"track all, not excluded", Rules{Mode: ModeTrackAll, Items: []Rule{excludeTray}}, code, true, -1},
+		{"allowlist, no rules", Rules{Mode: ModeAllowlist}, code, false, -1},
+		{"allowlist, included", Rules{Mode: ModeAllowlist, Items: []Rule{excludeTray, includeCode}}, code, true, 1},
+		{"allowlist, not included", Rules{Mode: ModeAllowlist, Items: []Rule{includeCode}}, shell, false, -1},
+		{"allowlist, exclude before include wins", Rules{Mode: ModeAllowlist, Items: []Rule{excludeKeePass, includeAll}}, keepass, false, 0},
+		{"allowlist, include after exclude", Rules{Mode: ModeAllowlist, Items: []Rule{excludeKeePass, includeAll}}, code, true, 1},
+		{"include before exclude wins", Rules{Mode: ModeTrackAll, Items: []Rule{includeAll, excludeKeePass}}, keepass, true, 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := c.rules.Explain(c.t)
+			if d.Tracked != c.tracked || d.RuleIndex != c.index {
+				t.Fatalf("Explain = tracked %v, rule %d (%s); want tracked %v, rule %d", d.Tracked, d.RuleIndex, d.Reason, c.tracked, c.index)
+			}
+			if (d.Rule != nil) != (c.index >= 0) {
+				t.Errorf("Rule = %v for index %d", d.Rule, c.index)
+			}
+			if c.rules.Allow(c.t) != c.tracked {
+				t.Errorf("Allow disagrees with Explain")
+			}
+		})
+	}
+}
+
+func TestRulesValidate(t *testing.T) {
+	cases := []struct {
+		name  string
+		rules Rules
+		field string
+	}{
+		{"bad mode", Rules{Mode: "some"}, "rules.mode"},
+		{"bad action", Rules{Items: []Rule{{Action: "skip", Field: FieldExeName, Match: MatchExact, Pattern: "a"}}}, "rules.items[0].action"},
+		{"bad field", Rules{Items: []Rule{{Action: ActionExclude, Field: "user", Match: MatchExact, Pattern: "a"}}}, "rules.items[0].field"},
+		{"bad match", Rules{Items: []Rule{{Action: ActionExclude, Field: FieldExeName, Match: "fuzzy", Pattern: "a"}}}, "rules.items[0].match"},
+		{"bad regex", Rules{Items: []Rule{{Action: ActionExclude, Field: FieldExeName, Match: MatchRegex, Pattern: "("}}}, "rules.items[0].pattern"},
+		{"empty pattern", Rules{Items: []Rule{{Action: ActionExclude, Field: FieldExeName, Match: MatchGlob}}}, "rules.items[0].pattern"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Rules = c.rules
+			var ve *ValidationError
+			if err := cfg.Validate(); !errors.As(err, &ve) {
+				t.Fatalf("Validate = %v, want a ValidationError", err)
+			}
+			for _, fe := range ve.Errors {
+				if fe.Field == c.field {
+					return
+				}
+			}
+			t.Errorf("no error for %s in %v", c.field, ve.Errors)
+		})
+	}
+}
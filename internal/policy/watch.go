@@ -0,0 +1,41 @@
+package policy
+
+import (
+	"os"
+	"time"
+)
+
+// Watch polls the config file at path and calls onChange with the reloaded
+// config, or with the error when the new contents are invalid, each time the
+// file's size or modification time changes. It returns when stop is closed.
+func Watch(path string, interval time.Duration, stop <-chan struct{}, onChange func(*Config, error)) {
+	last, _ := fileStamp(path)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+		cur, err := fileStamp(path)
+		if err != nil || cur == last {
+			continue
+		}
+		last = cur
+		onChange(Load(path))
+	}
+}
+
+type stamp struct {
+	mod  time.Time
+	size int64
+}
+
+func fileStamp(path string) (stamp, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return stamp{}, err
+	}
+	return stamp{mod: fi.ModTime(), size: fi.Size()}, nil
+}
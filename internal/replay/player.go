@@ -0,0 +1,123 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"Rewinder/internal/state"
+)
+
+// ErrNoCapture is returned by FakeCapture when the recorded session captured
+// nothing for the current event, e.g. because it was throttled.
+var ErrNoCapture = errors.New("no capture recorded for this event")
+
+// SkippedError is returned by FakeCapture for a capture the recorded session
+// did not track, of which only the app ID is known.
+type SkippedError struct {
+	AppID string
+}
+
+func (e *SkippedError) Error() string {
+	return "capture of " + e.AppID + " was not tracked when recorded"
+}
+
+// Clock is the virtual time of a replay: the time of the step being played.
+type Clock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *Clock) Set(t time.Time) {
+	c.mu.Lock()
+	c.t = t
+	c.mu.Unlock()
+}
+
+// FakeCapture stands in for the capture engine and hands out the states
+// recorded for the current step, in order.
+type FakeCapture struct {
+	mu    sync.Mutex
+	queue []Capture
+}
+
+// Load replaces whatever the previous step left unused.
+func (f *FakeCapture) Load(captures []Capture) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queue = f.queue[:0]
+	for _, c := range captures {
+		// Captures are modified downstream; keep the recording intact.
+		a := c.App
+		cp := *a
+		cp.Windows = append([]state.WindowState(nil), a.Windows...)
+		cp.OpenFiles = append([]state.FileRef(nil), a.OpenFiles...)
+		cp.PluginData = clonePluginData(a.PluginData)
+		f.queue = append(f.queue, Capture{App: &cp, Skipped: c.Skipped})
+	}
+}
+
+func (f *FakeCapture) CaptureForeground() (*state.AppState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.queue) == 0 {
+		return nil, ErrNoCapture
+	}
+	c := f.queue[0]
+	f.queue = f.queue[1:]
+	if c.Skipped {
+		return nil, &SkippedError{AppID: c.App.AppID}
+	}
+	return c.App, nil
+}
+
+func clonePluginData(m map[string]any) map[string]any {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		if sub, ok := v.(map[string]any); ok {
+			v = clonePluginData(sub)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// Play hands the steps of rec to handle one at a time, after setting clock to
+// the step's time and loading its captures into capture. speed 1 keeps the
+// recorded pacing, 10 plays ten times faster, and 0 plays without waiting.
+// Decisions made while handling see only the virtual clock, so they do not
+// depend on speed.
+func Play(ctx context.Context, rec *Recording, speed float64, clock *Clock, capture *FakeCapture, handle func(Step)) error {
+	var prev time.Time
+	for i, st := range rec.Steps {
+		if speed > 0 && i > 0 {
+			if d := time.Duration(float64(st.At.Sub(prev)) / speed); d > 0 {
+				t := time.NewTimer(d)
+				select {
+				case <-ctx.Done():
+					t.Stop()
+					return ctx.Err()
+				case <-t.C:
+				}
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		prev = st.At
+		clock.Set(st.At)
+		capture.Load(st.Captures)
+		handle(st)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package replay
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"Rewinder/internal/events"
+	"Rewinder/internal/state"
+)
+
+const (
+	FormatVersion = 1
+
+	kindEvent   = "event"
+	kindCapture = "capture"
+)
+
+// A recording is a gzip stream of JSON lines: a Header, then one entry per
+// event handled and per app state captured while handling it. Captures are
+// stored after redaction, as the snapshot store keeps them; rules that match
+// redacted text may decide differently on replay. Apps that were not tracked
+// leave only their app ID, and events lose the process command lines, which
+// nothing replays. Clipboard text is never stored, only its hash.
+type Header struct {
+	Version    int    `json:"version"`
+	OS         string `json:"os"`
+	StartedUTC int64  `json:"startedUTC"`
+}
+
+type entry struct {
+	Kind  string              `json:"k"`
+	AtUTC int64               `json:"t"` // unix ms when it happened
+	Event *events.SystemEvent `json:"ev,omitempty"`
+	App   *state.AppState     `json:"app,omitempty"`
+	// Skipped marks a capture that was not tracked; App has only its ID.
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// Recorder writes a recording. Entries are flushed one by one so the file is
+// readable up to the last event if the app crashes.
+type Recorder struct {
+	mu  sync.Mutex
+	f   *os.File
+	gz  *gzip.Writer
+	enc *json.Encoder
+	err error
+}
+
+func NewRecorder(path string, now time.Time) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	gz := gzip.NewWriter(f)
+	r := &Recorder{f: f, gz: gz, enc: json.NewEncoder(gz)}
+	if err := r.write(Header{Version: FormatVersion, OS: runtime.GOOS, StartedUTC: now.UTC().UnixMilli()}); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Event records ev, handled at the given time, without the command line a
+// process event carries.
+func (r *Recorder) Event(ev events.SystemEvent, at time.Time) {
+	if _, ok := ev.Metadata["cmdline"]; ok {
+		md := make(map[string]any, len(ev.Metadata))
+		for k, v := range ev.Metadata {
+			if k != "cmdline" {
+				md[k] = v
+			}
+		}
+		ev.Metadata = md
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_ = r.write(entry{Kind: kindEvent, AtUTC: at.UTC().UnixMilli(), Event: &ev})
+}
+
+// Capture records a state captured for the last event.
+func (r *Recorder) Capture(app *state.AppState, at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_ = r.write(entry{Kind: kindCapture, AtUTC: at.UTC().UnixMilli(), App: app})
+}
+
+// Skipped records that appID was captured for the last event but not
+// tracked. Nothing else about it is kept.
+func (r *Recorder) Skipped(appID string, at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_ = r.write(entry{Kind: kindCapture, AtUTC: at.UTC().UnixMilli(), App: &state.AppState{AppID: appID}, Skipped: true})
+}
+
+// Err returns the first write error; nothing is written after it.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func (r *Recorder) write(v any) error {
+	if r.err != nil {
+		return r.err
+	}
+	if err := r.enc.Encode(v); err != nil {
+		r.err = err
+		return err
+	}
+	r.err = r.gz.Flush()
+	return r.err
+}
+
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.gz.Close()
+	if cerr := r.f.Close(); err == nil {
+		err = cerr
+	}
+	r.f = nil
+	if r.err == nil {
+		r.err = errors.New("recorder closed")
+	}
+	return err
+}
+
+// Step is one recorded event with the states captured while handling it.
+type Step struct {
+	At       time.Time
+	Event    events.SystemEvent
+	Captures []Capture
+}
+
+// Capture is a state captured while handling a step. Skipped captures were
+// not tracked and have only the app ID.
+type Capture struct {
+	App     *state.AppState
+	Skipped bool
+}
+
+type Recording struct {
+	Header Header
+	Steps  []Step
+}
+
+// Open reads the recording at path. A file cut off by a crash yields the
+// steps before the damage.
+func Open(path string) (*Recording, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+func Read(r io.Reader) (*Recording, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bufio.NewReader(gz))
+	rec := &Recording{}
+	if err := dec.Decode(&rec.Header); err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	if rec.Header.Version != FormatVersion {
+		return nil, fmt.Errorf("unsupported recording version %d", rec.Header.Version)
+	}
+	for {
+		var e entry
+		if err := dec.Decode(&e); err != nil {
+			if errors.Is(err, io.EOF) || len(rec.Steps) > 0 {
+				return rec, nil
+			}
+			return nil, err
+		}
+		at := time.UnixMilli(e.AtUTC)
+		switch {
+		case e.Kind == kindEvent && e.Event != nil:
+			rec.Steps = append(rec.Steps, Step{At: at, Event: *e.Event})
+		case e.Kind == kindCapture && e.App != nil && len(rec.Steps) > 0:
+			last := &rec.Steps[len(rec.Steps)-1]
+			last.Captures = append(last.Captures, Capture{App: e.App, Skipped: e.Skipped})
+		}
+	}
+}
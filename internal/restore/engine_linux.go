@@ -0,0 +1,199 @@
+package restore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"Rewinder/internal/plugins"
+	"Rewinder/internal/snapshot"
+	"Rewinder/internal/state"
+)
+
+// Engine restores a snapshot by relaunching its process when it is gone and
+// reopening its files. Linux has no common way to move, focus or type into
+// another app's windows, so those stages are reported as skipped.
+type Engine struct {
+	mu     sync.RWMutex
+	opener []string
+}
+
+func NewEngine() *Engine {
+	return &Engine{opener: []string{"xdg-open", "{file}"}}
+}
+
+// SetFileOpener sets the command that reopens files; see
+// policy.RestorePolicy.
+func (e *Engine) SetFileOpener(cmd []string) {
+	if len(cmd) == 0 {
+		return
+	}
+	e.mu.Lock()
+	e.opener = append([]string(nil), cmd...)
+	e.mu.Unlock()
+}
+
+func (e *Engine) RestoreSnapshot(progress ProgressFn, snap *snapshot.Snapshot, full *snapshot.FullSnapshot) error {
+	if full == nil {
+		return errors.New("no snapshot state")
+	}
+	app := full.App
+	if app.RawCommandLine != "" {
+		// Relaunch with the arguments as captured, not their redacted form.
+		app.CommandLine = app.RawCommandLine
+	}
+	plugins.DefaultRegistry().Restore(&app)
+	progress("ensure_process", 15, "Ensuring process exists")
+
+	pid := app.PID
+	if pid <= 0 || !processExists(pid, app.ExecutablePath) {
+		npid, exited, err := relaunch(&app)
+		if err != nil {
+			return err
+		}
+		pid = npid
+		progress("wait_windows", 35, "Waiting for the process to start")
+		if err := waitStarted(exited, 2*time.Second); err != nil {
+			return err
+		}
+	}
+
+	progress("restore_windows", 60, "Reopening files")
+	opened, failed := e.reopenFiles(pid, &app)
+	if failed > 0 {
+		progress("restore_windows", 70, fmt.Sprintf("%d of %d files could not be reopened", failed, opened+failed))
+	}
+
+	progress("restore_focus", 80, "Skipped: focus is not restored on Linux")
+	progress("restore_input", 90, "Skipped: clipboard and input language are not restored on Linux")
+	return nil
+}
+
+// processExists checks /proc for pid. If the executable is readable it must
+// match, so a reused PID does not count.
+func processExists(pid int, exePath string) bool {
+	dir := filepath.Join("/proc", strconv.Itoa(pid))
+	if _, err := os.Stat(dir); err != nil {
+		return false
+	}
+	exe, err := os.Readlink(filepath.Join(dir, "exe"))
+	if err != nil || exePath == "" {
+		return true
+	}
+	return strings.TrimSuffix(exe, " (deleted)") == exePath
+}
+
+// relaunch starts the app again from its command line, in its working dir
+// and desktop session. The child gets its own session so it outlives us;
+// exited reports how it ended.
+func relaunch(app *state.AppState) (int, <-chan error, error) {
+	if app.ExecutablePath == "" && app.CommandLine == "" {
+		return 0, nil, errors.New("missing executable path/command line")
+	}
+	args, err := state.SplitArgs(app.CommandLine)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(args) == 0 {
+		args = []string{app.ExecutablePath}
+	}
+	path := app.ExecutablePath
+	if path == "" {
+		if path, err = exec.LookPath(args[0]); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	cmd := &exec.Cmd{Path: path, Args: args, Env: os.Environ()}
+	for k, v := range app.Environment {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	if fi, err := os.Stat(app.WorkingDir); err == nil && fi.IsDir() {
+		cmd.Dir = app.WorkingDir
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return 0, nil, err
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	return cmd.Process.Pid, exited, nil
+}
+
+// waitStarted gives a relaunched process a moment to fail. Exiting cleanly is
+// fine: launchers often hand off to a running instance.
+func waitStarted(exited <-chan error, timeout time.Duration) error {
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case err := <-exited:
+		if err != nil {
+			return fmt.Errorf("relaunched process exited: %w", err)
+		}
+	case <-t.C:
+	}
+	return nil
+}
+
+// reopenFiles opens the snapshot's files that pid does not have open, except
+// those on its command line, which the app opens itself.
+func (e *Engine) reopenFiles(pid int, app *state.AppState) (opened, failed int) {
+	skip := map[string]bool{}
+	for _, f := range state.OpenFiles(pid) {
+		skip[f.Path] = true
+	}
+	if args, err := state.SplitArgs(app.CommandLine); err == nil {
+		for _, a := range args {
+			if filepath.IsAbs(a) {
+				skip[filepath.Clean(a)] = true
+			} else if app.WorkingDir != "" {
+				skip[filepath.Join(app.WorkingDir, a)] = true
+			}
+		}
+	}
+
+	e.mu.RLock()
+	tmpl := e.opener
+	e.mu.RUnlock()
+	for _, f := range app.OpenFiles {
+		if skip[f.Path] {
+			continue
+		}
+		if _, err := os.Stat(f.Path); err != nil {
+			continue
+		}
+		argv := openerArgs(tmpl, f.Path, app.ExecutablePath)
+		cmd := exec.Command(argv[0], argv[1:]...)
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+		if err := cmd.Start(); err != nil {
+			failed++
+			continue
+		}
+		go func() { _ = cmd.Wait() }()
+		opened++
+	}
+	return opened, failed
+}
+
+func openerArgs(tmpl []string, path, exe string) []string {
+	out := make([]string, 0, len(tmpl)+1)
+	hasFile := false
+	for _, a := range tmpl {
+		if strings.Contains(a, "{file}") {
+			hasFile = true
+		}
+		a = strings.ReplaceAll(a, "{file}", path)
+		out = append(out, strings.ReplaceAll(a, "{exe}", exe))
+	}
+	if !hasFile {
+		out = append(out, path)
+	}
+	return out
+}
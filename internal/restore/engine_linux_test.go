@@ -0,0 +1,148 @@
+package restore
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"Rewinder/internal/snapshot"
+	"Rewinder/internal/state"
+)
+
+// findProcs lists the PIDs whose argv is exactly args.
+func findProcs(args ...string) []int {
+	want := []byte(strings.Join(args, "\x00") + "\x00")
+	entries, _ := os.ReadDir("/proc")
+	var pids []int
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join("/proc", e.Name(), "cmdline"))
+		if err == nil && bytes.Equal(b, want) {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
+
+// killAll kills the relaunched helpers, which are not *exec.Cmd of the test.
+func killAll(args ...string) {
+	for _, pid := range findProcs(args...) {
+		_ = syscall.Kill(pid, syscall.SIGKILL)
+	}
+}
+
+func waitFor(t *testing.T, what string, ok func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if ok() {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+// captureHelper starts a sleep that the test owns and captures it the way
+// the services do, with its command line redacted.
+func captureHelper(t *testing.T, arg string) (*exec.Cmd, state.AppState) {
+	t.Helper()
+	cmd := exec.Command("sleep", arg)
+	if err := cmd.Start(); err != nil {
+		t.Skipf("sleep: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+	st, err := state.NewCaptureEngine().CapturePID(cmd.Process.Pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.CommandLine != "sleep "+arg {
+		t.Fatalf("captured %q", st.CommandLine)
+	}
+	st.RawCommandLine = st.CommandLine
+	st.CommandLine = "sleep [REDACTED]"
+	return cmd, *st
+}
+
+func TestRestoreRelaunchesHelper(t *testing.T) {
+	arg := fmt.Sprintf("%d.25", 3000+os.Getpid()%1000)
+	t.Cleanup(func() { killAll("sleep", arg) })
+	cmd, app := captureHelper(t, arg)
+
+	file := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(file, []byte("draft"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	app.OpenFiles = []state.FileRef{{Path: file}}
+
+	_ = cmd.Process.Kill()
+	_ = cmd.Wait()
+
+	e := NewEngine()
+	e.SetFileOpener([]string{"cp", "{file}", "{file}.reopened"})
+	var stages []string
+	progress := func(stage string, percent int, msg string) {
+		stages = append(stages, stage+": "+msg)
+	}
+	if err := e.RestoreSnapshot(progress, nil, &snapshot.FullSnapshot{App: app}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Relaunched from the unredacted command line.
+	pids := findProcs("sleep", arg)
+	if len(pids) != 1 || pids[0] == cmd.Process.Pid {
+		t.Fatalf("relaunched sleep processes: %v (stages %q)", pids, stages)
+	}
+	if len(findProcs("sleep", "[REDACTED]")) != 0 {
+		t.Fatal("relaunched with the redacted command line")
+	}
+	waitFor(t, "the file to be reopened", func() bool {
+		_, err := os.Stat(file + ".reopened")
+		return err == nil
+	})
+
+	want := map[string]string{
+		"ensure_process": "", "wait_windows": "", "restore_windows": "",
+		"restore_focus": "Skipped", "restore_input": "Skipped",
+	}
+	for _, s := range stages {
+		stage, msg, _ := strings.Cut(s, ": ")
+		prefix, ok := want[stage]
+		if !ok {
+			t.Errorf("unexpected stage %q", s)
+			continue
+		}
+		if !strings.HasPrefix(msg, prefix) {
+			t.Errorf("stage %q, want it to start with %q", s, prefix)
+		}
+		delete(want, stage)
+	}
+	if len(want) != 0 {
+		t.Errorf("stages not reported: %v", want)
+	}
+}
+
+func TestRestoreKeepsRunningHelper(t *testing.T) {
+	arg := fmt.Sprintf("%d.75", 3000+os.Getpid()%1000)
+	t.Cleanup(func() { killAll("sleep", arg) })
+	cmd, app := captureHelper(t, arg)
+
+	e := NewEngine()
+	if err := e.RestoreSnapshot(func(string, int, string) {}, nil, &snapshot.FullSnapshot{App: app}); err != nil {
+		t.Fatal(err)
+	}
+	if pids := findProcs("sleep", arg); len(pids) != 1 || pids[0] != cmd.Process.Pid {
+		t.Fatalf("sleep processes %v, want only the running %d", pids, cmd.Process.Pid)
+	}
+}
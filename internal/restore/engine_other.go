@@ -0,0 +1,19 @@
+//go:build !windows && !linux
+
+package restore
+
+import (
+	"errors"
+
+	"Rewinder/internal/snapshot"
+)
+
+type Engine struct{}
+
+func NewEngine() *Engine { return &Engine{} }
+
+func (e *Engine) SetFileOpener(cmd []string) {}
+
+func (e *Engine) RestoreSnapshot(progress ProgressFn, snap *snapshot.Snapshot, full *snapshot.FullSnapshot) error {
+	return errors.New("restore is not supported on this platform")
+}
@@ -13,17 +13,25 @@ import (
 	"golang.org/x/sys/windows"
 )
 
-type ProgressFn func(stage string, percent int, msg string)
+type Engine struct {
+	input InputRestorer
+}
 
-type Engine struct{}
+func NewEngine() *Engine { return &Engine{input: newPlatformInput()} }
 
-func NewEngine() *Engine { return &Engine{} }
+// SetFileOpener is a no-op on Windows: files come back with the relaunched
+// command line.
+func (e *Engine) SetFileOpener(cmd []string) {}
 
 func (e *Engine) RestoreSnapshot(progress ProgressFn, snap *snapshot.Snapshot, full *snapshot.FullSnapshot) error {
 	if full == nil {
 		return errors.New("no snapshot state")
 	}
 	app := full.App
+	if app.RawCommandLine != "" {
+		// Relaunch with the arguments as captured, not their redacted form.
+		app.CommandLine = app.RawCommandLine
+	}
 	plugins.DefaultRegistry().Restore(&app)
 	progress("ensure_process", 15, "Ensuring process exists")
 
@@ -43,11 +51,26 @@ func (e *Engine) RestoreSnapshot(progress ProgressFn, snap *snapshot.Snapshot, f
 		return err
 	}
 
-	progress("restore_focus", 90, "Restoring focus")
+	progress("restore_focus", 80, "Restoring focus")
 	restoreFocus(pid, app.Windows)
+
+	progress("restore_input", 90, "Restoring clipboard and input language")
+	e.restoreInput(&app)
 	return nil
 }
 
+func (e *Engine) restoreInput(app *state.AppState) {
+	if e.input == nil {
+		return
+	}
+	if app.ClipboardText != "" {
+		_ = e.input.SetClipboardText(app.ClipboardText)
+	}
+	if app.InputState.InputLanguage != "" {
+		_ = e.input.SetInputLanguage(app.InputState.InputLanguage)
+	}
+}
+
 func processExists(pid int) bool {
 	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
 	if err != nil {
@@ -0,0 +1,11 @@
+package restore
+
+// ProgressFn reports a restore stage with its overall percentage.
+type ProgressFn func(stage string, percent int, msg string)
+
+// InputRestorer puts session-wide input state back after the windows of a
+// snapshot have been restored.
+type InputRestorer interface {
+	SetClipboardText(text string) error
+	SetInputLanguage(tag string) error
+}
@@ -0,0 +1,111 @@
+//go:build windows
+
+package restore
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+type windowsInput struct{}
+
+func newPlatformInput() InputRestorer { return windowsInput{} }
+
+func (windowsInput) SetClipboardText(text string) error {
+	u16, err := windows.UTF16FromString(text)
+	if err != nil {
+		return err
+	}
+
+	opened := false
+	for i := 0; i < 5; i++ {
+		r1, _, _ := procOpenClipboard.Call(0)
+		if r1 != 0 {
+			opened = true
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !opened {
+		return errors.New("clipboard is busy")
+	}
+	defer procCloseClipboard.Call()
+
+	if r1, _, e1 := procEmptyClipboard.Call(); r1 == 0 {
+		return e1
+	}
+	size := uintptr(len(u16) * 2)
+	h, _, e1 := procGlobalAlloc.Call(GMEM_MOVEABLE, size)
+	if h == 0 {
+		return e1
+	}
+	mem, err := globalLock(h)
+	if err != nil {
+		_, _, _ = procGlobalFree.Call(h)
+		return err
+	}
+	copy(unsafe.Slice((*uint16)(mem), len(u16)), u16)
+	_, _, _ = procGlobalUnlock.Call(h)
+
+	if r1, _, e1 := procSetClipboardData.Call(CF_UNICODETEXT, h); r1 == 0 {
+		_, _, _ = procGlobalFree.Call(h)
+		return e1
+	}
+	return nil
+}
+
+// globalLock returns the memory of a global handle. It is allocated by
+// Windows, not Go, so the GC never moves it while it is locked.
+func globalLock(h uintptr) (unsafe.Pointer, error) {
+	r1, _, e1 := syscall.SyscallN(procGlobalLock.Addr(), h)
+	if r1 == 0 {
+		return nil, e1
+	}
+	return unsafe.Pointer(r1), nil
+}
+
+func (windowsInput) SetInputLanguage(tag string) error {
+	langID, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(tag), "0x"), 16, 16)
+	if err != nil {
+		return fmt.Errorf("bad input language %q: %w", tag, err)
+	}
+	klid, _ := windows.UTF16PtrFromString(fmt.Sprintf("%08X", langID))
+	hkl, _, e1 := procLoadKeyboardLayoutW.Call(uintptr(unsafe.Pointer(klid)), KLF_ACTIVATE)
+	if hkl == 0 {
+		return e1
+	}
+	fg, _, _ := procGetForegroundWindow.Call()
+	if fg != 0 {
+		_, _, _ = procPostMessageW.Call(fg, WM_INPUTLANGCHANGEREQUEST, 0, hkl)
+	}
+	return nil
+}
+
+var (
+	kernel32                = windows.NewLazySystemDLL("kernel32.dll")
+	procOpenClipboard       = user32.NewProc("OpenClipboard")
+	procCloseClipboard      = user32.NewProc("CloseClipboard")
+	procEmptyClipboard      = user32.NewProc("EmptyClipboard")
+	procSetClipboardData    = user32.NewProc("SetClipboardData")
+	procLoadKeyboardLayoutW = user32.NewProc("LoadKeyboardLayoutW")
+	procGetForegroundWindow = user32.NewProc("GetForegroundWindow")
+	procPostMessageW        = user32.NewProc("PostMessageW")
+	procGlobalAlloc         = kernel32.NewProc("GlobalAlloc")
+	procGlobalFree          = kernel32.NewProc("GlobalFree")
+	procGlobalLock          = kernel32.NewProc("GlobalLock")
+	procGlobalUnlock        = kernel32.NewProc("GlobalUnlock")
+)
+
+const (
+	CF_UNICODETEXT            = 13
+	GMEM_MOVEABLE             = 0x0002
+	KLF_ACTIVATE              = 0x00000001
+	WM_INPUTLANGCHANGEREQUEST = 0x0050
+)
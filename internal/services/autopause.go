@@ -0,0 +1,60 @@
+package services
+
+import (
+	"time"
+
+	"Rewinder/internal/ipcapi"
+	"Rewinder/internal/policy"
+)
+
+// Timed pauses also expire on this tick, or on the next capture if sooner.
+const autoPauseInterval = 30 * time.Second
+
+func (s *Services) autoPauseLoop() {
+	tick, stop := s.ticker(autoPauseInterval)
+	defer stop()
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-tick:
+			s.expirePauses()
+			s.evaluateAutoPause(nil)
+		}
+	}
+}
+
+// evaluateAutoPause applies the schedules and conditions of the config at
+// the current clock time. fg is the new foreground app, or nil to reuse the
+// last one seen. Changes are announced with onTrackingStateChanged.
+func (s *Services) evaluateAutoPause(fg *policy.Target) {
+	s.cfgMu.RLock()
+	ap := s.cfg.AutoPause
+	s.cfgMu.RUnlock()
+
+	s.trackingMu.Lock()
+	if fg != nil {
+		t := *fg
+		s.lastFG = &t
+	}
+	paused, reason := ap.Evaluate(s.now(), s.lastFG)
+	changed := paused != s.autoPaused || reason != s.autoReason
+	prevReason := s.autoReason
+	s.autoPaused, s.autoReason = paused, reason
+	manual := !s.trackingOn
+	s.trackingMu.Unlock()
+
+	if !changed {
+		return
+	}
+	ev := ipcapi.TrackingStateChangedEvent{State: "paused", Reason: reason, AtUTC: s.now().UTC().UnixMilli()}
+	if !paused {
+		if manual {
+			// Still paused by the user.
+			return
+		}
+		ev.State = "active"
+		ev.Reason = "auto-pause ended (" + prevReason + ")"
+	}
+	s.deps.EmitEvent("onTrackingStateChanged", ev)
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"Rewinder/internal/ipcapi"
+	"Rewinder/internal/policy"
+	"Rewinder/internal/replay"
+)
+
+// The auto-pause loop runs on the injected ticker and clock: quiet hours
+// pause and resume tracking, and a manual pause is not lifted when they end.
+func TestAutoPauseLoop(t *testing.T) {
+	clock := &replay.Clock{}
+	monday := time.Date(2026, 10, 19, 0, 0, 0, 0, time.Local)
+	clock.Set(monday.Add(12 * time.Hour))
+	ticks := make(chan time.Time)
+	evs := make(chan ipcapi.TrackingStateChangedEvent, 16)
+	svc := newTestServices(t, Dependencies{
+		EmitEvent: func(name string, v any) {
+			if ev, ok := v.(ipcapi.TrackingStateChangedEvent); ok {
+				evs <- ev
+			}
+		},
+		Now: clock.Now,
+		Ticker: func(d time.Duration) (<-chan time.Time, func()) {
+			if d != autoPauseInterval {
+				t.Errorf("ticker interval %v", d)
+			}
+			return ticks, func() {}
+		},
+	})
+	setConfig(svc, func(cfg *policy.Config) {
+		cfg.AutoPause = policy.AutoPause{Schedules: []policy.QuietHours{{Name: "night", Start: "22:00", End: "06:00"}}}
+	})
+	go svc.autoPauseLoop()
+
+	// The loop takes the next tick only once it has handled the last one.
+	tick := func(at time.Duration) {
+		clock.Set(monday.Add(at))
+		ticks <- clock.Now()
+	}
+	expect := func(state, reason string) {
+		t.Helper()
+		tick(clock.Now().Sub(monday)) // flush
+		select {
+		case ev := <-evs:
+			if ev.State != state || ev.Reason != reason || ev.AtUTC != clock.Now().UTC().UnixMilli() {
+				t.Fatalf("event %+v, want %s (%s) at %v", ev, state, reason, clock.Now())
+			}
+		default:
+			t.Fatalf("no event, want %s (%s)", state, reason)
+		}
+	}
+	expectNone := func() {
+		t.Helper()
+		tick(clock.Now().Sub(monday))
+		select {
+		case ev := <-evs:
+			t.Fatalf("unexpected event %+v", ev)
+		default:
+		}
+	}
+
+	tick(21*time.Hour + 59*time.Minute)
+	expectNone()
+	tick(22 * time.Hour)
+	expect("paused", "quiet hours: night")
+	tick(23 * time.Hour)
+	expectNone()
+	tick(30 * time.Hour) // Tuesday 06:00
+	expect("active", "auto-pause ended (quiet hours: night)")
+
+	svc.PauseTracking(nil)
+	<-evs
+	tick(46 * time.Hour) // Tuesday 22:00
+	expect("paused", "quiet hours: night")
+	tick(54 * time.Hour) // Wednesday 06:00
+	expectNone()
+	svc.trackingMu.RLock()
+	auto, on := svc.autoPaused, svc.trackingOn
+	svc.trackingMu.RUnlock()
+	if auto || on {
+		t.Fatalf("autoPaused %v, trackingOn %v after quiet hours under a manual pause", auto, on)
+	}
+}
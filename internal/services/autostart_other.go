@@ -0,0 +1,11 @@
+//go:build !windows
+
+package services
+
+import "errors"
+
+func IsAutostartEnabled() bool { return false }
+
+func SetAutostart(enabled bool) error {
+	return errors.New("autostart is not supported on this platform")
+}
@@ -0,0 +1,5 @@
+//go:build !windows
+
+package services
+
+func BlockOSInput(block bool) error { return nil }
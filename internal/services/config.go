@@ -0,0 +1,226 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"Rewinder/internal/ipcapi"
+	"Rewinder/internal/policy"
+	"Rewinder/internal/snapshot"
+)
+
+// GetConfig returns the config file as written, profiles included.
+func (s *Services) GetConfig() policy.Config {
+	s.cfgMu.RLock()
+	defer s.cfgMu.RUnlock()
+	return *s.fileCfg
+}
+
+func (s *Services) GetDefaultConfig() policy.Config {
+	return *policy.DefaultConfig()
+}
+
+// UpdateConfig validates cfg, writes it to the config file and applies it.
+// Invalid settings are reported per field and nothing is changed.
+func (s *Services) UpdateConfig(cfg policy.Config) (ipcapi.ConfigUpdateResult, error) {
+	cfg.Migrate()
+	if err := cfg.Validate(); err != nil {
+		var verr *policy.ValidationError
+		if errors.As(err, &verr) {
+			return ipcapi.ConfigUpdateResult{Errors: fieldErrors(verr)}, nil
+		}
+		return ipcapi.ConfigUpdateResult{}, err
+	}
+	return s.saveConfig("api", func(policy.Config) (*policy.Config, error) { return &cfg, nil })
+}
+
+// ResetConfig restores the default settings.
+func (s *Services) ResetConfig() (ipcapi.ConfigUpdateResult, error) {
+	return s.saveConfig("reset", func(policy.Config) (*policy.Config, error) { return policy.DefaultConfig(), nil })
+}
+
+func (s *Services) GetProfiles() ipcapi.ProfilesInfo {
+	s.cfgMu.RLock()
+	defer s.cfgMu.RUnlock()
+	return ipcapi.ProfilesInfo{Profiles: s.fileCfg.ProfileNames(), Active: s.fileCfg.ActiveProfile}
+}
+
+// SetActiveProfile switches to the profile name, or back to the base
+// settings when name is empty, and saves the choice to the config file.
+func (s *Services) SetActiveProfile(name string) (ipcapi.ConfigUpdateResult, error) {
+	var prev string
+	res, err := s.saveConfig("profile", func(cur policy.Config) (*policy.Config, error) {
+		if _, ok := cur.Profiles[name]; name != "" && !ok {
+			return nil, fmt.Errorf("unknown profile %q", name)
+		}
+		prev = cur.ActiveProfile
+		cur.ActiveProfile = name
+		return &cur, nil
+	})
+	if err != nil {
+		return res, err
+	}
+	s.deps.EmitEvent("onProfileChanged", ipcapi.ProfileChangedEvent{
+		Profile:  name,
+		Previous: prev,
+		AtUTC:    ipcapi.NowUTC(),
+	})
+	return res, nil
+}
+
+// saveConfig builds the new config file from the current one with edit,
+// writes it and installs it. The lock is held from edit to install so
+// concurrent changes cannot interleave and the file watcher sees the new
+// config installed.
+func (s *Services) saveConfig(source string, edit func(cur policy.Config) (*policy.Config, error)) (ipcapi.ConfigUpdateResult, error) {
+	s.cfgMu.Lock()
+	cfg, err := edit(*s.fileCfg)
+	if err != nil {
+		s.cfgMu.Unlock()
+		return ipcapi.ConfigUpdateResult{}, err
+	}
+	eff, err := cfg.Resolved()
+	if err != nil {
+		s.cfgMu.Unlock()
+		return ipcapi.ConfigUpdateResult{}, err
+	}
+	if err := policy.Save(s.cfgPath, cfg); err != nil {
+		s.cfgMu.Unlock()
+		return ipcapi.ConfigUpdateResult{}, err
+	}
+	s.fileCfg, s.cfg = cfg, eff
+	s.cfgMu.Unlock()
+	restart := s.applyConfig(cfg, eff, source)
+	return ipcapi.ConfigUpdateResult{Applied: true, RestartRequired: restart}, nil
+}
+
+// reloadConfig applies a config file change. Rules, clipboard and throttling
+// settings and the snapshot engine limits change in place; the storage dir
+// and enabling the clipboard vault take effect after a restart.
+func (s *Services) reloadConfig(cfg *policy.Config, err error) {
+	var eff *policy.Config
+	if err == nil {
+		eff, err = cfg.Resolved()
+	}
+	if err != nil {
+		ev := ipcapi.ConfigErrorEvent{Path: s.cfgPath, AtUTC: ipcapi.NowUTC()}
+		var verr *policy.ValidationError
+		if errors.As(err, &verr) {
+			ev.Errors = fieldErrors(verr)
+		} else {
+			ev.Errors = []ipcapi.ConfigFieldError{{Message: err.Error()}}
+		}
+		s.deps.EmitEvent("onConfigError", ev)
+		return
+	}
+	s.cfgMu.Lock()
+	if reflect.DeepEqual(s.fileCfg, cfg) {
+		// Our own write from saveConfig.
+		s.cfgMu.Unlock()
+		return
+	}
+	s.fileCfg, s.cfg = cfg, eff
+	s.cfgMu.Unlock()
+	s.applyConfig(cfg, eff, "file")
+}
+
+// applyConfig pushes an installed config to the snapshot engine and tells
+// every window and the tray about it. It returns the fields that need a
+// restart.
+func (s *Services) applyConfig(file, eff *policy.Config, source string) []string {
+	s.ss.SetLimits(s.engineConfig(eff))
+	s.rs.SetFileOpener(eff.Restore.FileOpener)
+	s.evaluateAutoPause(nil)
+	if s.sub != nil {
+		s.sub.SetCoalesce(coalesceOptions(eff))
+	}
+	if s.th != nil {
+		s.th.SetProfiles(file.ProfileNames(), file.ActiveProfile)
+	}
+
+	var restart []string
+	if eff.StorageDir != s.bootCfg.StorageDir {
+		restart = append(restart, "storageDir")
+	}
+	if eff.Clipboard.VaultEnabled && !s.bootCfg.Clipboard.VaultEnabled {
+		restart = append(restart, "clipboard.vaultEnabled")
+	}
+	s.deps.EmitEvent("onConfigChanged", ipcapi.ConfigChangedEvent{
+		Config:          *file,
+		Effective:       *eff,
+		Source:          source,
+		RestartRequired: restart,
+		AtUTC:           ipcapi.NowUTC(),
+	})
+	return restart
+}
+
+// appLimits resolves the per-app overrides of the current config for the
+// snapshot engine.
+func (s *Services) appLimits(appID, exePath string) snapshot.AppLimits {
+	s.cfgMu.RLock()
+	defer s.cfgMu.RUnlock()
+	eff := s.cfg.EffectiveFor(appID, exePath)
+	if len(eff.Overrides) == 0 {
+		return snapshot.AppLimits{}
+	}
+	t := s.cfg.Throttling
+	t.Threshold = eff.Threshold
+	t.BurstThreshold = eff.BurstThreshold
+	if time.Duration(t.SnapshotMinInterval) != eff.SnapshotMinInterval {
+		// The override beats the legacy per-app intervals.
+		t.SnapshotMinInterval = policy.Duration(eff.SnapshotMinInterval)
+		t.AppMinIntervals = nil
+	}
+	sig := significanceFromPolicy(t)
+	return snapshot.AppLimits{
+		Retention:          eff.Retention,
+		MaxSnapshotsPerApp: eff.MaxSnapshotsPerApp,
+		Significance:       &sig,
+	}
+}
+
+func effectivePolicy(eff policy.Effective) *ipcapi.EffectivePolicy {
+	p := &ipcapi.EffectivePolicy{
+		Retention:           eff.Retention.String(),
+		MaxSnapshots:        eff.MaxSnapshotsPerApp,
+		CaptureMinInterval:  eff.CaptureMinInterval.String(),
+		SnapshotMinInterval: eff.SnapshotMinInterval.String(),
+		Threshold:           eff.Threshold,
+		BurstThreshold:      eff.BurstThreshold,
+		Overrides:           eff.Overrides,
+	}
+	for id, on := range eff.Plugins {
+		if !on {
+			p.DisabledPlugins = append(p.DisabledPlugins, id)
+		}
+	}
+	sort.Strings(p.DisabledPlugins)
+	return p
+}
+
+func fieldErrors(verr *policy.ValidationError) []ipcapi.ConfigFieldError {
+	out := make([]ipcapi.ConfigFieldError, 0, len(verr.Errors))
+	for _, fe := range verr.Errors {
+		out = append(out, ipcapi.ConfigFieldError{Field: fe.Field, Message: fe.Message})
+	}
+	return out
+}
+
+func (s *Services) engineConfig(cfg *policy.Config) snapshot.EngineConfig {
+	return snapshot.EngineConfig{
+		AppLimits:          s.appLimits,
+		MaxSnapshotsPerApp: cfg.ResourceLimits.MaxSnapshotsPerApp,
+		MaxRAMBytes:        cfg.ResourceLimits.MaxRAMBytes,
+		MaxDiskBytes:       cfg.ResourceLimits.MaxDiskBytes,
+		Retention:          time.Duration(cfg.Retention),
+		StorageDir:         cfg.StorageDir,
+		ClipboardVault:     cfg.Clipboard.VaultEnabled,
+		Significance:       significanceFromPolicy(cfg.Throttling),
+		ReadOnly:           s.readOnly,
+		Now:                s.now,
+	}
+}
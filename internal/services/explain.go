@@ -0,0 +1,170 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"Rewinder/internal/ipcapi"
+	"Rewinder/internal/policy"
+	"Rewinder/internal/state"
+)
+
+const (
+	outcomeSnapshot     = "snapshot"
+	outcomeUnchanged    = "unchanged"
+	outcomeSkipped      = "skipped"
+	outcomeIngestFailed = "ingest-failed"
+	outcomeThrottled    = "throttled"
+)
+
+// captureRecord remembers the last capture of an app for ExplainTracking.
+type captureRecord struct {
+	target     policy.Target
+	at         time.Time
+	outcome    string
+	snapshotAt time.Time
+}
+
+type captureError struct {
+	err error
+	at  time.Time
+}
+
+func (s *Services) recordCapture(app *state.AppState, outcome string) {
+	s.diagMu.Lock()
+	defer s.diagMu.Unlock()
+	rec := s.seen[app.AppID]
+	if rec == nil {
+		rec = &captureRecord{}
+		s.seen[app.AppID] = rec
+	}
+	// A replayed skip knows only the app ID; keep what was seen before.
+	if app.ExecutablePath != "" || rec.target == (policy.Target{}) {
+		rec.target = policy.TargetOf(app)
+	}
+	rec.at = s.now()
+	rec.outcome = outcome
+	if outcome == outcomeSnapshot {
+		rec.snapshotAt = rec.at
+	}
+}
+
+func pausedUntil(until time.Time) string {
+	if until.IsZero() {
+		return ""
+	}
+	return " until " + until.Local().Format("Jan 2 15:04")
+}
+
+// recordCaptureError keeps the last error per app; "" is for captures that
+// failed before the app was known.
+func (s *Services) recordCaptureError(appID string, err error) {
+	s.diagMu.Lock()
+	s.captureErrs[appID] = captureError{err: err, at: s.now()}
+	s.diagMu.Unlock()
+}
+
+// ExplainTracking reports whether appID is tracked right now and which
+// setting or condition decides it. Pauses are checked first, then the rules
+// against the app's last capture, then the outcome of that capture.
+func (s *Services) ExplainTracking(appID string) ipcapi.TrackingExplanation {
+	return s.explain(appID, func() string {
+		for _, a := range s.ss.GetApps() {
+			if a.AppID == appID {
+				return a.ExecutablePath
+			}
+		}
+		return ""
+	})
+}
+
+// explain calls historyExe only for apps not captured since start.
+func (s *Services) explain(appID string, historyExe func() string) ipcapi.TrackingExplanation {
+	ex := ipcapi.TrackingExplanation{AppID: appID}
+
+	s.diagMu.Lock()
+	var rec captureRecord
+	rec0 := s.seen[appID]
+	if rec0 != nil {
+		rec = *rec0
+	}
+	unknownErr, appErr := s.captureErrs[""], s.captureErrs[appID]
+	s.diagMu.Unlock()
+	if rec0 != nil {
+		ex.LastSeenUTC = rec.at.UTC().UnixMilli()
+		ex.LastOutcome = rec.outcome
+		if !rec.snapshotAt.IsZero() {
+			ex.LastSnapshotAt = rec.snapshotAt.UTC().UnixMilli()
+		}
+	}
+
+	s.trackingMu.RLock()
+	on := s.trackingOn
+	paused := s.appPaused[appID]
+	globalUntil, appUntil := s.pauseUntil[""], s.pauseUntil[appID]
+	auto, autoReason := s.autoPaused, s.autoReason
+	s.trackingMu.RUnlock()
+	switch {
+	case !on:
+		ex.State, ex.Reason, ex.Detail = "paused", "global-pause", "Tracking is paused for all apps"+pausedUntil(globalUntil)
+		return ex
+	case auto:
+		ex.State, ex.Reason, ex.Detail = "paused", "auto-pause", "Tracking is paused automatically: "+autoReason
+		return ex
+	case paused:
+		ex.State, ex.Reason, ex.Detail = "paused", "app-paused", "Tracking is paused for this app"+pausedUntil(appUntil)
+		return ex
+	}
+
+	if rec0 == nil {
+		// Known only from saved history; judge the rules by its executable.
+		if exe := historyExe(); exe != "" {
+			rec.target = policy.Target{ExePath: exe}
+			rec0 = &rec
+		}
+	}
+	if rec0 == nil {
+		ex.State, ex.Reason, ex.Detail = "unseen", "not-captured", "The app has not been in the foreground since Rewinder started"
+		if unknownErr.err != nil {
+			ex.Detail += fmt.Sprintf("; the last capture failed at %s: %v", unknownErr.at.Format(time.TimeOnly), unknownErr.err)
+		}
+		return ex
+	}
+
+	s.cfgMu.RLock()
+	d := s.cfg.Rules.Explain(rec.target)
+	s.cfgMu.RUnlock()
+	if d.Rule != nil {
+		ex.Rule = &ipcapi.TrackingRule{
+			Index:   d.RuleIndex,
+			Action:  d.Rule.Action,
+			Field:   d.Rule.Field,
+			Match:   d.Rule.Match,
+			Pattern: d.Rule.Pattern,
+		}
+	}
+	if !d.Tracked {
+		ex.State, ex.Reason, ex.Detail = "excluded", "rule", "Excluded by "+d.Reason
+		if d.Rule == nil {
+			ex.Reason = "allowlist"
+		}
+		return ex
+	}
+
+	ex.Tracked = true
+	ex.State, ex.Reason, ex.Detail = "active", "tracked", "Tracked: "+d.Reason
+	switch rec.outcome {
+	case outcomeIngestFailed:
+		ex.State, ex.Reason, ex.Detail = "error", "ingest-failed", "The last capture could not be stored"
+		if appErr.err != nil {
+			ex.Detail += ": " + appErr.err.Error()
+		}
+	case outcomeThrottled:
+		ex.Reason = "throttled"
+		ex.Detail = "Tracked; the last capture came within the minimum capture interval and was skipped"
+	case outcomeUnchanged:
+		ex.Reason = "throttled"
+		ex.Detail = "Tracked; the last capture was below the significance threshold or unchanged, so no snapshot was taken"
+	}
+	return ex
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"Rewinder/internal/policy"
+	"Rewinder/internal/replay"
+	"Rewinder/internal/state"
+)
+
+// queueCapture hands out its results in order.
+type queueCapture struct {
+	apps []*state.AppState
+	errs []error
+}
+
+func (q *queueCapture) push(app *state.AppState, err error) {
+	q.apps = append(q.apps, app)
+	q.errs = append(q.errs, err)
+}
+
+func (q *queueCapture) CaptureForeground() (*state.AppState, error) {
+	app, err := q.apps[0], q.errs[0]
+	q.apps, q.errs = q.apps[1:], q.errs[1:]
+	return app, err
+}
+
+func editorApp(at time.Time) *state.AppState {
+	return &state.AppState{
+		AppID:          "editor",
+		ExecutablePath: "/opt/editor/editor",
+		Windows:        []state.WindowState{{HWND: 1, Rect: state.Rect{Right: 800, Bottom: 600}, IsForeground: true}},
+		Timestamp:      at,
+	}
+}
+
+func TestExplainCaptureOutcomes(t *testing.T) {
+	clock := &replay.Clock{}
+	clock.Set(time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC))
+	capture := &queueCapture{}
+	svc := newTestServices(t, Dependencies{EmitEvent: func(string, any) {}, Now: clock.Now, Capture: capture})
+	interval := policy.Duration(10 * time.Second)
+	setConfig(svc, func(cfg *policy.Config) {
+		cfg.Throttling.CaptureMinInterval = policy.Duration(500 * time.Millisecond)
+		cfg.Overrides = []policy.AppOverride{{Match: "editor", CaptureMinInterval: &interval}}
+	})
+	explain := func(appID string, outcome, reason string) {
+		t.Helper()
+		ex := svc.ExplainTracking(appID)
+		if ex.LastOutcome != outcome || ex.Reason != reason || ex.LastSeenUTC != clock.Now().UnixMilli() {
+			t.Fatalf("%s: %+v, want outcome %q, reason %q, seen at %v", appID, ex, outcome, reason, clock.Now())
+		}
+	}
+
+	// A capture that fails before the app is known is reported for apps
+	// not seen yet.
+	capture.push(nil, errors.New("no foreground window"))
+	svc.captureForeground(false)
+	if ex := svc.ExplainTracking("editor"); ex.State != "unseen" || !strings.Contains(ex.Detail, "no foreground window") {
+		t.Fatalf("%+v", ex)
+	}
+
+	clock.Set(clock.Now().Add(time.Second))
+	capture.push(editorApp(clock.Now()), nil)
+	svc.captureForeground(false)
+	explain("editor", outcomeSnapshot, "tracked")
+	if ex := svc.ExplainTracking("editor"); strings.Contains(ex.Detail, "no foreground window") {
+		t.Fatalf("another capture's error reported for editor: %+v", ex)
+	}
+
+	// Within the global interval nothing is captured; the skip counts for
+	// the app captured last.
+	clock.Set(clock.Now().Add(100 * time.Millisecond))
+	svc.captureForeground(false)
+	explain("editor", outcomeThrottled, "throttled")
+
+	// Past the global interval but within the app's own.
+	clock.Set(clock.Now().Add(time.Second))
+	capture.push(editorApp(clock.Now()), nil)
+	svc.captureForeground(false)
+	explain("editor", outcomeThrottled, "throttled")
+	if len(capture.apps) != 0 {
+		t.Fatal("capture not taken")
+	}
+
+	if ex := svc.ExplainTracking("shell"); ex.State != "unseen" || !strings.Contains(ex.Detail, "no foreground window") {
+		t.Fatalf("%+v", ex)
+	}
+}
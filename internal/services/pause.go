@@ -0,0 +1,155 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"Rewinder/internal/ipcapi"
+)
+
+const pauseStateFile = "pause.json"
+
+// pauseState is the on-disk form of the manual pauses, so a pause survives
+// a restart and still ends on time.
+type pauseState struct {
+	Global      bool                 `json:"global"`
+	GlobalUntil time.Time            `json:"globalUntil,omitempty"`
+	Apps        map[string]time.Time `json:"apps,omitempty"` // zero time means until resumed
+}
+
+func (s *Services) PauseTracking(appID *string) {
+	s.PauseTrackingFor(appID, 0)
+}
+
+// PauseTrackingFor pauses tracking of appID, or of everything when appID is
+// nil, for d. A d of zero or less pauses until ResumeTracking.
+func (s *Services) PauseTrackingFor(appID *string, d time.Duration) {
+	var until time.Time
+	if d > 0 {
+		until = s.now().Add(d)
+	}
+	key := ""
+	s.trackingMu.Lock()
+	if appID == nil {
+		s.trackingOn = false
+	} else {
+		key = *appID
+		s.appPaused[key] = true
+	}
+	s.pauseUntil[key] = until
+	s.trackingMu.Unlock()
+	s.savePauseState()
+	s.deps.EmitEvent("onTrackingStateChanged", s.pauseEvent(appID, "paused by user", until))
+}
+
+func (s *Services) ResumeTracking(appID *string) {
+	s.resume(appID, "resumed by user")
+}
+
+func (s *Services) resume(appID *string, reason string) {
+	s.trackingMu.Lock()
+	if appID == nil {
+		s.trackingOn = true
+		delete(s.pauseUntil, "")
+	} else {
+		delete(s.appPaused, *appID)
+		delete(s.pauseUntil, *appID)
+	}
+	s.trackingMu.Unlock()
+	s.savePauseState()
+	s.deps.EmitEvent("onTrackingStateChanged", ipcapi.TrackingStateChangedEvent{
+		AppID:  appID,
+		State:  "active",
+		Reason: reason,
+		AtUTC:  ipcapi.NowUTC(),
+	})
+}
+
+// expirePauses resumes every timed pause whose end has passed.
+func (s *Services) expirePauses() {
+	now := s.now()
+	var expired []string
+	s.trackingMu.RLock()
+	for key, until := range s.pauseUntil {
+		if !until.IsZero() && !now.Before(until) {
+			expired = append(expired, key)
+		}
+	}
+	s.trackingMu.RUnlock()
+	for _, key := range expired {
+		if key == "" {
+			s.resume(nil, "pause ended")
+		} else {
+			id := key
+			s.resume(&id, "pause ended")
+		}
+	}
+}
+
+func (s *Services) pauseEvent(appID *string, reason string, until time.Time) ipcapi.TrackingStateChangedEvent {
+	ev := ipcapi.TrackingStateChangedEvent{
+		AppID:  appID,
+		State:  "paused",
+		Reason: reason,
+		AtUTC:  ipcapi.NowUTC(),
+	}
+	if !until.IsZero() {
+		ev.UntilUTC = until.UTC().UnixMilli()
+		ev.RemainingMs = until.Sub(s.now()).Milliseconds()
+	}
+	return ev
+}
+
+func (s *Services) pauseStatePath() string {
+	return filepath.Join(filepath.Dir(s.cfgPath), pauseStateFile)
+}
+
+// savePauseState writes the manual pauses, unless another instance owns the
+// store and with it pause.json.
+func (s *Services) savePauseState() {
+	if s.readOnly {
+		return
+	}
+	s.trackingMu.RLock()
+	st := pauseState{Global: !s.trackingOn, GlobalUntil: s.pauseUntil[""], Apps: map[string]time.Time{}}
+	for id := range s.appPaused {
+		st.Apps[id] = s.pauseUntil[id]
+	}
+	s.trackingMu.RUnlock()
+	b, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return
+	}
+	path := s.pauseStatePath()
+	_ = os.MkdirAll(filepath.Dir(path), 0o755)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return
+	}
+	_ = os.Rename(tmp, path)
+}
+
+// loadPauseState restores the pauses saved before the last exit. Pauses that
+// ended meanwhile are dropped on the first expirePauses.
+func (s *Services) loadPauseState() {
+	b, err := os.ReadFile(s.pauseStatePath())
+	if err != nil {
+		return
+	}
+	var st pauseState
+	if json.Unmarshal(b, &st) != nil {
+		return
+	}
+	s.trackingMu.Lock()
+	defer s.trackingMu.Unlock()
+	if st.Global {
+		s.trackingOn = false
+		s.pauseUntil[""] = st.GlobalUntil
+	}
+	for id, until := range st.Apps {
+		s.appPaused[id] = true
+		s.pauseUntil[id] = until
+	}
+}
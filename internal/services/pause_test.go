@@ -0,0 +1,90 @@
+package services
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"Rewinder/internal/policy"
+	"Rewinder/internal/replay"
+)
+
+func pauseSnapshot(s *Services) (on bool, apps map[string]time.Time, until time.Time) {
+	s.trackingMu.RLock()
+	defer s.trackingMu.RUnlock()
+	apps = map[string]time.Time{}
+	for id := range s.appPaused {
+		apps[id] = s.pauseUntil[id]
+	}
+	return s.trackingOn, apps, s.pauseUntil[""]
+}
+
+// Pauses survive a restart, and a timed one still ends on time.
+func TestPauseStateRoundTrip(t *testing.T) {
+	clock := &replay.Clock{}
+	start := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	clock.Set(start)
+	deps := Dependencies{EmitEvent: func(string, any) {}, Now: clock.Now}
+	svc := newTestServices(t, deps)
+	editor, shell := "editor", "shell"
+	svc.PauseTrackingFor(nil, time.Hour)
+	svc.PauseTracking(&editor)
+	svc.PauseTrackingFor(&shell, 10*time.Minute)
+	svc.Stop()
+
+	again, err := New(deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer again.Stop()
+	on, apps, until := pauseSnapshot(again)
+	if on || !until.Equal(start.Add(time.Hour)) || len(apps) != 2 ||
+		!apps[editor].IsZero() || !apps[shell].Equal(start.Add(10*time.Minute)) {
+		t.Fatalf("loaded on=%v until=%v apps=%v", on, until, apps)
+	}
+
+	clock.Set(start.Add(10 * time.Minute))
+	again.expirePauses()
+	if on, apps, _ := pauseSnapshot(again); on || len(apps) != 1 {
+		t.Fatalf("after the app pause ended: on=%v apps=%v", on, apps)
+	}
+	clock.Set(start.Add(time.Hour))
+	again.expirePauses()
+	if on, apps, _ := pauseSnapshot(again); !on || len(apps) != 1 {
+		t.Fatalf("after the global pause ended: on=%v apps=%v", on, apps)
+	}
+}
+
+// A pause.json that cannot be read leaves tracking on.
+func TestPauseStateCorrupt(t *testing.T) {
+	svc := newTestServices(t, Dependencies{EmitEvent: func(string, any) {}})
+	if err := os.WriteFile(svc.pauseStatePath(), []byte(`{"global": tru`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	svc.loadPauseState()
+	if on, apps, _ := pauseSnapshot(svc); !on || len(apps) != 0 {
+		t.Fatalf("on=%v apps=%v", on, apps)
+	}
+}
+
+// Only the instance that owns the store writes pause.json.
+func TestPauseStateReadOnly(t *testing.T) {
+	owner := newTestServices(t, Dependencies{EmitEvent: func(string, any) {}})
+	t.Setenv(policy.EnvLockMode, policy.LockReadOnly)
+	viewer, err := New(Dependencies{EmitEvent: func(string, any) {}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer viewer.Stop()
+	if !viewer.ReadOnly() {
+		t.Fatal("second instance is not read-only")
+	}
+	viewer.PauseTracking(nil)
+	if _, err := os.Stat(owner.pauseStatePath()); !os.IsNotExist(err) {
+		t.Fatalf("read-only instance wrote pause.json: %v", err)
+	}
+	owner.PauseTracking(nil)
+	if _, err := os.Stat(owner.pauseStatePath()); err != nil {
+		t.Fatal(err)
+	}
+}
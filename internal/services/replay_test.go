@@ -0,0 +1,186 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"Rewinder/internal/events"
+	"Rewinder/internal/policy"
+	"Rewinder/internal/replay"
+	"Rewinder/internal/state"
+)
+
+func newReplayServices(t *testing.T) *Services {
+	t.Helper()
+	return newTestServices(t, Dependencies{EmitEvent: func(string, any) {}})
+}
+
+// A replay runs on the recording's clock, so retention must not expire
+// snapshots just because the session was recorded days ago.
+func TestReplayOldRecording(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.rec")
+	start := time.Now().Add(-72 * time.Hour)
+	r, err := replay.NewRecorder(path, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const captures = 20
+	for i := 0; i < captures; i++ {
+		at := start.Add(time.Duration(i) * 10 * time.Minute)
+		r.Event(events.SystemEvent{Type: events.EventForegroundChanged}, at)
+		r.Capture(&state.AppState{
+			AppID:          "editor",
+			PID:            4242,
+			ExecutablePath: "/opt/editor/editor",
+			Windows: []state.WindowState{{
+				HWND:         1,
+				Title:        fmt.Sprintf("notes %d - Editor", i),
+				Rect:         state.Rect{Left: int32(i * 100), Right: int32(i*100 + 800), Bottom: 600},
+				IsForeground: true,
+			}},
+			Timestamp: at,
+		}, at)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := replay.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := newReplayServices(t)
+	if err := svc.Replay(context.Background(), rec, 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, app := range svc.GetApps() {
+		if app.AppID == "editor" {
+			if app.SnapshotCount != captures {
+				t.Fatalf("%d snapshots kept, want %d", app.SnapshotCount, captures)
+			}
+			return
+		}
+	}
+	t.Fatal("editor not tracked")
+}
+
+// Captures are recorded as they are stored, with secrets redacted.
+func TestRecordingIsRedacted(t *testing.T) {
+	const secret = "586xwjj1fIyhIxxGoTCjA4qLneL_ydbPryc7EUbakgo"
+	svc := newReplayServices(t)
+	path := filepath.Join(t.TempDir(), "session.rec")
+	r, err := replay.NewRecorder(path, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.rec = r
+	fake := &replay.FakeCapture{}
+	svc.cap = fake
+	fake.Load([]replay.Capture{{App: &state.AppState{
+		AppID:          "tool",
+		ExecutablePath: "/opt/tool/tool",
+		CommandLine:    "/opt/tool/tool --token " + secret,
+		Windows:        []state.WindowState{{HWND: 1, Title: "mail me@example.com", IsForeground: true}},
+		Timestamp:      time.Now(),
+	}}})
+	r.Event(events.SystemEvent{Type: events.EventForegroundChanged}, time.Now())
+	svc.captureForeground(false)
+	_ = r.Close()
+
+	rec, err := replay.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.Steps) != 1 || len(rec.Steps[0].Captures) != 1 {
+		t.Fatalf("recording has %d steps", len(rec.Steps))
+	}
+	app := rec.Steps[0].Captures[0].App
+	if app.CommandLine != "/opt/tool/tool --token [secret]" || app.Windows[0].Title != "mail [email]" {
+		t.Errorf("recorded %q, %q", app.CommandLine, app.Windows[0].Title)
+	}
+}
+
+// Apps that are not tracked leave only their ID in a recording, and process
+// events lose their command lines.
+func TestRecordingLeavesOutUntracked(t *testing.T) {
+	const secret = "hunter2-Zq8vLw3pX9"
+	svc := newReplayServices(t)
+	setConfig(svc, func(cfg *policy.Config) {
+		cfg.Rules.Items = append([]policy.Rule{{
+			Action: policy.ActionExclude, Field: policy.FieldExeName, Match: policy.MatchExact, Pattern: "vault",
+		}}, cfg.Rules.Items...)
+	})
+
+	path := filepath.Join(t.TempDir(), "session.rec")
+	r, err := replay.NewRecorder(path, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.rec = r
+	fake := &replay.FakeCapture{}
+	svc.cap = fake
+	fake.Load([]replay.Capture{{App: &state.AppState{
+		AppID:          "vault",
+		ExecutablePath: "/opt/vault/vault",
+		CommandLine:    "/opt/vault/vault --unlock " + secret,
+		Windows:        []state.WindowState{{HWND: 1, Title: "Bank login - Vault", IsForeground: true}},
+		Timestamp:      time.Now(),
+	}}})
+	r.Event(events.SystemEvent{Type: events.EventProcessStarted, PID: 77, Metadata: map[string]any{
+		"exe": "/usr/bin/curl", "name": "curl", "cmdline": "curl -u admin:" + secret + " https://example.com",
+	}}, time.Now())
+	svc.captureForeground(false)
+	_ = r.Close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, leak := range []string{secret, "Bank login", "/opt/vault", "cmdline"} {
+		if bytes.Contains(raw, []byte(leak)) {
+			t.Errorf("recording contains %q:\n%s", leak, raw)
+		}
+	}
+
+	rec, err := replay.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.Steps) != 1 || len(rec.Steps[0].Captures) != 1 {
+		t.Fatalf("recording has %d steps", len(rec.Steps))
+	}
+	if c := rec.Steps[0].Captures[0]; !c.Skipped || c.App.AppID != "vault" {
+		t.Fatalf("capture = %+v, %+v", c, c.App)
+	}
+	if rec.Steps[0].Event.Metadata["name"] != "curl" {
+		t.Errorf("metadata = %v", rec.Steps[0].Event.Metadata)
+	}
+
+	// On replay the skip is reported, not a capture error.
+	replayed := newReplayServices(t)
+	if err := replayed.Replay(context.Background(), rec, 0); err != nil {
+		t.Fatal(err)
+	}
+	replayed.diagMu.Lock()
+	got := replayed.seen["vault"]
+	replayed.diagMu.Unlock()
+	if got == nil || got.outcome != outcomeSkipped {
+		t.Fatalf("replayed outcome = %+v", got)
+	}
+}
@@ -2,6 +2,10 @@ package services
 
 import (
 	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -9,6 +13,7 @@ import (
 	"Rewinder/internal/ipcapi"
 	"Rewinder/internal/plugins"
 	"Rewinder/internal/policy"
+	"Rewinder/internal/replay"
 	"Rewinder/internal/restore"
 	"Rewinder/internal/snapshot"
 	"Rewinder/internal/state"
@@ -19,54 +24,142 @@ type Dependencies struct {
 	EmitEvent          func(name string, data any)
 	OnOverlayRequested func()
 	ShowTimelineWindow func()
+
+	// Now is the clock for schedules; nil means time.Now.
+	Now func() time.Time
+	// Ticker drives the periodic auto-pause check; nil means time.NewTicker.
+	// It returns the tick channel and a function that stops it.
+	Ticker func(d time.Duration) (<-chan time.Time, func())
+	// Capture replaces the platform capture engine, e.g. in a replay.
+	Capture Capturer
+}
+
+// Capturer captures the app in the foreground.
+type Capturer interface {
+	CaptureForeground() (*state.AppState, error)
 }
 
 type Services struct {
 	deps Dependencies
 
-	cfgMu sync.RWMutex
-	cfg   *policy.Config
+	cfgMu   sync.RWMutex
+	cfg     *policy.Config // settings in effect, with the active profile applied
+	fileCfg *policy.Config // the config file as written; both replaced on change, never modified
+	cfgPath string
+	bootCfg *policy.Config // the config the snapshot engine was created with
+
+	lock     *snapshot.StoreLock // nil when read-only
+	readOnly bool                // another instance owns the store; no capture
 
 	ev  *events.Bus
-	cap *state.CaptureEngine
+	sub *events.Subscription // what captureLoop reads
+	cap Capturer
+	rec *replay.Recorder // set when the session is being recorded
 	ss  *snapshot.Engine
 	rs  *restore.Engine
 	th  *trayhotkey.Manager
 	pl  *plugins.Registry
 
+	diagMu      sync.Mutex
+	seen        map[string]*captureRecord
+	captureErrs map[string]captureError
+
+	// lastCapture, lastApp and appCapture are only used by the capture
+	// goroutine.
+	lastCapture time.Time
+	lastApp     string
+	appCapture  map[string]time.Time
+
+	now    func() time.Time
+	ticker func(d time.Duration) (<-chan time.Time, func())
+
 	trackingMu sync.RWMutex
 	trackingOn bool
 	appPaused  map[string]bool
+	pauseUntil map[string]time.Time // "" is the global pause; zero means until resumed
+	autoPaused bool
+	autoReason string
+	lastFG     *policy.Target
+	started    bool
 	stopOnce   sync.Once
 	stopCh     chan struct{}
 }
 
-func New(deps Dependencies) *Services {
-	cfg := policy.DefaultConfig()
+// New loads the config and opens the snapshot store. When another instance
+// holds the store it is opened read-only, or with policy.LockFail New fails
+// with an error wrapping snapshot.ErrStoreLocked.
+func New(deps Dependencies) (*Services, error) {
+	cfgPath := policy.DefaultConfigPath()
+	cfg, err := policy.Load(cfgPath)
+	if err != nil {
+		slog.Warn("config unreadable, using defaults", "path", cfgPath, "err", err)
+		cfg = policy.DefaultConfig()
+	}
+	fileCfg := cfg
+	if cfg, err = fileCfg.Resolved(); err != nil {
+		slog.Warn("profile not applied, using base settings", "profile", fileCfg.ActiveProfile, "err", err)
+		cfg = fileCfg
+	}
+	lock, err := snapshot.LockStore(cfg.StorageDir)
+	readOnly := errors.Is(err, snapshot.ErrStoreLocked)
+	if readOnly {
+		if policy.LockMode() == policy.LockFail {
+			return nil, err
+		}
+		slog.Info("snapshot store opened read-only", "err", err)
+	} else if err != nil {
+		slog.Warn("snapshot store not locked", "dir", cfg.StorageDir, "err", err)
+	}
 	bus := events.NewBus(1024)
-	ss := snapshot.NewEngine(snapshot.EngineConfig{
-		MaxSnapshotsPerApp: cfg.ResourceLimits.MaxSnapshotsPerApp,
-		MaxRAMBytes:        cfg.ResourceLimits.MaxRAMBytes,
-		MaxDiskBytes:       cfg.ResourceLimits.MaxDiskBytes,
-		Retention:          cfg.Retention,
-		StorageDir:         cfg.StorageDir,
-	})
 
-	return &Services{
-		deps:       deps,
-		cfg:        cfg,
-		ev:         bus,
-		cap:        state.NewCaptureEngine(),
-		ss:         ss,
-		rs:         restore.NewEngine(),
-		pl:         plugins.DefaultRegistry(),
-		trackingOn: true,
-		appPaused:  map[string]bool{},
-		stopCh:     make(chan struct{}),
+	s := &Services{
+		deps:        deps,
+		cfg:         cfg,
+		fileCfg:     fileCfg,
+		cfgPath:     cfgPath,
+		bootCfg:     cfg,
+		lock:        lock,
+		readOnly:    readOnly,
+		ev:          bus,
+		cap:         deps.Capture,
+		rs:          restore.NewEngine(),
+		pl:          plugins.DefaultRegistry(),
+		trackingOn:  true,
+		appPaused:   map[string]bool{},
+		pauseUntil:  map[string]time.Time{},
+		seen:        map[string]*captureRecord{},
+		captureErrs: map[string]captureError{},
+		appCapture:  map[string]time.Time{},
+		stopCh:      make(chan struct{}),
+	}
+	s.now = deps.Now
+	if s.now == nil {
+		s.now = time.Now
 	}
+	s.ticker = deps.Ticker
+	if s.ticker == nil {
+		s.ticker = func(d time.Duration) (<-chan time.Time, func()) {
+			t := time.NewTicker(d)
+			return t.C, t.Stop
+		}
+	}
+	if s.cap == nil {
+		s.cap = state.NewCaptureEngine()
+	}
+	s.ss = snapshot.NewEngine(s.engineConfig(cfg))
+	s.rs.SetFileOpener(cfg.Restore.FileOpener)
+	s.loadPauseState()
+	return s, nil
+}
+
+// ReadOnly reports whether the store belongs to another instance, so this
+// one only browses it.
+func (s *Services) ReadOnly() bool {
+	return s.readOnly
 }
 
 func (s *Services) Start(ctx context.Context) {
+	s.started = true
 	s.th = trayhotkey.NewManager(trayhotkey.Dependencies{
 		OnOpenTimeline: func() {
 			s.deps.EmitEvent("onShowTimelineRequested", nil)
@@ -77,6 +170,14 @@ func (s *Services) Start(ctx context.Context) {
 		OnPauseTracking: func() {
 			s.PauseTracking(nil)
 		},
+		OnPauseTrackingFor: func(d time.Duration) {
+			s.PauseTrackingFor(nil, d)
+		},
+		OnPauseUntilTomorrow: func() {
+			now := s.now()
+			y, m, d := now.Date()
+			s.PauseTrackingFor(nil, time.Date(y, m, d+1, 0, 0, 0, 0, now.Location()).Sub(now))
+		},
 		OnResumeTracking: func() {
 			s.ResumeTracking(nil)
 		},
@@ -92,21 +193,57 @@ func (s *Services) Start(ctx context.Context) {
 				s.deps.OnOverlayRequested()
 			}
 		},
+		OnSelectProfile: func(name string) {
+			if _, err := s.SetActiveProfile(name); err != nil {
+				slog.Warn("profile switch from the tray failed", "profile", name, "err", err)
+			}
+		},
 	})
 	s.th.Start()
+	s.cfgMu.RLock()
+	s.th.SetProfiles(s.fileCfg.ProfileNames(), s.fileCfg.ActiveProfile)
+	s.cfgMu.RUnlock()
 
-	go func() {
-		_ = s.ev.StartWindowsSources()
-	}()
-
-	go s.captureLoop()
+	if !s.readOnly {
+		// Newer events matter more: each one triggers a capture of the
+		// current foreground anyway.
+		s.cfgMu.RLock()
+		co := coalesceOptions(s.cfg)
+		s.cfgMu.RUnlock()
+		s.sub = s.ev.Subscribe(events.SubscribeOptions{Name: "capture", Overflow: events.DropOldest, Coalesce: co})
+		if path := os.Getenv(policy.EnvRecord); path != "" {
+			rec, err := replay.NewRecorder(path, s.now())
+			if err != nil {
+				slog.Warn("session not recorded", "path", path, "err", err)
+			} else {
+				s.rec = rec
+			}
+		}
+		for _, src := range events.DefaultSources(events.SourceOptions{ProcessScanInterval: s.processScanInterval}) {
+			if err := s.ev.AddSource(src); err != nil {
+				slog.Warn("event source not added", "source", src.Name(), "err", err)
+			}
+		}
+		go s.captureLoop()
+	}
+	s.expirePauses()
+	s.evaluateAutoPause(nil)
+	go s.autoPauseLoop()
+	go policy.Watch(s.cfgPath, 2*time.Second, s.stopCh, s.reloadConfig)
 
-	s.deps.EmitEvent("onTrackingStateChanged", ipcapi.TrackingStateChangedEvent{
-		AppID:  nil,
-		State:  "active",
-		Reason: "",
-		AtUTC:  ipcapi.NowUTC(),
-	})
+	s.trackingMu.RLock()
+	on, until := s.trackingOn, s.pauseUntil[""]
+	s.trackingMu.RUnlock()
+	if on {
+		s.deps.EmitEvent("onTrackingStateChanged", ipcapi.TrackingStateChangedEvent{
+			AppID:  nil,
+			State:  "active",
+			Reason: "",
+			AtUTC:  ipcapi.NowUTC(),
+		})
+	} else {
+		s.deps.EmitEvent("onTrackingStateChanged", s.pauseEvent(nil, "paused before restart", until))
+	}
 }
 
 func (s *Services) Stop() {
@@ -121,61 +258,76 @@ func (s *Services) Stop() {
 		if s.ss != nil {
 			_ = s.ss.Close()
 		}
+		if s.rec != nil {
+			_ = s.rec.Close()
+		}
+		_ = s.lock.Close()
 	})
 }
 
-func (s *Services) PauseTracking(appID *string) {
-	s.trackingMu.Lock()
-	defer s.trackingMu.Unlock()
-	if appID == nil {
-		s.trackingOn = false
-		s.deps.EmitEvent("onTrackingStateChanged", ipcapi.TrackingStateChangedEvent{
-			AppID:  nil,
-			State:  "paused",
-			Reason: "paused by user",
-			AtUTC:  ipcapi.NowUTC(),
-		})
-		return
-	}
-	s.appPaused[*appID] = true
-	s.deps.EmitEvent("onTrackingStateChanged", ipcapi.TrackingStateChangedEvent{
-		AppID:  appID,
-		State:  "paused",
-		Reason: "paused by user",
-		AtUTC:  ipcapi.NowUTC(),
-	})
-}
-
-func (s *Services) ResumeTracking(appID *string) {
-	s.trackingMu.Lock()
-	defer s.trackingMu.Unlock()
-	if appID == nil {
-		s.trackingOn = true
-		s.deps.EmitEvent("onTrackingStateChanged", ipcapi.TrackingStateChangedEvent{
-			AppID:  nil,
-			State:  "active",
-			Reason: "resumed by user",
-			AtUTC:  ipcapi.NowUTC(),
-		})
-		return
-	}
-	delete(s.appPaused, *appID)
-	s.deps.EmitEvent("onTrackingStateChanged", ipcapi.TrackingStateChangedEvent{
-		AppID:  appID,
-		State:  "active",
-		Reason: "resumed by user",
-		AtUTC:  ipcapi.NowUTC(),
-	})
+// GetEventStats reports emitted, delivered and dropped events per subscriber,
+// and the health of the event sources.
+func (s *Services) GetEventStats() events.BusStats {
+	return s.ev.Stats()
 }
 
 func (s *Services) GetApps() []ipcapi.AppSummary {
-	return s.ss.GetApps()
+	apps := s.ss.GetApps()
+	for i := range apps {
+		exe := apps[i].ExecutablePath
+		ex := s.explain(apps[i].AppID, func() string { return exe })
+		apps[i].TrackingState = ex.State
+		apps[i].TrackingDetail = ex.Detail
+
+		s.cfgMu.RLock()
+		eff := s.cfg.EffectiveFor(apps[i].AppID, exe)
+		s.cfgMu.RUnlock()
+		apps[i].Policy = effectivePolicy(eff)
+	}
+	return apps
 }
 
 func (s *Services) GetTimeline(appID string) []ipcapi.SnapshotMeta {
 	return s.ss.GetTimeline(appID)
 }
 
+func (s *Services) GetBranches(appID string) []ipcapi.BranchInfo {
+	return s.ss.GetBranches(appID)
+}
+
+func (s *Services) Search(query string, filter ipcapi.SearchFilter) []ipcapi.SearchHit {
+	return s.ss.Search(query, filter)
+}
+
+func (s *Services) GetStorageStats() ipcapi.StorageStats {
+	return s.ss.Stats()
+}
+
+func (s *Services) ForgetApp(appID string) error {
+	if err := s.ss.Forget(appID); err != nil {
+		return err
+	}
+	s.deps.EmitEvent("onHistoryDeleted", ipcapi.HistoryDeletedEvent{
+		AppID: appID,
+		AtUTC: ipcapi.NowUTC(),
+	})
+	return nil
+}
+
+func (s *Services) PurgeHistory(appID string, from, to time.Time) (int, error) {
+	n, err := s.ss.Purge(appID, from, to)
+	if n > 0 {
+		s.deps.EmitEvent("onHistoryDeleted", ipcapi.HistoryDeletedEvent{
+			AppID:   appID,
+			FromUTC: from.UTC().UnixMilli(),
+			ToUTC:   to.UTC().UnixMilli(),
+			Removed: n,
+			AtUTC:   ipcapi.NowUTC(),
+		})
+	}
+	return n, err
+}
+
 func (s *Services) Restore(appID string, snapshotID string) error {
 	s.deps.EmitEvent("onRestoreProgress", ipcapi.RestoreProgressEvent{
 		AppID:      appID,
@@ -205,6 +357,16 @@ func (s *Services) Restore(appID string, snapshotID string) error {
 		s.deps.EmitEvent("onRestoreError", ipcapi.RestoreErrorEvent{AppID: appID, SnapshotID: snapshotID, Error: err.Error()})
 		return err
 	}
+
+	// Whatever happens next continues from the restored state.
+	if branchID, created, err := s.ss.BeginBranch(appID, snapshotID); err == nil && created {
+		s.deps.EmitEvent("onBranchCreated", ipcapi.BranchCreatedEvent{
+			AppID:            appID,
+			BranchID:         branchID,
+			ParentSnapshotID: snapshotID,
+			AtUTC:            ipcapi.NowUTC(),
+		})
+	}
 	progress("done", 100, "Restore completed")
 	return nil
 }
@@ -214,61 +376,134 @@ func (s *Services) captureLoop() {
 		select {
 		case <-s.stopCh:
 			return
-		case ev := <-s.ev.Events():
+		case ev := <-s.sub.Events():
+			if s.rec != nil {
+				s.rec.Event(ev, s.now())
+			}
 			s.handleSystemEvent(ev)
 		}
 	}
 }
 
-func (s *Services) shouldTrack(appID string, exePath string, windowClass string) bool {
+func (s *Services) shouldTrack(app *state.AppState) bool {
 	s.trackingMu.RLock()
-	on := s.trackingOn
-	paused := s.appPaused[appID]
+	on := s.trackingOn && !s.autoPaused
+	paused := s.appPaused[app.AppID]
 	s.trackingMu.RUnlock()
 	if !on || paused {
 		return false
 	}
 	s.cfgMu.RLock()
 	defer s.cfgMu.RUnlock()
-	return s.cfg.Rules.Allow(exePath, windowClass)
+	return s.cfg.Rules.Allow(policy.TargetOf(app))
 }
 
-// Добавляем переменную для отслеживания времени последнего захвата
-var lastCaptureTime time.Time
-
 func (s *Services) handleSystemEvent(ev events.SystemEvent) {
 	switch ev.Type {
-	case events.EventForegroundChanged, events.EventWindowMoved, events.EventWindowShown, events.EventWindowHidden, events.EventProcessStarted, events.EventProcessExited:
-		s.captureForeground()
+	case events.EventWindowMoved:
+		s.cfgMu.RLock()
+		settled := s.cfg.Throttling.MoveQuietPeriod > 0
+		s.cfgMu.RUnlock()
+		s.captureForeground(settled)
+	case events.EventForegroundChanged, events.EventWindowShown, events.EventWindowHidden, events.EventProcessStarted, events.EventProcessExited:
+		s.captureForeground(false)
 	case events.EventClipboardChanged:
-		s.captureForeground()
+		s.captureForeground(false)
 	default:
 	}
 }
 
-func (s *Services) captureForeground() {
+func (s *Services) processScanInterval() time.Duration {
+	s.cfgMu.RLock()
+	defer s.cfgMu.RUnlock()
+	return time.Duration(s.cfg.Throttling.ProcessScanInterval)
+}
+
+// coalesceOptions merges the moves of each window until it settles.
+func coalesceOptions(cfg *policy.Config) events.CoalesceOptions {
+	return events.CoalesceOptions{
+		Quiet: time.Duration(cfg.Throttling.MoveQuietPeriod),
+		Types: []events.EventType{events.EventWindowMoved},
+	}
+}
+
+// captureForeground captures the foreground app. settled marks the last
+// event of a coalesced burst, which skips the capture intervals so the final
+// state is not lost.
+func (s *Services) captureForeground(settled bool) {
 	// Ограничиваем частоту захватов, чтобы избежать избыточной нагрузки
-	now := time.Now()
-	if now.Sub(lastCaptureTime) < 500*time.Millisecond { // Минимальный интервал между захватами
+	s.cfgMu.RLock()
+	minInterval := s.cfg.MinCaptureInterval()
+	s.cfgMu.RUnlock()
+	s.expirePauses()
+	now := s.now()
+	if !settled && now.Sub(s.lastCapture) < minInterval { // Минимальный интервал между захватами
+		// Не захватываем, поэтому относим пропуск к последнему захваченному приложению
+		if s.lastApp != "" {
+			s.recordCapture(&state.AppState{AppID: s.lastApp}, outcomeThrottled)
+		}
 		return
 	}
-	lastCaptureTime = now
+	s.lastCapture = now
 
 	app, err := s.cap.CaptureForeground()
+	var skipped *replay.SkippedError
+	if errors.As(err, &skipped) {
+		// Replayed capture of an app the recorded session did not track.
+		s.recordCapture(&state.AppState{AppID: skipped.AppID}, outcomeSkipped)
+		return
+	}
 	if err != nil {
+		s.recordCaptureError("", err)
+		return
+	}
+	s.lastApp = app.AppID
+	app.Settled = settled
+	s.cfgMu.RLock()
+	eff := s.cfg.EffectiveFor(app.AppID, app.ExecutablePath)
+	s.cfgMu.RUnlock()
+	if last, ok := s.appCapture[app.AppID]; ok && !settled && now.Sub(last) < eff.CaptureMinInterval {
+		s.recordCapture(app, outcomeThrottled)
 		return
 	}
+	s.appCapture[app.AppID] = now
 	if s.pl != nil {
-		s.pl.Capture(app)
+		s.pl.CaptureEnabled(app, eff.PluginEnabled)
 	}
-	if !s.shouldTrack(app.AppID, app.ExecutablePath, app.ForegroundWindowClass) {
+	fg := policy.TargetOf(app)
+	s.evaluateAutoPause(&fg)
+	if !s.shouldTrack(app) {
+		s.recordCapture(app, outcomeSkipped)
+		// Excluded and paused apps leave only their ID in a recording.
+		if s.rec != nil {
+			s.rec.Skipped(app.AppID, s.now())
+		}
 		return
 	}
+	s.cfgMu.RLock()
+	if !s.cfg.Clipboard.KeepText(app.ClipboardText, app.ClipboardSource, app.ExecutablePath) {
+		app.ClipboardText = ""
+	}
+	raw := app.CommandLine
+	app.Redactions = s.cfg.Redaction.Apply(app)
+	if app.CommandLine != raw {
+		app.RawCommandLine = raw
+	}
+	s.cfgMu.RUnlock()
+	// Recorded only once redacted, as the snapshot store keeps it.
+	if s.rec != nil {
+		s.rec.Capture(app, s.now())
+	}
 	meta, err := s.ss.Ingest(app)
 	if err != nil {
+		s.recordCaptureError(app.AppID, err)
+		s.recordCapture(app, outcomeIngestFailed)
 		return
 	}
-	if meta != nil {
+	if meta == nil {
+		s.recordCapture(app, outcomeUnchanged)
+	} else {
+		s.recordCapture(app, outcomeSnapshot)
 		s.deps.EmitEvent("onSnapshotCreated", ipcapi.SnapshotCreatedEvent{
 			AppID:      app.AppID,
 			Snapshot:   *meta,
@@ -276,3 +511,46 @@ func (s *Services) captureForeground() {
 		})
 	}
 }
+
+func significanceFromPolicy(t policy.Throttling) snapshot.SignificancePolicy {
+	w := t.Weights
+	perApp := make(map[string]time.Duration, len(t.AppMinIntervals))
+	for k, v := range t.AppMinIntervals {
+		perApp[strings.ToLower(k)] = time.Duration(v)
+	}
+	return snapshot.SignificancePolicy{
+		Threshold:            t.Threshold,
+		BurstThreshold:       t.BurstThreshold,
+		MinInterval:          time.Duration(t.SnapshotMinInterval),
+		AppMinIntervals:      perApp,
+		WindowMoved:          w.WindowMoved,
+		MovePixels:           w.MovePixels,
+		WindowOpened:         w.WindowOpened,
+		WindowClosed:         w.WindowClosed,
+		WindowStateChanged:   w.WindowStateChanged,
+		FocusChanged:         w.FocusChanged,
+		FileChanged:          w.FileChanged,
+		PluginChanged:        w.PluginChanged,
+		ClipboardChanged:     w.ClipboardChanged,
+		InputLanguageChanged: w.InputLanguageChanged,
+		TitleChanged:         w.TitleChanged,
+	}
+}
+
+// Replay runs a recorded session through capture, tracking and snapshot
+// decisions instead of live capture, with the recorded captures and a clock
+// at the recorded times. It is used on services that were never started, on
+// any platform. See replay.Play for speed.
+func (s *Services) Replay(ctx context.Context, rec *replay.Recording, speed float64) error {
+	if s.started {
+		return errors.New("replay needs services that were not started")
+	}
+	clock := &replay.Clock{}
+	fake := &replay.FakeCapture{}
+	s.now = clock.Now
+	s.ss.SetClock(clock.Now)
+	s.cap = fake
+	return replay.Play(ctx, rec, speed, clock, fake, func(st replay.Step) {
+		s.handleSystemEvent(st.Event)
+	})
+}
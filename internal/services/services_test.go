@@ -0,0 +1,97 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"Rewinder/internal/policy"
+	"Rewinder/internal/replay"
+	"Rewinder/internal/snapshot"
+	"Rewinder/internal/state"
+)
+
+// newTestServices creates services over a fresh home dir, not started.
+func newTestServices(t *testing.T, deps Dependencies) *Services {
+	t.Helper()
+	t.Setenv(policy.EnvHome, t.TempDir())
+	t.Setenv(policy.EnvConfigPath, "")
+	t.Setenv(policy.EnvLockMode, policy.LockFail)
+	svc, err := New(deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(svc.Stop)
+	return svc
+}
+
+// setConfig replaces the settings in effect with a changed copy.
+func setConfig(s *Services, change func(cfg *policy.Config)) {
+	s.cfgMu.Lock()
+	cfg := *s.cfg
+	change(&cfg)
+	s.cfg = &cfg
+	s.cfgMu.Unlock()
+}
+
+type countingCapture struct{ n int }
+
+func (c *countingCapture) CaptureForeground() (*state.AppState, error) {
+	c.n++
+	return nil, errors.New("no foreground window")
+}
+
+// The capture interval runs on the services' clock and is kept per
+// instance.
+func TestCaptureMinInterval(t *testing.T) {
+	clock := &replay.Clock{}
+	clock.Set(time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC))
+	capA, capB := &countingCapture{}, &countingCapture{}
+	a := newTestServices(t, Dependencies{EmitEvent: func(string, any) {}, Now: clock.Now, Capture: capA})
+	setConfig(a, func(cfg *policy.Config) { cfg.Throttling.CaptureMinInterval = policy.Duration(500 * time.Millisecond) })
+
+	a.captureForeground(false)
+	clock.Set(clock.Now().Add(499 * time.Millisecond))
+	a.captureForeground(false)
+	if capA.n != 1 {
+		t.Fatalf("%d captures within the interval", capA.n)
+	}
+	// The settled end of a burst is captured inside the interval.
+	a.captureForeground(true)
+	if capA.n != 2 {
+		t.Fatalf("settled capture throttled")
+	}
+	clock.Set(clock.Now().Add(500 * time.Millisecond))
+	a.captureForeground(false)
+	if capA.n != 3 {
+		t.Fatalf("capture after the interval throttled")
+	}
+
+	b := newTestServices(t, Dependencies{EmitEvent: func(string, any) {}, Now: clock.Now, Capture: capB})
+	b.captureForeground(false)
+	if capB.n != 1 {
+		t.Fatal("throttled by another instance's capture")
+	}
+}
+
+// A second instance on the same store opens it read-only, or fails with
+// ErrStoreLocked under LockFail.
+func TestSecondInstance(t *testing.T) {
+	first := newTestServices(t, Dependencies{EmitEvent: func(string, any) {}})
+	if first.ReadOnly() {
+		t.Fatal("first instance is read-only")
+	}
+	if _, err := New(Dependencies{EmitEvent: func(string, any) {}}); !errors.Is(err, snapshot.ErrStoreLocked) {
+		t.Fatalf("second instance under LockFail: %v", err)
+	}
+
+	t.Setenv(policy.EnvLockMode, policy.LockReadOnly)
+	second, err := New(Dependencies{EmitEvent: func(string, any) {}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Stop()
+	if !second.ReadOnly() {
+		t.Fatal("second instance is not read-only")
+	}
+}
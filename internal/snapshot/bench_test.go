@@ -0,0 +1,115 @@
+package snapshot
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"Rewinder/internal/state"
+)
+
+// Keyframes are written every 50 snapshots, so chains are at most 49 deltas.
+var benchChainLengths = []int{1, 10, 25, 49}
+
+// chainEngine returns an engine holding one app with a keyframe followed by
+// n-1 deltas.
+func chainEngine(b *testing.B, n int) (*Engine, *appTimeline, time.Time) {
+	e := newTestEngine(b, EngineConfig{MaxSnapshotsPerApp: 1000, Retention: 1000 * time.Hour})
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		if _, err := e.Ingest(testApp("bench", i, start.Add(time.Duration(i)*time.Second))); err != nil {
+			b.Fatal(err)
+		}
+	}
+	tl := e.timeline("bench")
+	if tl == nil || len(tl.snapshots) != n {
+		b.Fatalf("want %d snapshots", n)
+	}
+	return e, tl, start
+}
+
+// truncate drops the snapshots after the first n and makes the n-th the tip
+// again, with head as its materialized state.
+func truncate(e *Engine, tl *appTimeline, n int, head *state.AppState) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	if extra := tl.snapshots[n:]; len(extra) > 0 {
+		ids := snapshotIDs(extra)
+		tl.index.remove(ids)
+		e.cache.invalidate(tl.appID, ids)
+	}
+	tl.snapshots = tl.snapshots[:n]
+	tl.tip = tl.snapshots[n-1].SnapshotID
+	tl.head = head
+}
+
+func benchmarkIngest(b *testing.B, warmHead bool) {
+	for _, n := range benchChainLengths {
+		b.Run(fmt.Sprintf("chain=%d", n), func(b *testing.B) {
+			e, tl, start := chainEngine(b, n)
+			head := tl.head
+			at := start.Add(time.Duration(n) * time.Second)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				h := head
+				if !warmHead {
+					h = nil
+					e.cache.invalidate(tl.appID, snapshotIDs(tl.snapshots))
+				}
+				truncate(e, tl, n, h)
+				b.StartTimer()
+				if _, err := e.Ingest(testApp("bench", n+1+i%2, at)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkIngest appends to a timeline whose tip state is cached, the usual
+// case while the app is running.
+func BenchmarkIngest(b *testing.B) { benchmarkIngest(b, true) }
+
+// BenchmarkIngestColdHead appends after a restart, when the tip must be
+// rebuilt from its delta chain first.
+func BenchmarkIngestColdHead(b *testing.B) { benchmarkIngest(b, false) }
+
+// BenchmarkResolve materializes the newest snapshot that is not the tip, with
+// an empty resolve cache, so the whole chain is replayed from the keyframe.
+func BenchmarkResolve(b *testing.B) {
+	for _, n := range benchChainLengths {
+		b.Run(fmt.Sprintf("chain=%d", n), func(b *testing.B) {
+			e, tl, _ := chainEngine(b, n+1)
+			target := tl.snapshots[n-1].SnapshotID
+			ids := snapshotIDs(tl.snapshots)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				e.cache.invalidate(tl.appID, ids)
+				if _, _, err := e.ResolveSnapshot("bench", target); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkResolveCached resolves the same snapshot again, served by the
+// resolve cache.
+func BenchmarkResolveCached(b *testing.B) {
+	for _, n := range benchChainLengths {
+		b.Run(fmt.Sprintf("chain=%d", n), func(b *testing.B) {
+			e, tl, _ := chainEngine(b, n+1)
+			target := tl.snapshots[n-1].SnapshotID
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := e.ResolveSnapshot("bench", target); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
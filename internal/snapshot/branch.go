@@ -0,0 +1,214 @@
+package snapshot
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"Rewinder/internal/ipcapi"
+
+	"github.com/google/uuid"
+)
+
+const MainBranch = "main"
+
+// Branch is a line of snapshots in a timeline. Every branch except the main
+// one forks from the snapshot that was restored when it was started.
+type Branch struct {
+	ID               string    `json:"id"`
+	ParentBranchID   string    `json:"parentBranchID,omitempty"`
+	ParentSnapshotID string    `json:"parentSnapshotID,omitempty"`
+	CreatedAt        time.Time `json:"createdAt"`
+}
+
+// BeginBranch makes new snapshots of appID continue from snapshotID instead
+// of the current tip. When snapshotID is already the tip nothing changes;
+// when it is the head of another branch that branch becomes active again;
+// otherwise a new branch forking from it becomes active. It returns the ID of
+// the active branch and whether it was created.
+func (e *Engine) BeginBranch(appID, snapshotID string) (string, bool, error) {
+	if e.cfg.ReadOnly {
+		return "", false, ErrReadOnly
+	}
+	tl := e.timeline(appID)
+	if tl == nil {
+		return "", false, errors.New("unknown app")
+	}
+	tl.ingestMu.Lock()
+	defer tl.ingestMu.Unlock()
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+
+	idx := tl.indexOf(snapshotID)
+	if idx == -1 {
+		return "", false, errors.New("snapshot not found")
+	}
+	if snapshotID == tl.tip {
+		return tl.activeBranch, false, nil
+	}
+	if branchID := tl.snapshots[idx].BranchID; tl.branchHeadLocked(branchID) == idx {
+		tl.activeBranch = branchID
+		tl.tip = snapshotID
+		tl.head = nil
+		tl.resetBranchesLocked()
+		tl.dirty = true
+		return branchID, false, nil
+	}
+	b := Branch{
+		ID:               uuid.NewString(),
+		ParentBranchID:   tl.snapshots[idx].BranchID,
+		ParentSnapshotID: snapshotID,
+		CreatedAt:        e.now(),
+	}
+	tl.branches = append(tl.branches, b)
+	tl.activeBranch = b.ID
+	tl.tip = snapshotID
+	tl.head = nil
+	tl.resetBranchesLocked()
+	tl.dirty = true
+	return b.ID, true, nil
+}
+
+// GetBranches lists the branches of appID that still have snapshots, plus
+// the active one, oldest first.
+func (e *Engine) GetBranches(appID string) []ipcapi.BranchInfo {
+	tl := e.timeline(appID)
+	if tl == nil {
+		return nil
+	}
+	tl.mu.RLock()
+	defer tl.mu.RUnlock()
+
+	byID := map[string]*ipcapi.BranchInfo{}
+	var out []*ipcapi.BranchInfo
+	for _, b := range tl.branches {
+		info := &ipcapi.BranchInfo{
+			BranchID:         b.ID,
+			ParentBranchID:   b.ParentBranchID,
+			ParentSnapshotID: b.ParentSnapshotID,
+			CreatedAtUTC:     b.CreatedAt.UTC().UnixMilli(),
+			Active:           b.ID == tl.activeBranch,
+		}
+		byID[b.ID] = info
+		out = append(out, info)
+	}
+	for _, s := range tl.snapshots {
+		info := byID[s.BranchID]
+		if info == nil {
+			continue
+		}
+		info.SnapshotCount++
+		info.HeadSnapshotID = s.SnapshotID
+		info.HeadUTC = s.Timestamp.UTC().UnixMilli()
+	}
+
+	res := make([]ipcapi.BranchInfo, 0, len(out))
+	for _, info := range out {
+		if info.SnapshotCount > 0 || info.Active {
+			res = append(res, *info)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].CreatedAtUTC < res[j].CreatedAtUTC })
+	return res
+}
+
+// indexOf returns the position of snapshotID in tl.snapshots, or -1. The
+// positions are only a hint: a stale one is caught by the ID check and the
+// positions are rebuilt.
+func (tl *appTimeline) indexOf(snapshotID string) int {
+	if i := tl.pos.get(snapshotID); i >= 0 && i < len(tl.snapshots) && tl.snapshots[i].SnapshotID == snapshotID {
+		return i
+	}
+	return tl.pos.rebuild(tl.snapshots, snapshotID)
+}
+
+// positions maps snapshot IDs to their place in the timeline. Appends and
+// drops at the front keep it current; any other change leaves it stale until
+// the next lookup misses. It has its own lock, as lookups run under the
+// timeline's read lock.
+type positions struct {
+	mu   sync.Mutex
+	idx  map[string]int // position plus base
+	base int
+}
+
+func (p *positions) get(id string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if i, ok := p.idx[id]; ok {
+		return i - p.base
+	}
+	return -1
+}
+
+func (p *positions) rebuild(snaps []Snapshot, id string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.idx = make(map[string]int, len(snaps))
+	p.base = 0
+	for i, s := range snaps {
+		p.idx[s.SnapshotID] = i
+	}
+	if i, ok := p.idx[id]; ok {
+		return i
+	}
+	return -1
+}
+
+func (p *positions) appended(id string, i int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.idx != nil {
+		p.idx[id] = i + p.base
+	}
+}
+
+// trimmed records that ids were dropped from the front.
+func (p *positions) trimmed(ids []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.base += len(ids)
+	for _, id := range ids {
+		delete(p.idx, id)
+	}
+}
+
+// branchHeadLocked returns the index of the latest snapshot of branchID, or
+// -1 when it has none.
+func (tl *appTimeline) branchHeadLocked(branchID string) int {
+	for i := len(tl.snapshots) - 1; i >= 0; i-- {
+		if tl.snapshots[i].BranchID == branchID {
+			return i
+		}
+	}
+	return -1
+}
+
+// resetBranchesLocked falls back to the latest snapshot when the tip is gone,
+// and drops branches that have no snapshots left and are not active.
+func (tl *appTimeline) resetBranchesLocked() {
+	if tl.tip != "" && tl.indexOf(tl.tip) == -1 {
+		tl.tip = ""
+		tl.head = nil
+		if i := tl.branchHeadLocked(tl.activeBranch); i >= 0 {
+			tl.tip = tl.snapshots[i].SnapshotID
+		}
+		if tl.tip == "" && len(tl.snapshots) > 0 {
+			last := tl.snapshots[len(tl.snapshots)-1]
+			tl.tip = last.SnapshotID
+			tl.activeBranch = last.BranchID
+		}
+	}
+	used := map[string]bool{tl.activeBranch: true, MainBranch: true}
+	for _, s := range tl.snapshots {
+		used[s.BranchID] = true
+	}
+	kept := tl.branches[:0]
+	for _, b := range tl.branches {
+		if used[b.ID] {
+			kept = append(kept, b)
+		}
+	}
+	tl.branches = kept
+}
@@ -0,0 +1,87 @@
+package snapshot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBranching(t *testing.T) {
+	e := newTestEngine(t, EngineConfig{})
+	start := time.Now()
+	step := 0
+	ingest := func() Snapshot {
+		t.Helper()
+		step++
+		meta, err := e.Ingest(testApp("tool", step, start.Add(time.Duration(step)*time.Second)))
+		if err != nil || meta == nil {
+			t.Fatalf("ingest %d: %v", step, err)
+		}
+		tl := e.timeline("tool")
+		tl.mu.RLock()
+		defer tl.mu.RUnlock()
+		return tl.snapshots[tl.indexOf(meta.SnapshotID)]
+	}
+	begin := func(snapshotID, wantBranch string, wantCreated bool) string {
+		t.Helper()
+		branch, created, err := e.BeginBranch("tool", snapshotID)
+		if err != nil || created != wantCreated || (wantBranch != "" && branch != wantBranch) {
+			t.Fatalf("BeginBranch = %q, %v, %v; want %q, %v", branch, created, err, wantBranch, wantCreated)
+		}
+		return branch
+	}
+	active := func() string {
+		for _, b := range e.GetBranches("tool") {
+			if b.Active {
+				return b.BranchID
+			}
+		}
+		return ""
+	}
+
+	m0, m1, m2 := ingest(), ingest(), ingest()
+	if m2.BranchID != MainBranch || m2.ParentID != m1.SnapshotID {
+		t.Fatalf("main snapshot %+v", m2)
+	}
+	begin(m2.SnapshotID, MainBranch, false) // already the tip
+
+	// Restoring an older snapshot forks a branch from it.
+	fork := begin(m1.SnapshotID, "", true)
+	b0 := ingest()
+	if b0.BranchID != fork || b0.ParentID != m1.SnapshotID {
+		t.Fatalf("first snapshot of the branch %+v", b0)
+	}
+	b1 := ingest()
+
+	// Restoring the head of main carries on with main.
+	begin(m2.SnapshotID, MainBranch, false)
+	if active() != MainBranch {
+		t.Fatalf("active branch %q", active())
+	}
+	m3 := ingest()
+	if m3.BranchID != MainBranch || m3.ParentID != m2.SnapshotID {
+		t.Fatalf("main continued as %+v", m3)
+	}
+
+	// And the head of the fork carries on with the fork.
+	begin(b1.SnapshotID, fork, false)
+	if b2 := ingest(); b2.BranchID != fork || b2.ParentID != b1.SnapshotID {
+		t.Fatalf("fork continued as %+v", b2)
+	}
+	// A snapshot behind a head still forks.
+	begin(m0.SnapshotID, "", true)
+
+	branches := e.GetBranches("tool")
+	if len(branches) != 3 {
+		t.Fatalf("%d branches: %+v", len(branches), branches)
+	}
+	for _, b := range branches {
+		if b.BranchID == fork && (b.ParentBranchID != MainBranch || b.ParentSnapshotID != m1.SnapshotID || b.SnapshotCount != 3 || b.Active) {
+			t.Fatalf("fork %+v", b)
+		}
+	}
+	// The empty new branch is dropped once another one is active.
+	begin(m3.SnapshotID, MainBranch, false)
+	if n := len(e.GetBranches("tool")); n != 2 {
+		t.Fatalf("%d branches after leaving an empty one", n)
+	}
+}
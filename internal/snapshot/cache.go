@@ -0,0 +1,91 @@
+package snapshot
+
+import (
+	"container/list"
+	"sync"
+
+	"Rewinder/internal/state"
+)
+
+// resolveCache is an LRU of materialized snapshots keyed by app and snapshot
+// ID. Entries are never handed out directly; callers get clones.
+type resolveCache struct {
+	mu    sync.Mutex
+	cap   int
+	ll    *list.List
+	items map[cacheKey]*list.Element
+}
+
+type cacheKey struct {
+	appID      string
+	snapshotID string
+}
+
+type cacheEntry struct {
+	key  cacheKey
+	full *FullSnapshot
+}
+
+func newResolveCache(capacity int) *resolveCache {
+	return &resolveCache{cap: capacity, ll: list.New(), items: map[cacheKey]*list.Element{}}
+}
+
+func (c *resolveCache) get(appID, snapshotID string) (*FullSnapshot, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[cacheKey{appID, snapshotID}]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return cloneFull(el.Value.(*cacheEntry).full), true
+}
+
+func (c *resolveCache) put(appID, snapshotID string, full *FullSnapshot) {
+	if c.cap <= 0 {
+		return
+	}
+	key := cacheKey{appID, snapshotID}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		el.Value.(*cacheEntry).full = cloneFull(full)
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&cacheEntry{key: key, full: cloneFull(full)})
+	for c.ll.Len() > c.cap {
+		old := c.ll.Back()
+		c.ll.Remove(old)
+		delete(c.items, old.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *resolveCache) invalidate(appID string, snapshotIDs []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range snapshotIDs {
+		key := cacheKey{appID, id}
+		if el, ok := c.items[key]; ok {
+			c.ll.Remove(el)
+			delete(c.items, key)
+		}
+	}
+}
+
+func cloneFull(fs *FullSnapshot) *FullSnapshot {
+	return &FullSnapshot{App: cloneApp(&fs.App)}
+}
+
+func cloneApp(app *state.AppState) state.AppState {
+	cp := *app
+	cp.Windows = append([]state.WindowState(nil), app.Windows...)
+	cp.OpenFiles = append([]state.FileRef(nil), app.OpenFiles...)
+	if app.PluginData != nil {
+		cp.PluginData = make(map[string]any, len(app.PluginData))
+		for k, v := range app.PluginData {
+			cp.PluginData[k] = v
+		}
+	}
+	return cp
+}
@@ -0,0 +1,212 @@
+package snapshot
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Forget drops the whole timeline of appID and securely deletes its spill
+// files and clipboard vault entries.
+func (e *Engine) Forget(appID string) error {
+	if e.cfg.ReadOnly {
+		return ErrReadOnly
+	}
+	if appID == "" || strings.ContainsAny(appID, `/\`) || appID == "." || appID == ".." {
+		return errors.New("invalid app id")
+	}
+	e.mu.Lock()
+	tl := e.apps[appID]
+	delete(e.apps, appID)
+	e.mu.Unlock()
+
+	removed := 0
+	if tl != nil {
+		// Wait for an ingest or save in flight so nothing is written after the wipe.
+		tl.persistMu.Lock()
+		tl.deleted = true
+		tl.persistMu.Unlock()
+		tl.ingestMu.Lock()
+		tl.mu.Lock()
+		removed = len(tl.snapshots)
+		e.dropLocked(tl, tl.snapshots)
+		tl.snapshots = nil
+		tl.head = nil
+		tl.mu.Unlock()
+		tl.ingestMu.Unlock()
+	}
+
+	prefix := appID + "/"
+	e.spill.discard(func(ref string) bool { return strings.HasPrefix(ref, prefix) })
+	err := secureRemoveAll(filepath.Join(e.cfg.StorageDir, appID))
+	_ = e.appendAudit(auditEntry{Action: "forget", AppID: appID, Removed: removed})
+	return err
+}
+
+// Purge removes the snapshots of appID taken within [from, to]. The first
+// snapshot after a removed one is turned into a keyframe so every remaining
+// snapshot still resolves to the same state. It returns the number of
+// snapshots removed.
+func (e *Engine) Purge(appID string, from, to time.Time) (int, error) {
+	if e.cfg.ReadOnly {
+		return 0, ErrReadOnly
+	}
+	if to.Before(from) {
+		return 0, errors.New("invalid time range")
+	}
+	tl := e.timeline(appID)
+	if tl == nil {
+		return 0, errors.New("unknown app")
+	}
+	tl.ingestMu.Lock()
+	defer tl.ingestMu.Unlock()
+
+	tl.mu.RLock()
+	exe := tl.exe
+	removed := map[string]bool{}
+	parentOf := map[string]string{}
+	for _, s := range tl.snapshots {
+		if !s.Timestamp.Before(from) && !s.Timestamp.After(to) {
+			removed[s.SnapshotID] = true
+			parentOf[s.SnapshotID] = s.ParentID
+		}
+	}
+	// survivor maps a removed snapshot to its closest kept ancestor.
+	survivor := func(id string) string {
+		for removed[id] {
+			id = parentOf[id]
+		}
+		return id
+	}
+	if len(removed) == 0 {
+		tl.mu.RUnlock()
+		return 0, nil
+	}
+	chains := map[string][]Snapshot{}
+	for _, s := range tl.snapshots {
+		if removed[s.SnapshotID] || s.BaseSnapshotID == nil || !removed[*s.BaseSnapshotID] {
+			continue
+		}
+		if _, chain, err := e.chainLocked(tl, s.SnapshotID); err == nil {
+			chains[s.SnapshotID] = chain
+		}
+	}
+	tl.mu.RUnlock()
+
+	// Materialize the new keyframes before anything is removed.
+	rebased := map[string]string{}
+	keepHashes := map[string]bool{}
+	keepLaunch := map[string]bool{}
+	for id, chain := range chains {
+		full, err := e.materialize(appID, exe, chain)
+		if err != nil {
+			return 0, err
+		}
+		job, err := e.spill.prepare(appID, full)
+		if err != nil {
+			return 0, err
+		}
+		if err := e.spill.submit(job); err != nil {
+			return 0, err
+		}
+		rebased[id] = job.ref
+		keepHashes[full.App.ClipboardHash] = true
+		keepLaunch[full.App.LaunchRef] = true
+	}
+
+	tl.mu.Lock()
+	var kept, dropped []Snapshot
+	for _, s := range tl.snapshots {
+		if removed[s.SnapshotID] {
+			dropped = append(dropped, s)
+			continue
+		}
+		if ref, ok := rebased[s.SnapshotID]; ok {
+			s.Spilled = true
+			s.DiskRef = ref
+			s.BaseSnapshotID = nil
+		}
+		s.ParentID = survivor(s.ParentID)
+		kept = append(kept, s)
+	}
+	for i := range tl.branches {
+		tl.branches[i].ParentSnapshotID = survivor(tl.branches[i].ParentSnapshotID)
+	}
+	if removed[tl.tip] {
+		tl.tip = survivor(tl.tip)
+		tl.head = nil
+	}
+	if tl.head != nil {
+		keepHashes[tl.head.ClipboardHash] = true
+		keepLaunch[tl.head.LaunchRef] = true
+	}
+	tl.snapshots = kept
+	e.dropLocked(tl, dropped)
+	tl.mu.Unlock()
+
+	keptRefs := map[string]bool{}
+	for _, s := range kept {
+		if s.DiskRef != "" {
+			keptRefs[s.DiskRef] = true
+		}
+		keepHashes[s.Delta.ClipboardHash] = true
+	}
+	launchKnown := true
+	for ref := range keptRefs {
+		if fs, err := e.spill.load(ref); err == nil {
+			keepHashes[fs.App.ClipboardHash] = true
+			keepLaunch[fs.App.LaunchRef] = true
+		} else {
+			launchKnown = false
+		}
+	}
+
+	var staleRefs []string
+	for _, s := range dropped {
+		if s.DiskRef != "" && !keptRefs[s.DiskRef] {
+			staleRefs = append(staleRefs, s.DiskRef)
+		}
+	}
+	e.spill.discard(func(ref string) bool {
+		for _, r := range staleRefs {
+			if r == ref {
+				return true
+			}
+		}
+		return false
+	})
+	var firstErr error
+	for _, ref := range staleRefs {
+		if err := secureRemove(e.spill.path(ref)); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if e.vault != nil {
+		for _, s := range dropped {
+			h := s.Delta.ClipboardHash
+			if h == "" || keepHashes[h] {
+				continue
+			}
+			if err := secureRemove(e.vault.path(appID, h)); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	// Command lines are shared by many snapshots; drop those no keyframe
+	// refers to any more, unless some keyframe could not be read.
+	if e.launch != nil && launchKnown {
+		if err := e.launch.prune(appID, keepLaunch); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	_ = e.appendAudit(auditEntry{
+		Action:  "purge",
+		AppID:   appID,
+		FromUTC: from.UTC().UnixMilli(),
+		ToUTC:   to.UTC().UnixMilli(),
+		Removed: len(dropped),
+	})
+	return len(dropped), firstErr
+}
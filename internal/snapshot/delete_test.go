@@ -0,0 +1,59 @@
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// An ingest that picked up the timeline before Forget removed it must not
+// write into the wiped app directory once it gets the ingest lock.
+func TestForgetDuringIngest(t *testing.T) {
+	dir := t.TempDir()
+	var park atomic.Bool
+	parked := make(chan struct{})
+	resume := make(chan struct{})
+	e := newTestEngine(t, EngineConfig{
+		StorageDir: dir,
+		// Limits are looked up after Ingest has the timeline and before it
+		// takes the ingest lock; hold the ingest there while Forget runs.
+		AppLimits: func(appID, exePath string) AppLimits {
+			if park.CompareAndSwap(true, false) {
+				close(parked)
+				<-resume
+			}
+			return AppLimits{}
+		},
+	})
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := e.Ingest(testApp("victim", i, start.Add(time.Duration(i)*time.Second))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	park.Store(true)
+	done := make(chan error, 1)
+	go func() {
+		_, err := e.Ingest(testApp("victim", 10, start.Add(10*time.Second)))
+		done <- err
+	}()
+	<-parked
+	if err := e.Forget("victim"); err != nil {
+		t.Fatal(err)
+	}
+	close(resume)
+	if err := <-done; err != nil {
+		t.Fatalf("ingest after forget: %v", err)
+	}
+	_ = e.Close()
+
+	if _, err := os.Stat(filepath.Join(dir, "victim")); !os.IsNotExist(err) {
+		t.Fatalf("app directory exists after Forget: %v", err)
+	}
+	if got := e.GetTimeline("victim"); len(got) != 0 {
+		t.Fatalf("timeline has %d snapshots after Forget", len(got))
+	}
+}
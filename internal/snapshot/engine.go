@@ -2,13 +2,9 @@ package snapshot
 
 import (
 	"bytes"
-	"compress/gzip"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"sort"
@@ -28,16 +24,53 @@ type EngineConfig struct {
 	MaxDiskBytes       int64
 	Retention          time.Duration
 	StorageDir         string
+	ClipboardVault     bool
+	SpillQueueDepth    int
+	ResolveCacheSize   int
+	Significance       SignificancePolicy
+	PersistInterval    time.Duration
+
+	// ReadOnly opens a store locked by another instance. Nothing is written;
+	// timelines are reloaded from disk every PersistInterval instead.
+	ReadOnly bool
+
+	// AppLimits, when set, returns per-app overrides of the limits above.
+	AppLimits func(appID, exePath string) AppLimits
+
+	// Now is the clock retention and branch times are based on; a replay
+	// sets its virtual clock. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// AppLimits overrides the global limits for one app. Zero values keep the
+// global setting.
+type AppLimits struct {
+	Retention          time.Duration
+	MaxSnapshotsPerApp int
+	Significance       *SignificancePolicy
 }
 
 type Engine struct {
-	cfg EngineConfig
+	cfgMu sync.RWMutex // guards the fields SetLimits may change
+	cfg   EngineConfig
 
-	mu   sync.RWMutex
+	mu   sync.RWMutex // guards apps; each timeline has its own locks
 	apps map[string]*appTimeline
+
+	vault  *secretVault // clipboard text, opt-in
+	launch *secretVault // command lines as captured before redaction
+	spill  *spillWriter
+	cache  *resolveCache
+
+	stopCh    chan struct{}
+	closeOnce sync.Once
+	persistWG sync.WaitGroup
 }
 
 type appTimeline struct {
+	ingestMu sync.Mutex
+	mu       sync.RWMutex
+
 	appID string
 	exe   string
 	name  string
@@ -46,17 +79,39 @@ type appTimeline struct {
 
 	snapshots []Snapshot
 	ramBytes  int64
+	pos       positions
+
+	// head is the materialized state of the tip snapshot. It is replaced,
+	// never modified, so it can be read after tl.mu is released.
+	head *state.AppState
+
+	// New snapshots extend tip on activeBranch; tip is empty only while the
+	// timeline has no snapshots.
+	branches     []Branch
+	activeBranch string
+	tip          string
+
+	index *searchIndex
+	dirty bool
+
+	persistMu sync.Mutex
+	deleted   bool
 }
 
 type Snapshot struct {
-	SnapshotID     string     `json:"snapshotID"`
-	AppID          string     `json:"appID"`
-	BaseSnapshotID *string    `json:"baseSnapshotID,omitempty"`
-	Delta          StateDelta `json:"delta"`
-	Timestamp      time.Time  `json:"timestamp"`
+	SnapshotID     string         `json:"snapshotID"`
+	AppID          string         `json:"appID"`
+	BaseSnapshotID *string        `json:"baseSnapshotID,omitempty"`
+	BranchID       string         `json:"branchID,omitempty"`
+	ParentID       string         `json:"parentID,omitempty"`
+	Redactions     map[string]int `json:"redactions,omitempty"`
+	Delta          StateDelta     `json:"delta"`
+	Timestamp      time.Time      `json:"timestamp"`
 
 	Spilled bool   `json:"spilled"`
 	DiskRef string `json:"diskRef,omitempty"`
+
+	ramSize int64
 }
 
 type StateDelta struct {
@@ -65,8 +120,12 @@ type StateDelta struct {
 	FilesAdded       []state.FileRef `json:"filesAdded,omitempty"`
 	FilesRemoved     []state.FileRef `json:"filesRemoved,omitempty"`
 	ClipboardChanged bool            `json:"clipboardChanged"`
+	ClipboardHash    string          `json:"clipboardHash,omitempty"`
 	PluginChanged    bool            `json:"pluginChanged"`
 	PluginData       map[string]any  `json:"pluginData,omitempty"`
+
+	InputLanguageChanged bool   `json:"inputLanguageChanged"`
+	InputLanguage        string `json:"inputLanguage,omitempty"`
 }
 
 type WindowDiff struct {
@@ -80,25 +139,175 @@ type FullSnapshot struct {
 }
 
 func NewEngine(cfg EngineConfig) *Engine {
+	cfg.limitDefaults()
+	if cfg.SpillQueueDepth <= 0 {
+		cfg.SpillQueueDepth = 16
+	}
+	if cfg.ResolveCacheSize <= 0 {
+		cfg.ResolveCacheSize = 64
+	}
+	if cfg.PersistInterval <= 0 {
+		cfg.PersistInterval = 30 * time.Second
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	if !cfg.ReadOnly {
+		_ = os.MkdirAll(cfg.StorageDir, 0o755)
+	}
+	e := &Engine{
+		cfg:    cfg,
+		apps:   map[string]*appTimeline{},
+		spill:  newSpillWriter(cfg.StorageDir, cfg.SpillQueueDepth),
+		cache:  newResolveCache(cfg.ResolveCacheSize),
+		stopCh: make(chan struct{}),
+	}
+	// Read-only instances open the vaults too, so restores get the clipboard
+	// text and command lines back; they only never create the key.
+	if cfg.ClipboardVault {
+		if v, err := openSecretVault(cfg.StorageDir, clipVaultSub, cfg.ReadOnly); err == nil {
+			e.vault = v
+		}
+	}
+	if v, err := openSecretVault(cfg.StorageDir, launchVaultSub, cfg.ReadOnly); err == nil {
+		e.launch = v
+	}
+	e.loadTimelines()
+	e.persistWG.Add(1)
+	go e.persistLoop(cfg.PersistInterval)
+	return e
+}
+
+func (cfg *EngineConfig) limitDefaults() {
 	if cfg.MaxSnapshotsPerApp <= 0 {
 		cfg.MaxSnapshotsPerApp = 500
 	}
 	if cfg.Retention <= 0 {
 		cfg.Retention = 24 * time.Hour
 	}
-	_ = os.MkdirAll(cfg.StorageDir, 0o755)
-	return &Engine{cfg: cfg, apps: map[string]*appTimeline{}}
+	if cfg.Significance.isZero() {
+		cfg.Significance = DefaultSignificance()
+	}
 }
 
-func (e *Engine) Close() error { return nil }
+// SetLimits applies new snapshot limits, retention and significance policy
+// from cfg. They take effect on the next ingest of each app, retention also
+// on the next periodic sweep. Storage, vault and queue settings are fixed
+// when the engine is created and are ignored.
+func (e *Engine) SetLimits(cfg EngineConfig) {
+	cfg.limitDefaults()
+	e.cfgMu.Lock()
+	e.cfg.MaxSnapshotsPerApp = cfg.MaxSnapshotsPerApp
+	e.cfg.MaxRAMBytes = cfg.MaxRAMBytes
+	e.cfg.MaxDiskBytes = cfg.MaxDiskBytes
+	e.cfg.Retention = cfg.Retention
+	e.cfg.Significance = cfg.Significance
+	e.cfg.AppLimits = cfg.AppLimits
+	e.cfgMu.Unlock()
+}
 
-func (e *Engine) GetApps() []ipcapi.AppSummary {
-	fmt.Printf("[DEBUG] GetApps called\n")
+// SetClock replaces the clock set by EngineConfig.Now.
+func (e *Engine) SetClock(now func() time.Time) {
+	if now == nil {
+		now = time.Now
+	}
+	e.cfgMu.Lock()
+	e.cfg.Now = now
+	e.cfgMu.Unlock()
+}
+
+func (e *Engine) now() time.Time {
+	e.cfgMu.RLock()
+	now := e.cfg.Now
+	e.cfgMu.RUnlock()
+	return now()
+}
+
+func (e *Engine) limits() EngineConfig {
+	e.cfgMu.RLock()
+	defer e.cfgMu.RUnlock()
+	return e.cfg
+}
+
+// limitsFor returns the limits of one app with its overrides applied. It
+// must be called without holding a timeline lock.
+func (e *Engine) limitsFor(appID, exePath string) EngineConfig {
+	cfg := e.limits()
+	if cfg.AppLimits == nil {
+		return cfg
+	}
+	al := cfg.AppLimits(appID, exePath)
+	if al.Retention > 0 {
+		cfg.Retention = al.Retention
+	}
+	if al.MaxSnapshotsPerApp > 0 {
+		cfg.MaxSnapshotsPerApp = al.MaxSnapshotsPerApp
+	}
+	if al.Significance != nil {
+		cfg.Significance = *al.Significance
+	}
+	return cfg
+}
+
+func (e *Engine) Close() error {
+	e.closeOnce.Do(func() {
+		close(e.stopCh)
+		e.persistWG.Wait()
+		if !e.cfg.ReadOnly {
+			e.flushTimelines()
+		}
+		e.spill.Close()
+	})
+	return nil
+}
+
+func (e *Engine) timeline(appID string) *appTimeline {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
-	var out []ipcapi.AppSummary
+	return e.apps[appID]
+}
+
+func (e *Engine) timelines() []*appTimeline {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	out := make([]*appTimeline, 0, len(e.apps))
 	for _, tl := range e.apps {
-		out = append(out, ipcapi.AppSummary{
+		out = append(out, tl)
+	}
+	return out
+}
+
+func (e *Engine) timelineFor(app *state.AppState) *appTimeline {
+	if tl := e.timeline(app.AppID); tl != nil {
+		return tl
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	tl := e.apps[app.AppID]
+	if tl == nil {
+		tl = &appTimeline{
+			appID: app.AppID,
+			exe:   app.ExecutablePath,
+			name:  filepath.Base(app.ExecutablePath),
+			index: newSearchIndex(),
+
+			branches:     []Branch{{ID: MainBranch, CreatedAt: app.Timestamp}},
+			activeBranch: MainBranch,
+		}
+		e.apps[app.AppID] = tl
+	}
+	return tl
+}
+
+func (e *Engine) GetApps() []ipcapi.AppSummary {
+	var out []ipcapi.AppSummary
+	for _, tl := range e.timelines() {
+		tl.mu.RLock()
+		exe := tl.exe
+		tl.mu.RUnlock()
+		lim := e.limitsFor(tl.appID, exe)
+		tl.mu.RLock()
+		sum := ipcapi.AppSummary{
 			AppID:           tl.appID,
 			Name:            tl.name,
 			ExecutablePath:  tl.exe,
@@ -106,62 +315,104 @@ func (e *Engine) GetApps() []ipcapi.AppSummary {
 			SnapshotCount:   len(tl.snapshots),
 			TrackingState:   "active",
 			RetentionStatus: "ok",
-		})
+			RetentionDetail: fmt.Sprintf("Snapshots are kept for %s", lim.Retention),
+		}
+		if len(tl.snapshots) >= lim.MaxSnapshotsPerApp {
+			sum.RetentionStatus = "at-limit"
+			sum.RetentionDetail = fmt.Sprintf("%d of %d snapshots used, the oldest are dropped as new ones arrive", len(tl.snapshots), lim.MaxSnapshotsPerApp)
+		}
+		out = append(out, sum)
+		tl.mu.RUnlock()
 	}
 	sort.Slice(out, func(i, j int) bool { return out[i].LastActivityUTC > out[j].LastActivityUTC })
-	fmt.Printf("[DEBUG] Returning %d apps\n", len(out))
 	return out
 }
 
 func (e *Engine) GetTimeline(appID string) []ipcapi.SnapshotMeta {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
-	tl := e.apps[appID]
+	tl := e.timeline(appID)
 	if tl == nil {
 		return nil
 	}
+	tl.mu.RLock()
 	out := make([]ipcapi.SnapshotMeta, 0, len(tl.snapshots))
 	for _, s := range tl.snapshots {
 		out = append(out, ipcapi.SnapshotMeta{
-			SnapshotID:   s.SnapshotID,
-			AppID:        s.AppID,
-			Timestamp:    s.Timestamp.UTC().UnixMilli(),
-			WindowsCount: len(s.Delta.WindowDiffs),
-			FilesAdded:   len(s.Delta.FilesAdded),
-			FilesRemoved: len(s.Delta.FilesRemoved),
+			SnapshotID:       s.SnapshotID,
+			AppID:            s.AppID,
+			BranchID:         s.BranchID,
+			ParentSnapshotID: s.ParentID,
+			Timestamp:        s.Timestamp.UTC().UnixMilli(),
+			WindowsCount:     len(s.Delta.WindowDiffs),
+			FilesAdded:       len(s.Delta.FilesAdded),
+			FilesRemoved:     len(s.Delta.FilesRemoved),
+			Redactions:       s.Redactions,
 		})
 	}
+	tl.mu.RUnlock()
 	sort.Slice(out, func(i, j int) bool { return out[i].Timestamp > out[j].Timestamp })
 	return out
 }
 
+// Ingest diffs app against the tip of the active branch and appends a new
+// snapshot when something changed. Ingests of one app are serialized by
+// the timeline's ingest lock; tl.mu is only held while the snapshot slice is
+// read or modified, so disk work never blocks timeline queries.
 func (e *Engine) Ingest(app *state.AppState) (*ipcapi.SnapshotMeta, error) {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-
-	tl := e.apps[app.AppID]
-	if tl == nil {
-		tl = &appTimeline{
-			appID: app.AppID,
-			exe:   app.ExecutablePath,
-			name:  filepath.Base(app.ExecutablePath),
-		}
-		e.apps[app.AppID] = tl
+	if e.cfg.ReadOnly {
+		return nil, ErrReadOnly
+	}
+	tl := e.timelineFor(app)
+	exePath := app.ExecutablePath
+	if exePath == "" {
+		tl.mu.RLock()
+		exePath = tl.exe
+		tl.mu.RUnlock()
+	}
+	lim := e.limitsFor(app.AppID, exePath)
+	tl.ingestMu.Lock()
+	defer tl.ingestMu.Unlock()
+	// Forget may have dropped the timeline while we waited for the lock.
+	tl.persistMu.Lock()
+	deleted := tl.deleted
+	tl.persistMu.Unlock()
+	if deleted {
+		return nil, nil
 	}
+
+	tl.mu.Lock()
 	tl.lastActivity = app.Timestamp
 	if app.ExecutablePath != "" {
 		tl.exe = app.ExecutablePath
 		tl.name = filepath.Base(app.ExecutablePath)
 	}
+	e.applyRetentionLocked(tl, lim.Retention)
 
-	e.applyRetentionLocked(tl)
+	exe := tl.exe
+	count := len(tl.snapshots)
+	head := tl.head
+	branch := tl.activeBranch
+	var last Snapshot
+	var chain []Snapshot
+	if count > 0 {
+		last = tl.snapshots[count-1]
+		if i := tl.indexOf(tl.tip); i >= 0 {
+			last = tl.snapshots[i]
+		}
+		if head == nil {
+			_, chain, _ = e.chainLocked(tl, last.SnapshotID)
+		}
+	}
+	tl.mu.Unlock()
 
 	var base *FullSnapshot
-	if len(tl.snapshots) == 0 {
+	switch {
+	case count == 0:
 		base = &FullSnapshot{App: *app}
-	} else {
+	case head != nil:
+		base = &FullSnapshot{App: *head}
+	default:
 		// Оптимизируем получение базового снимка, только если действительно необходимо
-		_, full, err := e.resolveSnapshotLocked(tl, tl.snapshots[len(tl.snapshots)-1].SnapshotID)
+		full, err := e.materialize(tl.appID, exe, chain)
 		if err == nil {
 			base = full
 		} else {
@@ -172,132 +423,290 @@ func (e *Engine) Ingest(app *state.AppState) (*ipcapi.SnapshotMeta, error) {
 	delta := diffStates(&base.App, app)
 
 	// Пропускаем создание снапшота, если нет значительных изменений
-	if len(tl.snapshots) > 0 && !delta.WindowsChanged && !delta.ClipboardChanged && len(delta.FilesAdded) == 0 && len(delta.FilesRemoved) == 0 && !delta.PluginChanged {
+	if count > 0 && !delta.WindowsChanged && !delta.ClipboardChanged && !delta.InputLanguageChanged && len(delta.FilesAdded) == 0 && len(delta.FilesRemoved) == 0 && !delta.PluginChanged {
 		return nil, nil
 	}
 
-	// Добавляем проверку, чтобы избежать создания слишком частых снапшотов
-	if len(tl.snapshots) > 0 {
-		lastSnapshot := tl.snapshots[len(tl.snapshots)-1]
-		if app.Timestamp.Sub(lastSnapshot.Timestamp) < 2*time.Second {
-			// Если прошло менее 2 секунд с последнего снапшота и изменения минимальны, пропускаем
-			if !delta.WindowsChanged && len(delta.FilesAdded) <= 1 && len(delta.FilesRemoved) <= 1 {
-				return nil, nil
-			}
+	// Отбрасываем мелкие и слишком частые изменения согласно политике значимости
+	if count > 0 {
+		score := lim.Significance.Score(&delta)
+		if !lim.Significance.Allow(score, app.Timestamp.Sub(last.Timestamp), app.Settled, app.AppID, exe) {
+			return nil, nil
 		}
 	}
 
+	if e.vault != nil && app.ClipboardText != "" {
+		_ = e.vault.Put(app.AppID, app.ClipboardHash, app.ClipboardText)
+	}
+
 	sid := uuid.NewString()
 	var baseID *string
-	if len(tl.snapshots) > 0 {
-		b := tl.snapshots[len(tl.snapshots)-1].SnapshotID
+	var parentID string
+	if count > 0 {
+		b := last.SnapshotID
 		baseID = &b
+		parentID = last.SnapshotID
 	}
 
 	snap := Snapshot{
 		SnapshotID:     sid,
 		AppID:          app.AppID,
 		BaseSnapshotID: baseID,
+		BranchID:       branch,
+		ParentID:       parentID,
+		Redactions:     app.Redactions,
 		Delta:          delta,
 		Timestamp:      app.Timestamp,
 	}
+	if raw, err := json.Marshal(&snap.Delta); err == nil {
+		snap.ramSize = int64(len(raw))
+	}
 
 	// Реже выполняем операции выгрузки на диск для экономии ресурсов
-	if len(tl.snapshots)%50 == 0 { // Увеличиваем интервал с 30 до 50
-		ref, err := e.spillFullSnapshotLocked(app.AppID, &FullSnapshot{App: *app})
+	// The first snapshot of a branch is a keyframe too, so chains never run
+	// back across a fork.
+	branchStart := count > 0 && last.BranchID != branch
+	if count%50 == 0 || branchStart { // Увеличиваем интервал с 30 до 50
+		// Keyframes carry the command line, so only they need its raw form.
+		if e.launch != nil && app.RawCommandLine != "" {
+			ref := e.launch.ref(app.RawCommandLine)
+			if e.launch.Put(app.AppID, ref, app.RawCommandLine) == nil {
+				app.LaunchRef = ref
+			}
+		}
+		job, err := e.spill.prepare(app.AppID, &FullSnapshot{App: *app})
 		if err == nil {
+			// Blocks when the writer queue is full; no timeline lock is held here.
+			if err := e.spill.submit(job); err != nil {
+				return nil, err
+			}
 			snap.Spilled = true
-			snap.DiskRef = ref
+			snap.DiskRef = job.ref
 			snap.BaseSnapshotID = nil
 		}
 	}
 
-	tl.snapshots = append(tl.snapshots, snap)
-
-	if len(tl.snapshots) > e.cfg.MaxSnapshotsPerApp {
-		tl.snapshots = tl.snapshots[len(tl.snapshots)-e.cfg.MaxSnapshotsPerApp:]
+	// The new head mirrors what resolving snap would produce.
+	var next state.AppState
+	if count == 0 || snap.BaseSnapshotID == nil {
+		next = cloneApp(app)
+		next.ClipboardText = ""
+		next.RawCommandLine = ""
+	} else {
+		next = cloneApp(&base.App)
 	}
+	applyDelta(&next, delta)
+	tokens := indexTokens(&next)
+
+	tl.mu.Lock()
+	tl.snapshots = append(tl.snapshots, snap)
+	tl.pos.appended(sid, len(tl.snapshots)-1)
+	tl.ramBytes += snap.ramSize
+	tl.head = &next
+	tl.tip = sid
+	tl.index.add(sid, tokens)
+	tl.dirty = true
+	if len(tl.snapshots) > lim.MaxSnapshotsPerApp {
+		n := len(tl.snapshots) - lim.MaxSnapshotsPerApp
+		dropped := tl.snapshots[:n]
+		tl.snapshots = tl.snapshots[n:]
+		tl.pos.trimmed(snapshotIDs(dropped))
+		e.dropLocked(tl, dropped)
+	}
+	tl.mu.Unlock()
 
 	return &ipcapi.SnapshotMeta{
-		SnapshotID:   sid,
-		AppID:        app.AppID,
-		Timestamp:    app.Timestamp.UTC().UnixMilli(),
-		WindowsCount: len(app.Windows),
-		FilesAdded:   len(delta.FilesAdded),
-		FilesRemoved: len(delta.FilesRemoved),
+		SnapshotID:       sid,
+		AppID:            app.AppID,
+		BranchID:         branch,
+		ParentSnapshotID: parentID,
+		Timestamp:        app.Timestamp.UTC().UnixMilli(),
+		WindowsCount:     len(app.Windows),
+		FilesAdded:       len(delta.FilesAdded),
+		FilesRemoved:     len(delta.FilesRemoved),
+		Redactions:       app.Redactions,
 	}, nil
 }
 
 func (e *Engine) ResolveSnapshot(appID, snapshotID string) (*Snapshot, *FullSnapshot, error) {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
-	tl := e.apps[appID]
+	tl := e.timeline(appID)
 	if tl == nil {
 		return nil, nil, errors.New("unknown app")
 	}
-	s, full, err := e.resolveSnapshotLocked(tl, snapshotID)
-	return s, full, err
-}
+	tl.mu.RLock()
+	idx := tl.indexOf(snapshotID)
+	if idx == -1 {
+		tl.mu.RUnlock()
+		return nil, nil, errors.New("snapshot not found")
+	}
+	sel := tl.snapshots[idx]
+	exe := tl.exe
+	var head *state.AppState
+	if tl.tip == snapshotID {
+		head = tl.head
+	}
+	// A cached snapshot needs no chain; walking it would cost its length.
+	var full *FullSnapshot
+	var chain []Snapshot
+	if head == nil {
+		var ok bool
+		if full, ok = e.cache.get(appID, snapshotID); !ok {
+			_, chain, _ = e.chainLocked(tl, snapshotID)
+		}
+	}
+	tl.mu.RUnlock()
 
-func (e *Engine) resolveSnapshotLocked(tl *appTimeline, snapshotID string) (*Snapshot, *FullSnapshot, error) {
-	var idx = -1
-	for i := range tl.snapshots {
-		if tl.snapshots[i].SnapshotID == snapshotID {
-			idx = i
-			break
+	var err error
+	switch {
+	case head != nil:
+		full = &FullSnapshot{App: cloneApp(head)}
+	case full == nil:
+		full, err = e.materialize(appID, exe, chain)
+		if err != nil {
+			return nil, nil, err
 		}
 	}
+	if e.vault != nil {
+		if text, ok := e.vault.Get(appID, full.App.ClipboardHash); ok {
+			full.App.ClipboardText = text
+		}
+	}
+	if e.launch != nil {
+		if raw, ok := e.launch.Get(appID, full.App.LaunchRef); ok {
+			full.App.RawCommandLine = raw
+		}
+	}
+	return &sel, full, nil
+}
+
+// chainLocked returns the selected snapshot and the delta chain leading to it,
+// newest first, ending at a keyframe or at the oldest base still kept. Each
+// base is looked up by ID, so the walk costs the chain, not the timeline.
+func (e *Engine) chainLocked(tl *appTimeline, snapshotID string) (*Snapshot, []Snapshot, error) {
+	idx := tl.indexOf(snapshotID)
 	if idx == -1 {
 		return nil, nil, errors.New("snapshot not found")
 	}
 
 	var chain []Snapshot
-	for i := idx; i >= 0; i-- {
-		chain = append(chain, tl.snapshots[i])
-		if tl.snapshots[i].Spilled && tl.snapshots[i].DiskRef != "" && tl.snapshots[i].BaseSnapshotID == nil {
+	for i := idx; i >= 0; {
+		s := tl.snapshots[i]
+		chain = append(chain, s)
+		if s.BaseSnapshotID == nil {
 			break
 		}
-		if tl.snapshots[i].BaseSnapshotID == nil && i == 0 {
+		i = tl.indexOf(*s.BaseSnapshotID)
+	}
+
+	sel := tl.snapshots[idx]
+	return &sel, chain, nil
+}
+
+// materialize replays a chain from chainLocked, starting from the newest
+// cached state on the chain if there is one. It may read the keyframe from
+// disk and must be called without holding the timeline lock.
+func (e *Engine) materialize(appID, exe string, chain []Snapshot) (*FullSnapshot, error) {
+	var base *FullSnapshot
+	start := len(chain) - 1
+	for i := range chain {
+		if cached, ok := e.cache.get(appID, chain[i].SnapshotID); ok {
+			if i == 0 {
+				return cached, nil
+			}
+			base, start = cached, i-1
 			break
 		}
 	}
 
-	var base *FullSnapshot
-	last := chain[len(chain)-1]
-	if last.Spilled && last.DiskRef != "" && last.BaseSnapshotID == nil {
-		fs, err := e.loadFullSnapshotLocked(last.DiskRef)
-		if err != nil {
-			return nil, nil, err
+	if base == nil {
+		last := chain[len(chain)-1]
+		if last.Spilled && last.DiskRef != "" && last.BaseSnapshotID == nil {
+			fs, err := e.spill.load(last.DiskRef)
+			if err != nil {
+				return nil, err
+			}
+			base = fs
+		} else {
+			base = &FullSnapshot{App: state.AppState{AppID: appID, ExecutablePath: exe}}
 		}
-		base = fs
-	} else {
-		base = &FullSnapshot{App: state.AppState{AppID: tl.appID, ExecutablePath: tl.exe}}
 	}
 
-	for i := len(chain) - 1; i >= 0; i-- {
+	for i := start; i >= 0; i-- {
 		applyDelta(&base.App, chain[i].Delta)
 	}
-
-	sel := tl.snapshots[idx]
-	return &sel, base, nil
+	e.cache.put(appID, chain[0].SnapshotID, base)
+	return base, nil
 }
 
-func (e *Engine) applyRetentionLocked(tl *appTimeline) {
-	if e.cfg.Retention <= 0 {
+func (e *Engine) applyRetentionLocked(tl *appTimeline, retention time.Duration) {
+	if retention <= 0 {
+		return
+	}
+	cut := e.now().Add(-retention)
+	expired := false
+	for _, s := range tl.snapshots {
+		if !s.Timestamp.After(cut) {
+			expired = true
+			break
+		}
+	}
+	if !expired {
 		return
 	}
-	cut := time.Now().Add(-e.cfg.Retention)
 	var kept []Snapshot
+	var dropped []Snapshot
 	for _, s := range tl.snapshots {
 		if s.Timestamp.After(cut) {
 			kept = append(kept, s)
+		} else {
+			dropped = append(dropped, s)
 		}
 	}
 	tl.snapshots = kept
+	e.dropLocked(tl, dropped)
+}
+
+// sweepRetention applies retention to every timeline, so apps that stopped
+// ingesting lose their expired snapshots too.
+func (e *Engine) sweepRetention() {
+	for _, tl := range e.timelines() {
+		tl.mu.RLock()
+		exe := tl.exe
+		tl.mu.RUnlock()
+		lim := e.limitsFor(tl.appID, exe)
+		tl.ingestMu.Lock()
+		tl.mu.Lock()
+		e.applyRetentionLocked(tl, lim.Retention)
+		tl.mu.Unlock()
+		tl.ingestMu.Unlock()
+	}
+}
+
+// dropLocked releases the accounting and cache entries of snapshots that were
+// removed from tl.snapshots and moves the tip off them.
+func (e *Engine) dropLocked(tl *appTimeline, dropped []Snapshot) {
+	if len(dropped) == 0 {
+		return
+	}
+	for _, s := range dropped {
+		tl.ramBytes -= s.ramSize
+	}
+	ids := snapshotIDs(dropped)
+	e.cache.invalidate(tl.appID, ids)
+	tl.index.remove(ids)
+	tl.resetBranchesLocked()
+	tl.dirty = true
+}
+
+func snapshotIDs(snaps []Snapshot) []string {
+	out := make([]string, 0, len(snaps))
+	for _, s := range snaps {
+		out = append(out, s.SnapshotID)
+	}
+	return out
 }
 
 func diffStates(prev *state.AppState, next *state.AppState) StateDelta {
-	fmt.Printf("[DEBUG] diffStates: prev windows=%d, next windows=%d\n", len(prev.Windows), len(next.Windows))
 	prevW := map[uintptr]state.WindowState{}
 	for _, w := range prev.Windows {
 		prevW[w.HWND] = w
@@ -327,7 +736,6 @@ func diffStates(prev *state.AppState, next *state.AppState) StateDelta {
 			diffs = append(diffs, WindowDiff{HWND: hwnd, Before: &before, After: nil})
 		}
 	}
-	fmt.Printf("[DEBUG] diffStates: found %d window diffs\n", len(diffs))
 
 	prevF := map[string]struct{}{}
 	for _, f := range prev.OpenFiles {
@@ -350,19 +758,28 @@ func diffStates(prev *state.AppState, next *state.AppState) StateDelta {
 		}
 	}
 
-	clipChanged := prev.ClipboardHash != "" && next.ClipboardHash != "" && prev.ClipboardHash != next.ClipboardHash
+	clipChanged := next.ClipboardHash != "" && prev.ClipboardHash != next.ClipboardHash
+	langChanged := next.InputState.InputLanguage != "" && prev.InputState.InputLanguage != next.InputState.InputLanguage
 
 	pluginChanged := !jsonEq(prev.PluginData, next.PluginData)
 
-	return StateDelta{
-		WindowsChanged:   len(diffs) > 0,
-		WindowDiffs:      diffs,
-		FilesAdded:       added,
-		FilesRemoved:     removed,
-		ClipboardChanged: clipChanged,
-		PluginChanged:    pluginChanged,
-		PluginData:       next.PluginData,
+	d := StateDelta{
+		WindowsChanged:       len(diffs) > 0,
+		WindowDiffs:          diffs,
+		FilesAdded:           added,
+		FilesRemoved:         removed,
+		ClipboardChanged:     clipChanged,
+		PluginChanged:        pluginChanged,
+		PluginData:           next.PluginData,
+		InputLanguageChanged: langChanged,
+	}
+	if clipChanged {
+		d.ClipboardHash = next.ClipboardHash
+	}
+	if langChanged {
+		d.InputLanguage = next.InputState.InputLanguage
 	}
+	return d
 }
 
 func applyDelta(app *state.AppState, d StateDelta) {
@@ -404,6 +821,10 @@ func applyDelta(app *state.AppState, d StateDelta) {
 		app.OpenFiles = fs
 	}
 	if d.ClipboardChanged {
+		app.ClipboardHash = d.ClipboardHash
+	}
+	if d.InputLanguageChanged {
+		app.InputState.InputLanguage = d.InputLanguage
 	}
 	if d.PluginChanged {
 		app.PluginData = d.PluginData
@@ -417,7 +838,8 @@ func windowEq(a, b *state.WindowState) bool {
 		a.IsForeground == b.IsForeground &&
 		a.IsMinimized == b.IsMinimized &&
 		a.IsMaximized == b.IsMaximized &&
-		a.VirtualDesktop == b.VirtualDesktop
+		a.VirtualDesktop == b.VirtualDesktop &&
+		a.Title == b.Title
 }
 
 func stringsToLower(s string) string {
@@ -429,54 +851,3 @@ func jsonEq(a, b any) bool {
 	bj, _ := json.Marshal(b)
 	return bytes.Equal(aj, bj)
 }
-
-func (e *Engine) spillFullSnapshotLocked(appID string, fs *FullSnapshot) (string, error) {
-	_ = os.MkdirAll(e.cfg.StorageDir, 0o755)
-	raw, err := json.Marshal(fs)
-	if err != nil {
-		return "", err
-	}
-	sum := sha256.Sum256(raw)
-	name := hex.EncodeToString(sum[:16]) + ".json.gz"
-	path := filepath.Join(e.cfg.StorageDir, appID, name)
-	_ = os.MkdirAll(filepath.Dir(path), 0o755)
-
-	var buf bytes.Buffer
-	zw := gzip.NewWriter(&buf)
-	_, _ = zw.Write(raw)
-	_ = zw.Close()
-	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
-		return "", err
-	}
-	return filepath.ToSlash(filepath.Join(appID, name)), nil
-}
-
-func (e *Engine) loadFullSnapshotLocked(ref string) (*FullSnapshot, error) {
-	path := filepath.Join(e.cfg.StorageDir, filepath.FromSlash(ref))
-	b, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-	zr, err := gzip.NewReader(bytes.NewReader(b))
-	if err != nil {
-		return nil, err
-	}
-	defer zr.Close()
-	raw, err := ioReadAllLimit(zr, 10<<20) // 10MB guard
-	if err != nil {
-		return nil, err
-	}
-	var fs FullSnapshot
-	if err := json.Unmarshal(raw, &fs); err != nil {
-		return nil, err
-	}
-	return &fs, nil
-}
-
-func ioReadAllLimit(r *gzip.Reader, limit int64) ([]byte, error) {
-	var buf bytes.Buffer
-	if _, err := buf.ReadFrom(io.LimitReader(r, limit)); err != nil {
-		return nil, err
-	}
-	return buf.Bytes(), nil
-}
@@ -0,0 +1,178 @@
+package snapshot
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"Rewinder/internal/ipcapi"
+	"Rewinder/internal/state"
+)
+
+// eagerSignificance turns every change into a snapshot.
+func eagerSignificance() SignificancePolicy {
+	p := DefaultSignificance()
+	p.BurstThreshold = p.Threshold
+	p.MinInterval = 0
+	p.MovePixels = 1
+	return p
+}
+
+func newTestEngine(t testing.TB, cfg EngineConfig) *Engine {
+	t.Helper()
+	if cfg.StorageDir == "" {
+		cfg.StorageDir = t.TempDir()
+	}
+	if cfg.Significance.isZero() {
+		cfg.Significance = eagerSignificance()
+	}
+	e := NewEngine(cfg)
+	t.Cleanup(func() { _ = e.Close() })
+	return e
+}
+
+// testApp is the state of appID at step i: one window that moves with i and
+// a title that changes with it.
+func testApp(appID string, i int, at time.Time) *state.AppState {
+	return &state.AppState{
+		AppID:          appID,
+		PID:            100,
+		ExecutablePath: `C:\apps\` + appID + `.exe`,
+		CommandLine:    appID + ".exe --open report",
+		Windows: []state.WindowState{{
+			HWND:  1,
+			Rect:  state.Rect{Left: int32(i * 20), Top: 0, Right: int32(i*20 + 400), Bottom: 300},
+			Title: fmt.Sprintf("report %d - %s", i, appID),
+		}},
+		OpenFiles: []state.FileRef{{Path: fmt.Sprintf(`C:\docs\report%d.txt`, i%5)}},
+		Timestamp: at,
+	}
+}
+
+func TestConcurrentEngineOps(t *testing.T) {
+	e := newTestEngine(t, EngineConfig{MaxSnapshotsPerApp: 120})
+	apps := []string{"alpha", "beta", "gamma"}
+	start := time.Now()
+	const steps = 150
+
+	var wg sync.WaitGroup
+	for _, appID := range apps {
+		wg.Add(1)
+		go func(appID string) {
+			defer wg.Done()
+			for i := 0; i < steps; i++ {
+				if _, err := e.Ingest(testApp(appID, i, start.Add(time.Duration(i)*time.Millisecond))); err != nil {
+					t.Errorf("ingest %s: %v", appID, err)
+					return
+				}
+			}
+		}(appID)
+	}
+
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	reader := func(seed int64, op func(r *rand.Rand, appID string)) {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			r := rand.New(rand.NewSource(seed))
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				op(r, apps[r.Intn(len(apps))])
+			}
+		}()
+	}
+	pick := func(r *rand.Rand, appID string) string {
+		tl := e.GetTimeline(appID)
+		if len(tl) == 0 {
+			return ""
+		}
+		return tl[r.Intn(len(tl))].SnapshotID
+	}
+	reader(1, func(r *rand.Rand, appID string) {
+		if id := pick(r, appID); id != "" {
+			// The snapshot may be purged or dropped in between.
+			_, _, _ = e.ResolveSnapshot(appID, id)
+		}
+	})
+	reader(2, func(r *rand.Rand, appID string) {
+		if id := pick(r, appID); id != "" {
+			_, _, _ = e.BeginBranch(appID, id)
+		}
+	})
+	reader(3, func(r *rand.Rand, appID string) {
+		_ = e.Search("report", ipcapi.SearchFilter{AppID: appID})
+		_ = e.GetBranches(appID)
+	})
+	reader(4, func(r *rand.Rand, appID string) {
+		from := start.Add(time.Duration(r.Intn(steps)) * time.Millisecond)
+		_, _ = e.Purge(appID, from, from.Add(2*time.Millisecond))
+		time.Sleep(time.Millisecond)
+	})
+	reader(5, func(r *rand.Rand, appID string) {
+		_ = e.GetApps()
+		_ = e.Stats()
+	})
+
+	wg.Wait()
+	close(stop)
+	readers.Wait()
+
+	for _, appID := range apps {
+		tl := e.GetTimeline(appID)
+		if len(tl) == 0 {
+			t.Fatalf("%s: empty timeline", appID)
+		}
+		if len(tl) > 120 {
+			t.Errorf("%s: %d snapshots, limit is 120", appID, len(tl))
+		}
+		for _, m := range tl {
+			if _, full, err := e.ResolveSnapshot(appID, m.SnapshotID); err != nil || full == nil {
+				t.Fatalf("%s: resolve %s: %v", appID, m.SnapshotID, err)
+			}
+		}
+	}
+}
+
+// Positions survive appends, drops at the front past the limit and purges
+// in the middle.
+func TestIndexOf(t *testing.T) {
+	e := newTestEngine(t, EngineConfig{MaxSnapshotsPerApp: 10})
+	start := time.Now()
+	check := func() {
+		t.Helper()
+		tl := e.timeline("tool")
+		tl.mu.RLock()
+		defer tl.mu.RUnlock()
+		for i, s := range tl.snapshots {
+			if got := tl.indexOf(s.SnapshotID); got != i {
+				t.Fatalf("indexOf(%d) = %d", i, got)
+			}
+		}
+		if got := tl.indexOf("gone"); got != -1 {
+			t.Fatalf("indexOf of an unknown ID = %d", got)
+		}
+	}
+	for i := 0; i < 25; i++ {
+		if _, err := e.Ingest(testApp("tool", i, start.Add(time.Duration(i)*time.Second))); err != nil {
+			t.Fatal(err)
+		}
+		check()
+	}
+	if n, err := e.Purge("tool", start.Add(17*time.Second), start.Add(19*time.Second)); err != nil || n != 3 {
+		t.Fatalf("purged %d: %v", n, err)
+	}
+	check()
+	for i := 25; i < 30; i++ {
+		if _, err := e.Ingest(testApp("tool", i, start.Add(time.Duration(i)*time.Second))); err != nil {
+			t.Fatal(err)
+		}
+		check()
+	}
+}
@@ -0,0 +1,71 @@
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const storeLockFile = "rewinder.lock"
+
+var (
+	ErrStoreLocked = errors.New("snapshot store is in use by another instance")
+	ErrReadOnly    = errors.New("snapshot store is open read-only")
+)
+
+// StoreLock is the exclusive lock of one instance on a snapshot store. The
+// lock file holds the owner's PID for error messages; the lock itself is an
+// OS file lock, so it goes away with the process.
+type StoreLock struct {
+	f *os.File
+}
+
+// LockStore takes the lock on the store in dir. When another instance holds
+// it the error wraps ErrStoreLocked.
+func LockStore(dir string) (*StoreLock, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, storeLockFile)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		_ = f.Close()
+		if errors.Is(err, errLockHeld) {
+			if pid := lockOwner(path); pid > 0 {
+				return nil, fmt.Errorf("%w (pid %d)", ErrStoreLocked, pid)
+			}
+			return nil, ErrStoreLocked
+		}
+		return nil, err
+	}
+	_ = f.Truncate(0)
+	_, _ = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	return &StoreLock{f: f}, nil
+}
+
+func lockOwner(path string) int {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(b)))
+	return pid
+}
+
+// Close releases the lock. The file stays so the PID of the last owner can
+// be seen.
+func (l *StoreLock) Close() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	_ = unlockFile(l.f)
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
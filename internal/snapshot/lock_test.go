@@ -0,0 +1,72 @@
+package snapshot
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStoreLock(t *testing.T) {
+	dir := t.TempDir()
+	l, err := LockStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(filepath.Join(dir, storeLockFile))
+	if strings.TrimSpace(string(b)) != strconv.Itoa(os.Getpid()) {
+		t.Errorf("lock file holds %q", b)
+	}
+
+	_, err = LockStore(dir)
+	if !errors.Is(err, ErrStoreLocked) || !strings.Contains(err.Error(), "pid "+strconv.Itoa(os.Getpid())) {
+		t.Fatalf("second lock: %v", err)
+	}
+
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	l, err = LockStore(dir)
+	if err != nil {
+		t.Fatalf("lock after release: %v", err)
+	}
+	_ = l.Close()
+}
+
+// A read-only engine writes nothing but still reads the vaults, so its
+// restores have the clipboard text and the unredacted command line.
+func TestReadOnlyEngine(t *testing.T) {
+	dir := t.TempDir()
+	w := newTestEngine(t, EngineConfig{StorageDir: dir, ClipboardVault: true})
+	app := testApp("tool", 0, time.Now())
+	app.ClipboardText, app.ClipboardHash = "copied text", "c1"
+	app.CommandLine = "tool --token [secret]"
+	app.RawCommandLine = "tool --token abc"
+	meta, err := w.Ingest(app)
+	if err != nil || meta == nil {
+		t.Fatalf("ingest: %v", err)
+	}
+	_ = w.Close()
+
+	r := newTestEngine(t, EngineConfig{StorageDir: dir, ClipboardVault: true, ReadOnly: true})
+	_, full, err := r.ResolveSnapshot("tool", meta.SnapshotID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if full.App.ClipboardText != "copied text" || full.App.RawCommandLine != "tool --token abc" {
+		t.Errorf("resolved clipboard %q, command line %q", full.App.ClipboardText, full.App.RawCommandLine)
+	}
+	if _, err := r.Ingest(testApp("tool", 1, time.Now())); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("ingest into a read-only store: %v", err)
+	}
+
+	// Nor does it create a vault key for a store that has none.
+	empty := t.TempDir()
+	_ = newTestEngine(t, EngineConfig{StorageDir: empty, ClipboardVault: true, ReadOnly: true}).Close()
+	if _, err := os.Stat(filepath.Join(empty, vaultKeyFile)); !os.IsNotExist(err) {
+		t.Errorf("read-only engine created a vault key: %v", err)
+	}
+}
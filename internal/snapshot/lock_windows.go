@@ -0,0 +1,22 @@
+package snapshot
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+var errLockHeld = windows.ERROR_LOCK_VIOLATION
+
+// The locked byte lies far past the PID so other processes can still read it.
+const lockOffsetHigh = 1
+
+func lockFile(f *os.File) error {
+	ol := windows.Overlapped{OffsetHigh: lockOffsetHigh}
+	return windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, &ol)
+}
+
+func unlockFile(f *os.File) error {
+	ol := windows.Overlapped{OffsetHigh: lockOffsetHigh}
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, &ol)
+}
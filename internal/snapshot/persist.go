@@ -0,0 +1,206 @@
+package snapshot
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	timelineFile    = "timeline.json.gz"
+	timelineVersion = 1
+)
+
+// timelineDoc is the on-disk form of a timeline: snapshot metadata and
+// deltas plus the search index. Keyframes stay in their own spill files.
+type timelineDoc struct {
+	Version      int          `json:"version"`
+	AppID        string       `json:"appID"`
+	Exe          string       `json:"exe"`
+	Name         string       `json:"name"`
+	LastActivity time.Time    `json:"lastActivity"`
+	Snapshots    []Snapshot   `json:"snapshots"`
+	Branches     []Branch     `json:"branches,omitempty"`
+	ActiveBranch string       `json:"activeBranch,omitempty"`
+	Tip          string       `json:"tip,omitempty"`
+	Index        *searchIndex `json:"index,omitempty"`
+}
+
+func (e *Engine) persistLoop(interval time.Duration) {
+	defer e.persistWG.Done()
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-e.stopCh:
+			return
+		case <-t.C:
+			if e.cfg.ReadOnly {
+				e.loadTimelines()
+			} else {
+				e.sweepRetention()
+				e.flushTimelines()
+			}
+		}
+	}
+}
+
+func (e *Engine) flushTimelines() {
+	for _, tl := range e.timelines() {
+		_ = e.saveTimeline(tl)
+	}
+}
+
+// saveTimeline writes tl if it changed since the last save. Only encoding
+// happens under tl.mu; compression and the write do not hold it.
+func (e *Engine) saveTimeline(tl *appTimeline) error {
+	tl.persistMu.Lock()
+	defer tl.persistMu.Unlock()
+	if tl.deleted {
+		return nil
+	}
+
+	tl.mu.Lock()
+	if !tl.dirty {
+		tl.mu.Unlock()
+		return nil
+	}
+	tl.dirty = false
+	raw, err := json.Marshal(timelineDoc{
+		Version:      timelineVersion,
+		AppID:        tl.appID,
+		Exe:          tl.exe,
+		Name:         tl.name,
+		LastActivity: tl.lastActivity,
+		Snapshots:    tl.snapshots,
+		Branches:     tl.branches,
+		ActiveBranch: tl.activeBranch,
+		Tip:          tl.tip,
+		Index:        tl.index,
+	})
+	tl.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write(raw)
+	_ = zw.Close()
+
+	path := filepath.Join(e.cfg.StorageDir, tl.appID, timelineFile)
+	_ = os.MkdirAll(filepath.Dir(path), 0o755)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o600); err != nil {
+		e.markDirty(tl)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		e.markDirty(tl)
+		return err
+	}
+	return nil
+}
+
+func (e *Engine) markDirty(tl *appTimeline) {
+	tl.mu.Lock()
+	tl.dirty = true
+	tl.mu.Unlock()
+}
+
+// loadTimelines restores the timelines saved under StorageDir, replacing
+// those in memory, without the snapshots retention has expired since. Heads are materialized lazily on the next ingest or
+// resolve.
+func (e *Engine) loadTimelines() {
+	entries, err := os.ReadDir(e.cfg.StorageDir)
+	if err != nil {
+		return
+	}
+	apps := map[string]*appTimeline{}
+	for _, ent := range entries {
+		if !ent.IsDir() {
+			continue
+		}
+		doc, err := readTimelineDoc(filepath.Join(e.cfg.StorageDir, ent.Name(), timelineFile))
+		if err != nil || doc.Version != timelineVersion || doc.AppID != ent.Name() {
+			continue
+		}
+		tl := &appTimeline{
+			appID:        doc.AppID,
+			exe:          doc.Exe,
+			name:         doc.Name,
+			lastActivity: doc.LastActivity,
+			snapshots:    doc.Snapshots,
+			branches:     doc.Branches,
+			activeBranch: doc.ActiveBranch,
+			tip:          doc.Tip,
+			index:        doc.Index,
+		}
+		upgradeBranches(tl)
+		if tl.index == nil || tl.index.Postings == nil {
+			tl.index = newSearchIndex()
+		} else {
+			tl.index.rebuildLookup()
+		}
+		for i := range tl.snapshots {
+			if raw, err := json.Marshal(&tl.snapshots[i].Delta); err == nil {
+				tl.snapshots[i].ramSize = int64(len(raw))
+				tl.ramBytes += tl.snapshots[i].ramSize
+			}
+		}
+		e.applyRetentionLocked(tl, e.limitsFor(tl.appID, tl.exe).Retention)
+		apps[tl.appID] = tl
+	}
+	e.mu.Lock()
+	e.apps = apps
+	e.mu.Unlock()
+}
+
+// upgradeBranches puts timelines saved before branching existed on the main
+// branch as one linear history.
+func upgradeBranches(tl *appTimeline) {
+	if len(tl.branches) == 0 {
+		created := tl.lastActivity
+		if len(tl.snapshots) > 0 {
+			created = tl.snapshots[0].Timestamp
+		}
+		tl.branches = []Branch{{ID: MainBranch, CreatedAt: created}}
+	}
+	if tl.activeBranch == "" {
+		tl.activeBranch = MainBranch
+	}
+	for i := range tl.snapshots {
+		s := &tl.snapshots[i]
+		if s.BranchID == "" {
+			s.BranchID = MainBranch
+			if i > 0 {
+				s.ParentID = tl.snapshots[i-1].SnapshotID
+			}
+		}
+	}
+	tl.resetBranchesLocked()
+}
+
+func readTimelineDoc(path string) (*timelineDoc, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	raw, err := ioReadAllLimit(zr, 256<<20)
+	if err != nil {
+		return nil, err
+	}
+	var doc timelineDoc
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
@@ -0,0 +1,55 @@
+package snapshot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetentionOnLoad(t *testing.T) {
+	dir := t.TempDir()
+	e := newTestEngine(t, EngineConfig{StorageDir: dir, Retention: 1000 * time.Hour})
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		at := now.Add(-3 * time.Hour).Add(time.Duration(i) * time.Second)
+		if i >= 3 {
+			at = now.Add(-10 * time.Minute).Add(time.Duration(i) * time.Second)
+		}
+		if _, err := e.Ingest(testApp("alpha", i, at)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = e.Close()
+
+	e = newTestEngine(t, EngineConfig{StorageDir: dir, Retention: time.Hour})
+	if got := e.GetTimeline("alpha"); len(got) != 2 {
+		t.Fatalf("%d snapshots after load, want 2", len(got))
+	}
+	_ = e.Close()
+
+	// The expired snapshots are gone from disk too.
+	e = newTestEngine(t, EngineConfig{StorageDir: dir, Retention: 1000 * time.Hour})
+	if got := e.GetTimeline("alpha"); len(got) != 2 {
+		t.Fatalf("%d snapshots after reload, want 2", len(got))
+	}
+}
+
+func TestRetentionSweep(t *testing.T) {
+	e := newTestEngine(t, EngineConfig{Retention: 300 * time.Millisecond, PersistInterval: 20 * time.Millisecond})
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := e.Ingest(testApp("idle", i, now.Add(time.Duration(i)*time.Millisecond))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := e.GetTimeline("idle"); len(got) != 3 {
+		t.Fatalf("%d snapshots, want 3", len(got))
+	}
+	// No further ingest: only the periodic sweep can expire them.
+	deadline := time.Now().Add(3 * time.Second)
+	for len(e.GetTimeline("idle")) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d snapshots still kept past retention", len(e.GetTimeline("idle")))
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
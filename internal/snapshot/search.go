@@ -0,0 +1,303 @@
+package snapshot
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"Rewinder/internal/ipcapi"
+	"Rewinder/internal/state"
+)
+
+const (
+	FieldTitle   = "title"
+	FieldPath    = "path"
+	FieldCmdline = "cmdline"
+	FieldPlugin  = "plugin"
+)
+
+var searchFields = []string{FieldTitle, FieldPath, FieldCmdline, FieldPlugin}
+
+var fieldWeights = map[uint8]float64{0: 3, 1: 2, 2: 1, 3: 1}
+
+// searchIndex is an inverted index from tokens to the snapshots whose state
+// contained them. It is kept per timeline, updated on every ingest and
+// persisted with the timeline.
+type searchIndex struct {
+	Postings map[string][]posting `json:"postings"`
+
+	// bySnapshot lists the tokens of each snapshot so removal is targeted.
+	bySnapshot map[string][]string
+	// tokens holds the keys of Postings sorted, for prefix lookups.
+	tokens []string
+}
+
+type posting struct {
+	SnapshotID string `json:"s"`
+	Field      uint8  `json:"f"`
+	Count      uint16 `json:"c"`
+}
+
+type tokenKey struct {
+	token string
+	field uint8
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{Postings: map[string][]posting{}, bySnapshot: map[string][]string{}}
+}
+
+// rebuildLookup restores bySnapshot after the index was loaded from disk.
+func (ix *searchIndex) rebuildLookup() {
+	ix.bySnapshot = map[string][]string{}
+	ix.tokens = make([]string, 0, len(ix.Postings))
+	for tok, ps := range ix.Postings {
+		ix.tokens = append(ix.tokens, tok)
+		for _, p := range ps {
+			ix.bySnapshot[p.SnapshotID] = appendUnique(ix.bySnapshot[p.SnapshotID], tok)
+		}
+	}
+	sort.Strings(ix.tokens)
+}
+
+// withPrefix returns the indexed tokens that start with prefix.
+func (ix *searchIndex) withPrefix(prefix string) []string {
+	i := sort.SearchStrings(ix.tokens, prefix)
+	j := i
+	for j < len(ix.tokens) && strings.HasPrefix(ix.tokens[j], prefix) {
+		j++
+	}
+	return ix.tokens[i:j]
+}
+
+// indexTokens extracts the searchable tokens of a materialized state. It runs
+// outside the timeline lock.
+func indexTokens(app *state.AppState) map[tokenKey]int {
+	out := map[tokenKey]int{}
+	add := func(field uint8, text string) {
+		for _, tok := range tokenize(text) {
+			out[tokenKey{tok, field}]++
+		}
+	}
+	for _, w := range app.Windows {
+		add(0, w.Title)
+	}
+	add(1, app.ExecutablePath)
+	for _, f := range app.OpenFiles {
+		add(1, f.Path)
+	}
+	add(2, app.CommandLine)
+	add(2, app.WorkingDir)
+	var walk func(v any)
+	walk = func(v any) {
+		switch t := v.(type) {
+		case string:
+			add(3, t)
+		case []string:
+			for _, s := range t {
+				add(3, s)
+			}
+		case []any:
+			for _, x := range t {
+				walk(x)
+			}
+		case map[string]any:
+			for _, x := range t {
+				walk(x)
+			}
+		}
+	}
+	walk(app.PluginData)
+	return out
+}
+
+func (ix *searchIndex) add(snapshotID string, tokens map[tokenKey]int) {
+	for k, n := range tokens {
+		if n > 0xffff {
+			n = 0xffff
+		}
+		if _, ok := ix.Postings[k.token]; !ok {
+			i := sort.SearchStrings(ix.tokens, k.token)
+			ix.tokens = append(ix.tokens, "")
+			copy(ix.tokens[i+1:], ix.tokens[i:])
+			ix.tokens[i] = k.token
+		}
+		ix.Postings[k.token] = append(ix.Postings[k.token], posting{SnapshotID: snapshotID, Field: k.field, Count: uint16(n)})
+		ix.bySnapshot[snapshotID] = appendUnique(ix.bySnapshot[snapshotID], k.token)
+	}
+}
+
+func (ix *searchIndex) remove(snapshotIDs []string) {
+	for _, id := range snapshotIDs {
+		for _, tok := range ix.bySnapshot[id] {
+			ps := ix.Postings[tok]
+			kept := ps[:0]
+			for _, p := range ps {
+				if p.SnapshotID != id {
+					kept = append(kept, p)
+				}
+			}
+			if len(kept) == 0 {
+				delete(ix.Postings, tok)
+				if i := sort.SearchStrings(ix.tokens, tok); i < len(ix.tokens) && ix.tokens[i] == tok {
+					ix.tokens = append(ix.tokens[:i], ix.tokens[i+1:]...)
+				}
+			} else {
+				ix.Postings[tok] = kept
+			}
+		}
+		delete(ix.bySnapshot, id)
+	}
+}
+
+// Search looks up snapshots across all apps whose state contains every term
+// of query. Terms also match as prefixes of indexed tokens. Hits are ranked by
+// term frequency weighted by field, newest first on ties.
+func (e *Engine) Search(query string, f ipcapi.SearchFilter) []ipcapi.SearchHit {
+	terms := tokenize(query)
+	if len(terms) == 0 {
+		return nil
+	}
+	fields := map[uint8]bool{}
+	for _, name := range f.Fields {
+		for i, known := range searchFields {
+			if strings.EqualFold(name, known) {
+				fields[uint8(i)] = true
+			}
+		}
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+
+	var hits []ipcapi.SearchHit
+	for _, tl := range e.timelines() {
+		if f.AppID != "" && tl.appID != f.AppID {
+			continue
+		}
+		tl.mu.RLock()
+		hits = append(hits, tl.searchLocked(terms, fields, f)...)
+		tl.mu.RUnlock()
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Timestamp > hits[j].Timestamp
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+type searchMatch struct {
+	score  float64
+	fields map[string]bool
+	terms  map[string]bool
+}
+
+func (tl *appTimeline) searchLocked(terms []string, fields map[uint8]bool, f ipcapi.SearchFilter) []ipcapi.SearchHit {
+	if tl.index == nil {
+		return nil
+	}
+	var matches map[string]*searchMatch
+	for _, term := range terms {
+		cur := map[string]*searchMatch{}
+		for _, tok := range tl.index.withPrefix(term) {
+			for _, p := range tl.index.Postings[tok] {
+				if len(fields) > 0 && !fields[p.Field] {
+					continue
+				}
+				m := cur[p.SnapshotID]
+				if m == nil {
+					m = &searchMatch{fields: map[string]bool{}, terms: map[string]bool{}}
+					cur[p.SnapshotID] = m
+				}
+				weight := fieldWeights[p.Field]
+				if tok != term {
+					weight /= 2
+				}
+				m.score += weight * float64(p.Count)
+				m.fields[searchFields[p.Field]] = true
+				m.terms[tok] = true
+			}
+		}
+		if matches == nil {
+			matches = cur
+			continue
+		}
+		// Every term has to match.
+		for id, m := range matches {
+			c := cur[id]
+			if c == nil {
+				delete(matches, id)
+				continue
+			}
+			m.score += c.score
+			for k := range c.fields {
+				m.fields[k] = true
+			}
+			for k := range c.terms {
+				m.terms[k] = true
+			}
+		}
+	}
+
+	var out []ipcapi.SearchHit
+	for _, s := range tl.snapshots {
+		m := matches[s.SnapshotID]
+		if m == nil {
+			continue
+		}
+		ts := s.Timestamp.UTC().UnixMilli()
+		if (f.FromUTC > 0 && ts < f.FromUTC) || (f.ToUTC > 0 && ts > f.ToUTC) {
+			continue
+		}
+		out = append(out, ipcapi.SearchHit{
+			AppID:      tl.appID,
+			AppName:    tl.name,
+			SnapshotID: s.SnapshotID,
+			Timestamp:  ts,
+			Score:      m.score,
+			Fields:     sortedKeys(m.fields),
+			Terms:      sortedKeys(m.terms),
+		})
+	}
+	return out
+}
+
+func tokenize(s string) []string {
+	var out []string
+	for _, tok := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(tok) < 2 {
+			continue
+		}
+		if r := []rune(tok); len(r) > 64 {
+			tok = string(r[:64])
+		}
+		out = append(out, tok)
+	}
+	return out
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, x := range list {
+		if x == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
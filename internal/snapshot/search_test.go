@@ -0,0 +1,80 @@
+package snapshot
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"Rewinder/internal/ipcapi"
+)
+
+// checkTokens verifies the sorted token list matches the postings.
+func checkTokens(t *testing.T, ix *searchIndex) {
+	t.Helper()
+	if !sort.StringsAreSorted(ix.tokens) {
+		t.Fatalf("tokens not sorted: %v", ix.tokens)
+	}
+	if len(ix.tokens) != len(ix.Postings) {
+		t.Fatalf("%d tokens, %d postings", len(ix.tokens), len(ix.Postings))
+	}
+	for _, tok := range ix.tokens {
+		if _, ok := ix.Postings[tok]; !ok {
+			t.Fatalf("token %q has no postings", tok)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	dir := t.TempDir()
+	e := newTestEngine(t, EngineConfig{StorageDir: dir})
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < 5; i++ {
+		if _, err := e.Ingest(testApp("alpha", i, start.Add(time.Duration(i)*time.Second))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tl := e.timeline("alpha")
+
+	cases := []struct {
+		query string
+		want  int
+	}{
+		{"repo", 5},
+		{"report", 5},
+		{"report3", 1},
+		{"rep alpha", 5},
+		{"report3 alpha", 1},
+		{"report3 report4", 0},
+		{"zzz", 0},
+	}
+	for _, c := range cases {
+		if got := e.Search(c.query, ipcapi.SearchFilter{}); len(got) != c.want {
+			t.Errorf("Search(%q): %d hits, want %d", c.query, len(got), c.want)
+		}
+	}
+	hits := e.Search("report3", ipcapi.SearchFilter{})
+	if len(hits) == 1 && (len(hits[0].Terms) != 1 || hits[0].Terms[0] != "report3") {
+		t.Errorf("terms = %v", hits[0].Terms)
+	}
+	checkTokens(t, tl.index)
+
+	// Purging the only snapshot with report3 open drops the token.
+	at := time.UnixMilli(hits[0].Timestamp)
+	if n, err := e.Purge("alpha", at, at.Add(time.Millisecond)); err != nil || n != 1 {
+		t.Fatalf("purge: %d, %v", n, err)
+	}
+	if got := e.Search("report3", ipcapi.SearchFilter{}); len(got) != 0 {
+		t.Errorf("report3 after purge: %d hits", len(got))
+	}
+	tl.mu.RLock()
+	checkTokens(t, tl.index)
+	tl.mu.RUnlock()
+
+	// The token list is rebuilt when the index is loaded.
+	_ = e.Close()
+	e = newTestEngine(t, EngineConfig{StorageDir: dir})
+	checkTokens(t, e.timeline("alpha").index)
+	if got := e.Search("report1", ipcapi.SearchFilter{}); len(got) != 1 {
+		t.Errorf("report1 after reload: %d hits", len(got))
+	}
+}
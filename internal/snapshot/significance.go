@@ -0,0 +1,139 @@
+package snapshot
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"Rewinder/internal/state"
+)
+
+// SignificancePolicy scores a delta and decides whether it is worth a
+// snapshot. A delta must reach Threshold; within MinInterval of the previous
+// snapshot it must also reach BurstThreshold.
+type SignificancePolicy struct {
+	Threshold      float64
+	BurstThreshold float64
+	MinInterval    time.Duration
+	// AppMinIntervals overrides MinInterval, keyed by appID or exe name.
+	AppMinIntervals map[string]time.Duration
+
+	WindowMoved          float64
+	MovePixels           int32
+	WindowOpened         float64
+	WindowClosed         float64
+	WindowStateChanged   float64
+	FocusChanged         float64
+	FileChanged          float64
+	PluginChanged        float64
+	ClipboardChanged     float64
+	InputLanguageChanged float64
+	// TitleChanged is 0 by default: a title that changes on its own, e.g. a
+	// clock or a progress counter, is no reason for a snapshot.
+	TitleChanged float64
+}
+
+func DefaultSignificance() SignificancePolicy {
+	return SignificancePolicy{
+		Threshold:            1,
+		BurstThreshold:       4,
+		MinInterval:          2 * time.Second,
+		WindowMoved:          1,
+		MovePixels:           16,
+		WindowOpened:         4,
+		WindowClosed:         4,
+		WindowStateChanged:   2,
+		FocusChanged:         1,
+		FileChanged:          4,
+		PluginChanged:        1,
+		ClipboardChanged:     2,
+		InputLanguageChanged: 1,
+	}
+}
+
+func (p SignificancePolicy) Score(d *StateDelta) float64 {
+	var score float64
+	for _, wd := range d.WindowDiffs {
+		switch {
+		case wd.Before == nil && wd.After != nil:
+			score += p.WindowOpened
+		case wd.After == nil && wd.Before != nil:
+			score += p.WindowClosed
+		case wd.Before != nil && wd.After != nil:
+			b, a := wd.Before, wd.After
+			if b.IsForeground != a.IsForeground {
+				score += p.FocusChanged
+			}
+			if b.IsMinimized != a.IsMinimized || b.IsMaximized != a.IsMaximized ||
+				b.MonitorID != a.MonitorID || b.VirtualDesktop != a.VirtualDesktop {
+				score += p.WindowStateChanged
+			}
+			if rectShift(b.Rect, a.Rect) >= p.MovePixels && b.Rect != a.Rect {
+				score += p.WindowMoved
+			}
+			if b.Title != a.Title {
+				score += p.TitleChanged
+			}
+		}
+	}
+	score += p.FileChanged * float64(len(d.FilesAdded)+len(d.FilesRemoved))
+	if d.PluginChanged {
+		score += p.PluginChanged
+	}
+	if d.ClipboardChanged {
+		score += p.ClipboardChanged
+	}
+	if d.InputLanguageChanged {
+		score += p.InputLanguageChanged
+	}
+	return score
+}
+
+func (p SignificancePolicy) MinIntervalFor(appID, exePath string) time.Duration {
+	if v, ok := p.AppMinIntervals[appID]; ok {
+		return v
+	}
+	if exePath != "" {
+		if v, ok := p.AppMinIntervals[strings.ToLower(filepath.Base(exePath))]; ok {
+			return v
+		}
+	}
+	return p.MinInterval
+}
+
+// Allow reports whether a delta with score, arriving sinceLast after the
+// previous snapshot, should become a snapshot. A settled delta, the final
+// state after a burst of moves, is not held to MinInterval: dropping it
+// would leave the window where the burst started.
+func (p SignificancePolicy) Allow(score float64, sinceLast time.Duration, settled bool, appID, exePath string) bool {
+	if score <= 0 || score < p.Threshold {
+		return false
+	}
+	if !settled && sinceLast < p.MinIntervalFor(appID, exePath) && score < p.BurstThreshold {
+		return false
+	}
+	return true
+}
+
+func (p SignificancePolicy) isZero() bool {
+	return p.Threshold == 0 && p.BurstThreshold == 0 && p.WindowMoved == 0 && p.WindowOpened == 0 &&
+		p.WindowClosed == 0 && p.WindowStateChanged == 0 && p.FocusChanged == 0 && p.FileChanged == 0 &&
+		p.PluginChanged == 0 && p.ClipboardChanged == 0 && p.InputLanguageChanged == 0 && p.TitleChanged == 0
+}
+
+func rectShift(a, b state.Rect) int32 {
+	m := absDiff(a.Left, b.Left)
+	for _, v := range []int32{absDiff(a.Top, b.Top), absDiff(a.Right, b.Right), absDiff(a.Bottom, b.Bottom)} {
+		if v > m {
+			m = v
+		}
+	}
+	return m
+}
+
+func absDiff(a, b int32) int32 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
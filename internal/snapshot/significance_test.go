@@ -0,0 +1,88 @@
+package snapshot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSignificanceAllow(t *testing.T) {
+	p := DefaultSignificance()
+	p.AppMinIntervals = map[string]time.Duration{"slow.exe": time.Minute}
+	cases := []struct {
+		name      string
+		score     float64
+		sinceLast time.Duration
+		settled   bool
+		exe       string
+		want      bool
+	}{
+		{"below threshold", 0.5, time.Hour, false, "", false},
+		{"zero score settled", 0, time.Hour, true, "", false},
+		{"after min interval", 1, 3 * time.Second, false, "", true},
+		{"small change in a burst", 1, time.Second, false, "", false},
+		{"large change in a burst", 4, time.Second, false, "", true},
+		{"settled move in a burst", 1, time.Second, true, "", true},
+		{"settled below threshold", 0.5, time.Second, true, "", false},
+		{"app min interval", 1, 30 * time.Second, false, "/opt/slow/slow.exe", false},
+		{"settled within app min interval", 1, 30 * time.Second, true, "/opt/slow/slow.exe", true},
+	}
+	for _, c := range cases {
+		if got := p.Allow(c.score, c.sinceLast, c.settled, "app", c.exe); got != c.want {
+			t.Errorf("%s: Allow = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+// A window dragged and released within MinInterval of the previous snapshot
+// scores only WindowMoved; as the settled capture it must still be kept.
+func TestIngestSettledMove(t *testing.T) {
+	e := newTestEngine(t, EngineConfig{Significance: DefaultSignificance()})
+	start := time.Now()
+	if _, err := e.Ingest(testApp("drag", 0, start)); err != nil {
+		t.Fatal(err)
+	}
+
+	moved := testApp("drag", 0, start.Add(500*time.Millisecond))
+	moved.Windows[0].Rect.Left += 100
+	moved.Windows[0].Rect.Right += 100
+	if meta, err := e.Ingest(moved); err != nil || meta != nil {
+		t.Fatalf("unsettled move within MinInterval: %v, %v", meta, err)
+	}
+
+	moved.Timestamp = start.Add(time.Second)
+	moved.Settled = true
+	meta, err := e.Ingest(moved)
+	if err != nil || meta == nil {
+		t.Fatalf("settled move dropped: %v", err)
+	}
+	_, full, err := e.ResolveSnapshot("drag", meta.SnapshotID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := full.App.Windows[0].Rect; got != moved.Windows[0].Rect {
+		t.Errorf("rect %+v, want %+v", got, moved.Windows[0].Rect)
+	}
+}
+
+// A title that changes on its own scores nothing unless TitleChanged is
+// weighted, so it does not become a snapshot by default.
+func TestIngestTitleOnlyChange(t *testing.T) {
+	for _, weight := range []float64{0, 1} {
+		p := eagerSignificance()
+		p.TitleChanged = weight
+		e := newTestEngine(t, EngineConfig{Significance: p})
+		start := time.Now()
+		if _, err := e.Ingest(testApp("clock", 0, start)); err != nil {
+			t.Fatal(err)
+		}
+		retitled := testApp("clock", 0, start.Add(time.Minute))
+		retitled.Windows[0].Title = "12:01 - clock"
+		meta, err := e.Ingest(retitled)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := meta != nil; got != (weight > 0) {
+			t.Errorf("TitleChanged %v: snapshot taken %v", weight, got)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package snapshot
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var errSpillClosed = errors.New("spill writer is closed")
+
+// A failed write is retried this many times in all, a little later each
+// time, before the keyframe is given up.
+const spillWriteAttempts = 3
+
+var spillRetryDelay = 50 * time.Millisecond
+
+// spillWriter writes keyframes to disk in the background. Jobs stay in
+// pending until they are on disk so that keyframes can be resolved right
+// after Ingest returns. The queue is bounded: submit blocks while it is full.
+// A keyframe that cannot be written is dropped from pending and its error
+// kept in failed, so reading it reports why it is missing.
+type spillWriter struct {
+	dir   string
+	queue chan spillJob
+
+	mu      sync.Mutex
+	pending map[string][]byte
+	failed  map[string]error
+
+	// ioMu is held while a file is written so discard can wait for it.
+	ioMu sync.Mutex
+
+	sendMu sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+const spillExt = ".json.gz"
+
+type spillJob struct {
+	ref  string
+	data []byte
+}
+
+func newSpillWriter(dir string, depth int) *spillWriter {
+	w := &spillWriter{
+		dir:     dir,
+		queue:   make(chan spillJob, depth),
+		pending: map[string][]byte{},
+		failed:  map[string]error{},
+	}
+	w.wg.Add(1)
+	go w.run()
+	return w
+}
+
+// prepare encodes and compresses fs. It does no I/O.
+func (w *spillWriter) prepare(appID string, fs *FullSnapshot) (spillJob, error) {
+	raw, err := json.Marshal(fs)
+	if err != nil {
+		return spillJob{}, err
+	}
+	sum := sha256.Sum256(raw)
+	name := hex.EncodeToString(sum[:16]) + spillExt
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(raw); err != nil {
+		return spillJob{}, err
+	}
+	if err := zw.Close(); err != nil {
+		return spillJob{}, err
+	}
+	return spillJob{ref: filepath.ToSlash(filepath.Join(appID, name)), data: buf.Bytes()}, nil
+}
+
+// submit queues job for writing. It fails once the writer is closed.
+func (w *spillWriter) submit(job spillJob) error {
+	w.sendMu.RLock()
+	defer w.sendMu.RUnlock()
+	if w.closed {
+		return errSpillClosed
+	}
+	w.mu.Lock()
+	w.pending[job.ref] = job.data
+	delete(w.failed, job.ref)
+	w.mu.Unlock()
+	w.queue <- job
+	return nil
+}
+
+func (w *spillWriter) run() {
+	defer w.wg.Done()
+	for job := range w.queue {
+		w.ioMu.Lock()
+		var err error
+		for attempt := 1; attempt <= spillWriteAttempts; attempt++ {
+			w.mu.Lock()
+			_, live := w.pending[job.ref]
+			w.mu.Unlock()
+			if !live { // discarded
+				err = nil
+				break
+			}
+			if err = w.write(job); err == nil {
+				break
+			}
+			if attempt < spillWriteAttempts {
+				time.Sleep(time.Duration(attempt) * spillRetryDelay)
+			}
+		}
+		w.mu.Lock()
+		if _, live := w.pending[job.ref]; live && err != nil {
+			w.failed[job.ref] = err
+		}
+		delete(w.pending, job.ref)
+		w.mu.Unlock()
+		w.ioMu.Unlock()
+	}
+}
+
+// discard drops queued keyframes whose ref matches, waiting for a write in
+// progress to finish so the caller can safely delete the files afterwards.
+func (w *spillWriter) discard(match func(ref string) bool) {
+	w.ioMu.Lock()
+	defer w.ioMu.Unlock()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for ref := range w.pending {
+		if match(ref) {
+			delete(w.pending, ref)
+		}
+	}
+	for ref := range w.failed {
+		if match(ref) {
+			delete(w.failed, ref)
+		}
+	}
+}
+
+func (w *spillWriter) path(ref string) string {
+	return filepath.Join(w.dir, filepath.FromSlash(ref))
+}
+
+func (w *spillWriter) write(job spillJob) error {
+	path := w.path(job.ref)
+	_ = os.MkdirAll(filepath.Dir(path), 0o755)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, job.data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (w *spillWriter) read(ref string) ([]byte, error) {
+	w.mu.Lock()
+	b, ok := w.pending[ref]
+	failed := w.failed[ref]
+	w.mu.Unlock()
+	if ok {
+		return b, nil
+	}
+	if failed != nil {
+		return nil, fmt.Errorf("keyframe was not written: %w", failed)
+	}
+	return os.ReadFile(w.path(ref))
+}
+
+func (w *spillWriter) load(ref string) (*FullSnapshot, error) {
+	b, err := w.read(ref)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	raw, err := ioReadAllLimit(zr, 10<<20) // 10MB guard
+	if err != nil {
+		return nil, err
+	}
+	var fs FullSnapshot
+	if err := json.Unmarshal(raw, &fs); err != nil {
+		return nil, err
+	}
+	return &fs, nil
+}
+
+// Close flushes queued keyframes and stops the writer. Later submits fail.
+func (w *spillWriter) Close() {
+	w.sendMu.Lock()
+	if w.closed {
+		w.sendMu.Unlock()
+		return
+	}
+	w.closed = true
+	close(w.queue)
+	w.sendMu.Unlock()
+	w.wg.Wait()
+}
+
+func ioReadAllLimit(r *gzip.Reader, limit int64) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(io.LimitReader(r, limit)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
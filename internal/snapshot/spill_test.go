@@ -0,0 +1,70 @@
+package snapshot
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"Rewinder/internal/state"
+)
+
+func TestSpillWriter(t *testing.T) {
+	defer func(d time.Duration) { spillRetryDelay = d }(spillRetryDelay)
+	spillRetryDelay = time.Millisecond
+
+	dir := t.TempDir()
+	// "broken" is a file, so nothing can be written below it.
+	if err := os.WriteFile(filepath.Join(dir, "broken"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w := newSpillWriter(dir, 4)
+	fs := &FullSnapshot{App: state.AppState{AppID: "tool"}}
+	ok, err := w.prepare("tool", fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad, _ := w.prepare("broken", fs)
+	for _, job := range []spillJob{ok, bad} {
+		if err := w.submit(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+
+	if got, err := w.load(ok.ref); err != nil || got.App.AppID != "tool" {
+		t.Fatalf("written keyframe: %v", err)
+	}
+	if _, err := os.Stat(w.path(ok.ref)); err != nil {
+		t.Fatal(err)
+	}
+	// The failed write is given up after its retries, and says so.
+	_, err = w.read(bad.ref)
+	if err == nil || !strings.Contains(err.Error(), "not written") {
+		t.Fatalf("failed keyframe: %v", err)
+	}
+	if len(w.pending) != 0 {
+		t.Fatalf("%d keyframes left in memory", len(w.pending))
+	}
+	w.discard(func(ref string) bool { return strings.HasPrefix(ref, "broken/") })
+	if _, err := w.read(bad.ref); err == nil || strings.Contains(err.Error(), "not written") {
+		t.Fatalf("discarded keyframe: %v", err)
+	}
+
+	if err := w.submit(ok); !errors.Is(err, errSpillClosed) {
+		t.Fatalf("submit after Close: %v", err)
+	}
+}
+
+func TestIngestAfterClose(t *testing.T) {
+	e := newTestEngine(t, EngineConfig{})
+	_ = e.Close()
+	if _, err := e.Ingest(testApp("tool", 0, time.Now())); !errors.Is(err, errSpillClosed) {
+		t.Fatalf("ingest after Close: %v", err)
+	}
+	if n := len(e.GetTimeline("tool")); n != 0 {
+		t.Fatalf("%d snapshots kept", n)
+	}
+}
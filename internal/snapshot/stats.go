@@ -0,0 +1,109 @@
+package snapshot
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"Rewinder/internal/ipcapi"
+)
+
+const (
+	statsRateBucket  = time.Hour
+	statsRateBuckets = 24
+)
+
+// Stats reports per-app storage usage and activity. Disk usage is measured
+// by walking each app's directory after the timeline locks are released.
+func (e *Engine) Stats() ipcapi.StorageStats {
+	now := e.now()
+	rateStart := now.Truncate(statsRateBucket).Add(-(statsRateBuckets - 1) * statsRateBucket)
+
+	out := ipcapi.StorageStats{GeneratedAtUTC: now.UTC().UnixMilli()}
+	for _, tl := range e.timelines() {
+		tl.mu.RLock()
+		st := ipcapi.AppStorageStats{
+			AppID:         tl.appID,
+			Name:          tl.name,
+			SnapshotCount: len(tl.snapshots),
+			RAMBytes:      tl.ramBytes,
+			Rate:          make([]ipcapi.RateBucket, statsRateBuckets),
+		}
+		for i := range st.Rate {
+			st.Rate[i].StartUTC = rateStart.Add(time.Duration(i) * statsRateBucket).UTC().UnixMilli()
+		}
+		depth := make(map[string]int, len(tl.snapshots))
+		chainSum := 0
+		for i, s := range tl.snapshots {
+			d := 1
+			if s.BaseSnapshotID == nil {
+				st.KeyframeCount++
+			} else {
+				d = depth[*s.BaseSnapshotID] + 1
+			}
+			depth[s.SnapshotID] = d
+			chainSum += d
+			ts := s.Timestamp.UTC().UnixMilli()
+			if i == 0 || ts < st.OldestUTC {
+				st.OldestUTC = ts
+			}
+			if ts > st.NewestUTC {
+				st.NewestUTC = ts
+			}
+			if b := int(s.Timestamp.Sub(rateStart) / statsRateBucket); b >= 0 && b < statsRateBuckets {
+				st.Rate[b].Count++
+			}
+		}
+		if len(tl.snapshots) > 0 {
+			st.AvgChainLength = float64(chainSum) / float64(len(tl.snapshots))
+		}
+		tl.mu.RUnlock()
+
+		appDiskUsage(filepath.Join(e.cfg.StorageDir, tl.appID), &st)
+		out.TotalSnapshots += st.SnapshotCount
+		out.TotalRAMBytes += st.RAMBytes
+		out.TotalDiskBytes += st.DiskBytes
+		out.TotalVaultDiskBytes += st.VaultDiskBytes
+		out.TotalLaunchDiskBytes += st.LaunchDiskBytes
+		out.TotalTimelineDiskBytes += st.TimelineDiskBytes
+		out.Apps = append(out.Apps, st)
+	}
+	sort.Slice(out.Apps, func(i, j int) bool {
+		return out.Apps[i].RAMBytes+appDiskBytes(&out.Apps[i]) > out.Apps[j].RAMBytes+appDiskBytes(&out.Apps[j])
+	})
+	return out
+}
+
+func appDiskBytes(st *ipcapi.AppStorageStats) int64 {
+	return st.DiskBytes + st.VaultDiskBytes + st.LaunchDiskBytes + st.TimelineDiskBytes
+}
+
+// appDiskUsage sorts the files under an app's directory: the vaults by
+// their subdirectory, spilled snapshots by name, and everything else,
+// the timeline and files left half-written, as timeline.
+func appDiskUsage(dir string, st *ipcapi.AppStorageStats) {
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		size := info.Size()
+		rel, _ := filepath.Rel(dir, path)
+		switch sub, _, nested := strings.Cut(filepath.ToSlash(rel), "/"); {
+		case nested && sub == clipVaultSub:
+			st.VaultDiskBytes += size
+		case nested && sub == launchVaultSub:
+			st.LaunchDiskBytes += size
+		case !nested && rel != timelineFile && strings.HasSuffix(rel, spillExt):
+			st.DiskBytes += size
+		default:
+			st.TimelineDiskBytes += size
+		}
+		return nil
+	})
+}
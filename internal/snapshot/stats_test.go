@@ -0,0 +1,72 @@
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func filesSize(t *testing.T, pattern string) int64 {
+	t.Helper()
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var total int64
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += info.Size()
+	}
+	if total == 0 {
+		t.Fatalf("nothing on disk at %s", pattern)
+	}
+	return total
+}
+
+// Disk usage is split into spilled snapshots, the two vaults and the
+// timeline.
+func TestStatsDiskBreakdown(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC)
+	e := newTestEngine(t, EngineConfig{StorageDir: dir, ClipboardVault: true, Now: func() time.Time { return now }})
+	for i := 0; i < 3; i++ {
+		app := testApp("tool", i, now.Add(time.Duration(i-3)*time.Minute))
+		app.ClipboardText = strings.Repeat("clip", i+1)
+		app.ClipboardHash = strings.Repeat("c", i+1)
+		app.RawCommandLine = "tool --token secret"
+		if _, err := e.Ingest(app); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = e.Close()
+
+	appDir := filepath.Join(dir, "tool")
+	snaps := filesSize(t, filepath.Join(appDir, "*"+spillExt)) - filesSize(t, filepath.Join(appDir, timelineFile))
+	vault := filesSize(t, filepath.Join(appDir, clipVaultSub, "*"))
+	launch := filesSize(t, filepath.Join(appDir, launchVaultSub, "*"))
+	timeline := filesSize(t, filepath.Join(appDir, timelineFile))
+
+	st := e.Stats()
+	if len(st.Apps) != 1 {
+		t.Fatalf("%d apps", len(st.Apps))
+	}
+	a := st.Apps[0]
+	if a.DiskBytes != snaps || a.VaultDiskBytes != vault || a.LaunchDiskBytes != launch || a.TimelineDiskBytes != timeline {
+		t.Errorf("disk %d/%d/%d/%d, want %d/%d/%d/%d", a.DiskBytes, a.VaultDiskBytes, a.LaunchDiskBytes, a.TimelineDiskBytes,
+			snaps, vault, launch, timeline)
+	}
+	if st.TotalDiskBytes != snaps || st.TotalVaultDiskBytes != vault || st.TotalLaunchDiskBytes != launch || st.TotalTimelineDiskBytes != timeline {
+		t.Errorf("totals %+v", st)
+	}
+	if a.SnapshotCount != 3 || a.KeyframeCount < 1 || a.Rate[len(a.Rate)-1].Count != 3 {
+		t.Errorf("stats %+v", a)
+	}
+	if a.OldestUTC != now.Add(-3*time.Minute).UnixMilli() || a.NewestUTC != now.Add(-time.Minute).UnixMilli() {
+		t.Errorf("oldest %d, newest %d", a.OldestUTC, a.NewestUTC)
+	}
+}
@@ -0,0 +1,160 @@
+package snapshot
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// secretVault keeps text that must not be stored in the clear encrypted on
+// disk, addressed by a hash recorded in snapshots: clipboard text under
+// <appID>/clip and unredacted command lines under <appID>/launch, so they go
+// away together with the app's other spill files.
+type secretVault struct {
+	dir  string
+	sub  string
+	key  []byte
+	aead cipher.AEAD
+	mu   sync.Mutex
+}
+
+const (
+	vaultKeyFile   = "vault.key"
+	clipVaultSub   = "clip"
+	launchVaultSub = "launch"
+)
+
+// errNoKeyStore means the platform offers nothing that keeps the vault key
+// apart from the data it protects. The vaults are not opened then, so the
+// text is not stored at all.
+var errNoKeyStore = errors.New("no key store for the vault key")
+
+// The platform key store, replaced in tests.
+var (
+	protectKey   = protectVaultKey
+	unprotectKey = unprotectVaultKey
+)
+
+// openSecretVault opens the vault in dir/<appID>/sub. readOnly fails
+// instead of creating a key that is missing.
+func openSecretVault(dir, sub string, readOnly bool) (*secretVault, error) {
+	key, err := loadVaultKey(filepath.Join(dir, vaultKeyFile), !readOnly)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &secretVault{dir: dir, sub: sub, key: key, aead: aead}, nil
+}
+
+func loadVaultKey(path string, create bool) ([]byte, error) {
+	if b, err := os.ReadFile(path); err == nil {
+		key, err := unprotectKey(path, b)
+		if err != nil {
+			return nil, err
+		}
+		if len(key) != 32 {
+			return nil, errors.New("vault key has wrong size")
+		}
+		return key, nil
+	} else if !os.IsNotExist(err) || !create {
+		return nil, err
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	sealed, err := protectKey(path, key)
+	if err != nil {
+		return nil, err
+	}
+	_ = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err := os.WriteFile(path, sealed, 0o600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (v *secretVault) path(appID, hash string) string {
+	return filepath.Join(v.dir, appID, v.sub, hash+".bin")
+}
+
+// ref derives the address of text from the vault key, so it says nothing
+// about the text to whoever can read the snapshots but not the vault.
+func (v *secretVault) ref(text string) string {
+	mac := hmac.New(sha256.New, v.key)
+	mac.Write([]byte(text))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+func (v *secretVault) Put(appID, hash, text string) error {
+	if hash == "" || text == "" {
+		return nil
+	}
+	path := v.path(appID, hash)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	nonce := make([]byte, v.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	sealed := v.aead.Seal(nonce, nonce, []byte(text), []byte(hash))
+	_ = os.MkdirAll(filepath.Dir(path), 0o755)
+	return os.WriteFile(path, sealed, 0o600)
+}
+
+func (v *secretVault) Get(appID, hash string) (string, bool) {
+	if hash == "" {
+		return "", false
+	}
+	b, err := os.ReadFile(v.path(appID, hash))
+	if err != nil {
+		return "", false
+	}
+	ns := v.aead.NonceSize()
+	if len(b) < ns {
+		return "", false
+	}
+	raw, err := v.aead.Open(nil, b[:ns], b[ns:], []byte(hash))
+	if err != nil {
+		return "", false
+	}
+	return string(raw), true
+}
+
+// prune securely removes the entries of appID whose hash is not in keep.
+func (v *secretVault) prune(appID string, keep map[string]bool) error {
+	entries, err := os.ReadDir(filepath.Join(v.dir, appID, v.sub))
+	if err != nil {
+		return nil
+	}
+	var firstErr error
+	for _, ent := range entries {
+		hash, ok := strings.CutSuffix(ent.Name(), ".bin")
+		if !ok || keep[hash] {
+			continue
+		}
+		if err := secureRemove(v.path(appID, hash)); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,86 @@
+//go:build linux
+
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The vault key is kept in the desktop keyring through the Secret Service,
+// using secret-tool from libsecret. The key file only says so, and the
+// keyring entry is looked up by the key file's path, so copying the storage
+// dir does not copy the key.
+const keyringMarker = "secret-service v1\n"
+
+func keyringAttrs(path string) []string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	return []string{"application", "rewinder", "vault", abs}
+}
+
+func secretTool(stdin string, args ...string) ([]byte, error) {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return nil, errNoKeyStore
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "secret-tool", args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		// Usually no keyring daemon is running or the keyring is locked.
+		return nil, fmt.Errorf("%w: secret-tool %s: %v %s", errNoKeyStore, args[0], err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return out, nil
+}
+
+func protectVaultKey(path string, key []byte) ([]byte, error) {
+	args := append([]string{"store", "--label=Rewinder vault key"}, keyringAttrs(path)...)
+	if _, err := secretTool(hex.EncodeToString(key), args...); err != nil {
+		return nil, err
+	}
+	return []byte(keyringMarker), nil
+}
+
+func unprotectVaultKey(path string, sealed []byte) ([]byte, error) {
+	if string(sealed) != keyringMarker {
+		if len(sealed) != 32 {
+			return nil, errors.New("unknown vault key format")
+		}
+		// Earlier versions kept the key in the clear; move it into the
+		// keyring and wipe the file.
+		marker, err := protectVaultKey(path, sealed)
+		if err != nil {
+			return nil, err
+		}
+		if err := secureRemove(path); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, marker, 0o600); err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), sealed...), nil
+	}
+	out, err := secretTool("", append([]string{"lookup"}, keyringAttrs(path)...)...)
+	if err != nil {
+		return nil, err
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(out)))
+	if err != nil {
+		return nil, fmt.Errorf("vault key in the keyring: %w", err)
+	}
+	return key, nil
+}
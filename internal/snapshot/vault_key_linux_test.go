@@ -0,0 +1,89 @@
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeSecretTool puts a secret-tool on PATH that keeps one secret in a file
+// and logs its arguments.
+func fakeSecretTool(t *testing.T) (store, log string) {
+	t.Helper()
+	bin := t.TempDir()
+	store, log = filepath.Join(bin, "secret"), filepath.Join(bin, "args")
+	script := `#!/bin/sh
+echo "$@" >> "` + log + `"
+case "$1" in
+store) cat > "` + store + `" ;;
+lookup) cat "` + store + `" 2>/dev/null || exit 1 ;;
+*) exit 2 ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(bin, "secret-tool"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return store, log
+}
+
+func TestKeyringVaultKey(t *testing.T) {
+	store, log := fakeSecretTool(t)
+	path := filepath.Join(t.TempDir(), vaultKeyFile)
+	key := bytes.Repeat([]byte{0xab}, 32)
+
+	sealed, err := protectVaultKey(path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, key) || string(sealed) != keyringMarker {
+		t.Fatalf("key file content %q", sealed)
+	}
+	if b, _ := os.ReadFile(store); !strings.HasPrefix(string(b), "abab") {
+		t.Fatalf("keyring holds %q", b)
+	}
+	got, err := unprotectVaultKey(path, sealed)
+	if err != nil || !bytes.Equal(got, key) {
+		t.Fatalf("unprotect = %x, %v", got, err)
+	}
+	if b, _ := os.ReadFile(log); !strings.Contains(string(b), "lookup application rewinder vault "+path) {
+		t.Fatalf("secret-tool calls:\n%s", b)
+	}
+
+	// A key kept in the clear by an earlier version moves to the keyring.
+	_ = os.Remove(store)
+	if err := os.WriteFile(path, key, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got, err = unprotectVaultKey(path, key)
+	if err != nil || !bytes.Equal(got, key) {
+		t.Fatalf("legacy key = %x, %v", got, err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != keyringMarker {
+		t.Fatalf("key file after migration %q", b)
+	}
+	if got, err := unprotectVaultKey(path, []byte(keyringMarker)); err != nil || !bytes.Equal(got, key) {
+		t.Fatalf("after migration = %x, %v", got, err)
+	}
+}
+
+func TestKeyringUnavailable(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	path := filepath.Join(t.TempDir(), vaultKeyFile)
+	if _, err := protectVaultKey(path, make([]byte, 32)); !errors.Is(err, errNoKeyStore) {
+		t.Fatalf("protect without secret-tool: %v", err)
+	}
+
+	// secret-tool is there but the keyring is not.
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "secret-tool"), []byte("#!/bin/sh\necho 'Cannot autolaunch D-Bus' >&2\nexit 1\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+	if _, err := unprotectVaultKey(path, []byte(keyringMarker)); !errors.Is(err, errNoKeyStore) {
+		t.Fatalf("lookup without a keyring: %v", err)
+	}
+}
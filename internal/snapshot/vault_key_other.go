@@ -0,0 +1,12 @@
+//go:build !windows && !linux
+
+package snapshot
+
+// No key store is supported here, so the vaults stay closed.
+func protectVaultKey(path string, key []byte) ([]byte, error) {
+	return nil, errNoKeyStore
+}
+
+func unprotectVaultKey(path string, sealed []byte) ([]byte, error) {
+	return nil, errNoKeyStore
+}
@@ -0,0 +1,135 @@
+package snapshot
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Tests keep vault keys in memory, never in the user's keyring.
+var testKeys sync.Map
+
+func init() {
+	protectKey = func(path string, key []byte) ([]byte, error) {
+		testKeys.Store(path, append([]byte(nil), key...))
+		return []byte("test keyring\n"), nil
+	}
+	unprotectKey = func(path string, sealed []byte) ([]byte, error) {
+		key, ok := testKeys.Load(path)
+		if !ok {
+			return nil, errNoKeyStore
+		}
+		return key.([]byte), nil
+	}
+}
+
+// containsOnDisk reports the files under dir that hold text, also inside
+// gzip streams.
+func containsOnDisk(dir, text string) []string {
+	var found []string
+	_ = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		b, _ := os.ReadFile(path)
+		if zr, err := gzip.NewReader(bytes.NewReader(b)); err == nil {
+			if raw, err := io.ReadAll(zr); err == nil {
+				b = raw
+			}
+		}
+		if strings.Contains(string(b), text) {
+			found = append(found, path)
+		}
+		return nil
+	})
+	return found
+}
+
+// The command line as captured before redaction is only stored encrypted,
+// and a resolved snapshot carries it for the restore engine.
+func TestLaunchVault(t *testing.T) {
+	const secret = "586xwjj1fIyhIxxGoTCjA4qLneL_ydbPryc7EUbakgo"
+	dir := t.TempDir()
+	e := newTestEngine(t, EngineConfig{StorageDir: dir})
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < 3; i++ {
+		app := testApp("tool", i, start.Add(time.Duration(i)*time.Second))
+		app.CommandLine = "tool --token [secret]"
+		app.RawCommandLine = "tool --token " + secret
+		if _, err := e.Ingest(app); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(e *Engine) {
+		t.Helper()
+		for _, m := range e.GetTimeline("tool") {
+			_, full, err := e.ResolveSnapshot("tool", m.SnapshotID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if full.App.CommandLine != "tool --token [secret]" || full.App.RawCommandLine != "tool --token "+secret {
+				t.Fatalf("resolved %q / %q", full.App.CommandLine, full.App.RawCommandLine)
+			}
+		}
+	}
+	check(e)
+	_ = e.Close()
+
+	// Nothing on disk holds the secret in the clear.
+	for _, path := range containsOnDisk(dir, secret) {
+		t.Errorf("%s contains the unredacted command line", path)
+	}
+
+	e = newTestEngine(t, EngineConfig{StorageDir: dir})
+	check(e)
+
+	// Purging every snapshot drops the vault entry with them.
+	if _, err := e.Purge("tool", start.Add(-time.Minute), start.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, "tool", "launch")); len(entries) != 0 {
+		t.Errorf("%d launch entries left after purge", len(entries))
+	}
+}
+
+// Without a key store the vaults stay closed: clipboard text and unredacted
+// command lines are dropped rather than stored next to their key.
+func TestVaultWithoutKeyStore(t *testing.T) {
+	protect, unprotect := protectKey, unprotectKey
+	protectKey = func(string, []byte) ([]byte, error) { return nil, errNoKeyStore }
+	unprotectKey = func(string, []byte) ([]byte, error) { return nil, errNoKeyStore }
+	t.Cleanup(func() { protectKey, unprotectKey = protect, unprotect })
+
+	const secret = "586xwjj1fIyhIxxGoTCjA4qLneL_ydbPryc7EUbakgo"
+	dir := t.TempDir()
+	e := newTestEngine(t, EngineConfig{StorageDir: dir, ClipboardVault: true})
+	app := testApp("tool", 0, time.Now())
+	app.ClipboardText = "copied " + secret
+	app.ClipboardHash = "abc123"
+	app.CommandLine = "tool --token [secret]"
+	app.RawCommandLine = "tool --token " + secret
+	meta, err := e.Ingest(app)
+	if err != nil || meta == nil {
+		t.Fatalf("ingest: %v, %v", meta, err)
+	}
+	_, full, err := e.ResolveSnapshot("tool", meta.SnapshotID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if full.App.ClipboardText != "" || full.App.RawCommandLine != "" {
+		t.Errorf("resolved %q / %q", full.App.ClipboardText, full.App.RawCommandLine)
+	}
+	_ = e.Close()
+	for _, path := range containsOnDisk(dir, secret) {
+		t.Errorf("%s contains the secret", path)
+	}
+	if _, err := os.Stat(filepath.Join(dir, vaultKeyFile)); !os.IsNotExist(err) {
+		t.Errorf("key file written without a key store: %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// secureRemove overwrites a file with zeros before unlinking it.
+func secureRemove(path string) error {
+	if f, err := os.OpenFile(path, os.O_WRONLY, 0); err == nil {
+		if info, err := f.Stat(); err == nil {
+			zero := make([]byte, 32*1024)
+			for left := info.Size(); left > 0; {
+				n := int64(len(zero))
+				if left < n {
+					n = left
+				}
+				if _, err := f.Write(zero[:n]); err != nil {
+					break
+				}
+				left -= n
+			}
+			_ = f.Sync()
+		}
+		_ = f.Close()
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func secureRemoveAll(dir string) error {
+	var firstErr error
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if err := secureRemove(path); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		return nil
+	})
+	if err := os.RemoveAll(dir); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
+
+type auditEntry struct {
+	AtUTC   int64  `json:"atUTC"`
+	Action  string `json:"action"`
+	AppID   string `json:"appID"`
+	FromUTC int64  `json:"fromUTC,omitempty"`
+	ToUTC   int64  `json:"toUTC,omitempty"`
+	Removed int    `json:"removed"`
+}
+
+const auditLogFile = "audit.log"
+
+var auditMu sync.Mutex
+
+// appendAudit records a deletion in the append-only audit log. It stores no
+// snapshot content, only what was deleted and when.
+func (e *Engine) appendAudit(entry auditEntry) error {
+	entry.AtUTC = e.now().UTC().UnixMilli()
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	f, err := os.OpenFile(filepath.Join(e.cfg.StorageDir, auditLogFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
@@ -0,0 +1,26 @@
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+func stableAppID(exePath string) string {
+	if exePath == "" {
+		return "unknown"
+	}
+	base := strings.ToLower(filepath.Base(exePath))
+	sum := sha256.Sum256([]byte(strings.ToLower(exePath)))
+	return fmt.Sprintf("%s:%s", base, hex.EncodeToString(sum[:8]))
+}
+
+func hashClipboardText(s string) string {
+	if s == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:16])
+}
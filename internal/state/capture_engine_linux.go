@@ -0,0 +1,268 @@
+package state
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrNoForegroundApp is returned by CaptureForeground when neither the
+// window provider nor /proc point at a foreground app.
+var ErrNoForegroundApp = errors.New("no foreground app")
+
+// sessionEnv are the variables kept from a process environment: what a
+// relaunch needs to land on the same desktop and locale.
+var sessionEnv = []string{
+	"DISPLAY", "WAYLAND_DISPLAY", "XDG_RUNTIME_DIR", "XDG_SESSION_TYPE",
+	"XDG_CURRENT_DESKTOP", "DBUS_SESSION_BUS_ADDRESS", "LANG",
+}
+
+// Как и в Windows-версии, ограничиваем число файлов на процесс
+const maxOpenFiles = 50
+
+// CaptureEngine builds app states from procfs. Window fields come from the
+// window provider, if any; NewCaptureEngine sets up the X11 one when the
+// session has a display and the X tools it needs are installed.
+type CaptureEngine struct {
+	root string
+
+	mu      sync.RWMutex
+	windows WindowProvider
+}
+
+func NewCaptureEngine() *CaptureEngine {
+	c := &CaptureEngine{root: "/proc"}
+	if p, ok := newX11Provider(); ok {
+		c.windows = p
+	}
+	return c
+}
+
+func (c *CaptureEngine) SetWindowProvider(p WindowProvider) {
+	c.mu.Lock()
+	c.windows = p
+	c.mu.Unlock()
+}
+
+func (c *CaptureEngine) provider() WindowProvider {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.windows
+}
+
+// CaptureForeground captures the app that owns the focused window. Without
+// a window provider, or when it sees no focused window, the app is guessed
+// from /proc, see guessForeground.
+func (c *CaptureEngine) CaptureForeground() (*AppState, error) {
+	if p := c.provider(); p != nil {
+		pid, fg, err := p.Foreground()
+		switch {
+		case err == nil && pid > 0:
+			st, err := c.CapturePID(pid)
+			if err != nil {
+				return nil, err
+			}
+			st.ForegroundWindowClass = fg.ClassName
+			return st, nil
+		case err == nil:
+			return nil, errors.New("no pid")
+		case !errors.Is(err, ErrNoForegroundWindow):
+			return nil, err
+		}
+	}
+	pid, err := c.guessForeground()
+	if err != nil {
+		return nil, err
+	}
+	return c.CapturePID(pid)
+}
+
+// guessForeground picks the most recently started process of our user that
+// a user works with: one in a desktop session (DISPLAY or WAYLAND_DISPLAY
+// set) or in the foreground process group of its terminal. New apps are
+// what the process events report, so the newest one is the best guess.
+func (c *CaptureEngine) guessForeground() (int, error) {
+	entries, err := os.ReadDir(c.root)
+	if err != nil {
+		return 0, err
+	}
+	self, uid := os.Getpid(), uint32(os.Getuid())
+	best := 0
+	var bestStart uint64
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || pid <= 0 || pid == self {
+			continue
+		}
+		dir := filepath.Join(c.root, e.Name())
+		if fi, err := os.Stat(dir); err != nil {
+			continue
+		} else if st, ok := fi.Sys().(*syscall.Stat_t); !ok || st.Uid != uid {
+			continue
+		}
+		ps, ok := readProcStat(filepath.Join(dir, "stat"))
+		if !ok || ps.start < bestStart || (ps.start == bestStart && pid < best) {
+			continue
+		}
+		if !(ps.tty != 0 && ps.pgrp == ps.tpgid) {
+			env := readSessionEnv(filepath.Join(dir, "environ"))
+			if env["DISPLAY"] == "" && env["WAYLAND_DISPLAY"] == "" {
+				continue
+			}
+		}
+		best, bestStart = pid, ps.start
+	}
+	if best == 0 {
+		return 0, ErrNoForegroundApp
+	}
+	return best, nil
+}
+
+type procStat struct {
+	pgrp, tty, tpgid int
+	start            uint64 // clock ticks after boot
+}
+
+// readProcStat parses the fields guessForeground needs from
+// /proc/<pid>/stat.
+func readProcStat(path string) (procStat, bool) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return procStat{}, false
+	}
+	// comm is in parentheses and may itself contain spaces and ")".
+	i := bytes.LastIndexByte(b, ')')
+	if i < 0 {
+		return procStat{}, false
+	}
+	// Fields after comm start at field 3 (state): pgrp is field 5, tty_nr
+	// 7, tpgid 8 and starttime 22.
+	f := strings.Fields(string(b[i+1:]))
+	if len(f) < 20 {
+		return procStat{}, false
+	}
+	var ps procStat
+	var errs [4]error
+	ps.pgrp, errs[0] = strconv.Atoi(f[2])
+	ps.tty, errs[1] = strconv.Atoi(f[4])
+	ps.tpgid, errs[2] = strconv.Atoi(f[5])
+	ps.start, errs[3] = strconv.ParseUint(f[19], 10, 64)
+	for _, err := range errs {
+		if err != nil {
+			return procStat{}, false
+		}
+	}
+	return ps, true
+}
+
+// CapturePID captures the process pid. It fails if the executable cannot be
+// read, e.g. for another user's process when not running as root.
+func (c *CaptureEngine) CapturePID(pid int) (*AppState, error) {
+	dir := filepath.Join(c.root, strconv.Itoa(pid))
+	exe, err := os.Readlink(filepath.Join(dir, "exe"))
+	if err != nil {
+		return nil, fmt.Errorf("process %d: %w", pid, err)
+	}
+	// Бинарник могли заменить при обновлении пакета
+	exe = strings.TrimSuffix(exe, " (deleted)")
+
+	var cmd string
+	if b, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil && len(b) > 0 {
+		cmd = JoinArgs(strings.Split(strings.TrimSuffix(string(b), "\x00"), "\x00"))
+	}
+	wd, _ := os.Readlink(filepath.Join(dir, "cwd"))
+
+	st := &AppState{
+		AppID:          stableAppID(exe),
+		PID:            pid,
+		ExecutablePath: exe,
+		CommandLine:    cmd,
+		WorkingDir:     wd,
+		Environment:    readSessionEnv(filepath.Join(dir, "environ")),
+		OpenFiles:      enumerateOpenFiles(filepath.Join(dir, "fd")),
+		Timestamp:      time.Now(),
+	}
+	if p := c.provider(); p != nil {
+		st.Windows, _ = p.Windows(pid)
+	}
+	return st, nil
+}
+
+// OpenFiles lists the regular files pid has open, as a capture would.
+func OpenFiles(pid int) []FileRef {
+	return enumerateOpenFiles(filepath.Join("/proc", strconv.Itoa(pid), "fd"))
+}
+
+func readSessionEnv(path string) map[string]string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var out map[string]string
+	for _, kv := range bytes.Split(b, []byte{0}) {
+		k, v, ok := strings.Cut(string(kv), "=")
+		if !ok {
+			continue
+		}
+		for _, want := range sessionEnv {
+			if k == want {
+				if out == nil {
+					out = map[string]string{}
+				}
+				out[k] = v
+			}
+		}
+	}
+	return out
+}
+
+// enumerateOpenFiles lists the regular files behind the descriptors in fdDir.
+// Sockets, pipes, anonymous inodes and anything under /dev, /proc, /sys or
+// the temp dir are skipped.
+func enumerateOpenFiles(fdDir string) []FileRef {
+	entries, err := os.ReadDir(fdDir)
+	if err != nil {
+		return nil
+	}
+	tmp := filepath.Clean(os.TempDir()) + "/"
+	seen := map[string]struct{}{}
+	for _, e := range entries {
+		if len(seen) >= maxOpenFiles {
+			break
+		}
+		fd := filepath.Join(fdDir, e.Name())
+		target, err := os.Readlink(fd)
+		// socket:[…], pipe:[…] и anon_inode:… не пути
+		if err != nil || !filepath.IsAbs(target) {
+			continue
+		}
+		if strings.HasPrefix(target, "/dev/") || strings.HasPrefix(target, "/proc/") ||
+			strings.HasPrefix(target, "/sys/") || strings.HasPrefix(target, tmp) {
+			continue
+		}
+		if strings.HasSuffix(target, " (deleted)") {
+			continue
+		}
+		// Stat through the descriptor: the path may be gone or replaced.
+		fi, err := os.Stat(fd)
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		seen[filepath.Clean(target)] = struct{}{}
+	}
+
+	res := make([]FileRef, 0, len(seen))
+	for p := range seen {
+		res = append(res, FileRef{Path: p})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Path < res[j].Path })
+	return res
+}
@@ -0,0 +1,241 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startSleep starts a desktop-looking child that lives for the test.
+func startSleep(t *testing.T) *exec.Cmd {
+	t.Helper()
+	cmd := exec.Command("sleep", "30")
+	cmd.Env = append(os.Environ(), "DISPLAY=:99")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("sleep: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+	return cmd
+}
+
+func TestCaptureForegroundWithoutProvider(t *testing.T) {
+	cmd := startSleep(t)
+	c := &CaptureEngine{root: "/proc"}
+	st, err := c.CaptureForeground()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.PID != cmd.Process.Pid {
+		t.Fatalf("pid = %d (%s), want the newest desktop process %d", st.PID, st.ExecutablePath, cmd.Process.Pid)
+	}
+	if !strings.HasSuffix(st.ExecutablePath, "/sleep") || st.CommandLine != "sleep 30" {
+		t.Fatalf("exe %q, cmdline %q", st.ExecutablePath, st.CommandLine)
+	}
+	if st.Environment["DISPLAY"] != ":99" {
+		t.Fatalf("env = %v", st.Environment)
+	}
+	if len(st.Windows) != 0 {
+		t.Fatalf("windows without a provider: %+v", st.Windows)
+	}
+}
+
+type fakeProvider struct {
+	pid int
+	err error
+}
+
+func (f fakeProvider) Foreground() (int, WindowState, error) {
+	if f.err != nil {
+		return 0, WindowState{}, f.err
+	}
+	return f.pid, WindowState{HWND: 7, ClassName: "Sleeper", IsForeground: true}, nil
+}
+
+func (f fakeProvider) Windows(pid int) ([]WindowState, error) {
+	if pid != f.pid {
+		return nil, nil
+	}
+	return []WindowState{{HWND: 7, ClassName: "Sleeper", Title: "zzz", IsForeground: true}}, nil
+}
+
+func TestCaptureForegroundWithProvider(t *testing.T) {
+	cmd := startSleep(t)
+	c := &CaptureEngine{root: "/proc"}
+	c.SetWindowProvider(fakeProvider{pid: cmd.Process.Pid})
+	st, err := c.CaptureForeground()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.PID != cmd.Process.Pid || st.ForegroundWindowClass != "Sleeper" {
+		t.Fatalf("pid %d, class %q", st.PID, st.ForegroundWindowClass)
+	}
+	if len(st.Windows) != 1 || st.Windows[0].Title != "zzz" {
+		t.Fatalf("windows = %+v", st.Windows)
+	}
+
+	// No focused window: fall back to the process data.
+	c.SetWindowProvider(fakeProvider{err: ErrNoForegroundWindow})
+	if st, err := c.CaptureForeground(); err != nil || st.PID != cmd.Process.Pid {
+		t.Fatalf("fallback: %v, %+v", err, st)
+	}
+
+	// Other provider errors are reported.
+	broken := errors.New("x server gone")
+	c.SetWindowProvider(fakeProvider{err: broken})
+	if _, err := c.CaptureForeground(); !errors.Is(err, broken) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestGuessForegroundFakeProc(t *testing.T) {
+	root := t.TempDir()
+	// pid, start time, terminal foreground, environ
+	procs := []struct {
+		pid   int
+		start int
+		fgTTY bool
+		env   string
+	}{
+		{pid: 10, start: 500, env: "DISPLAY=:0\x00"},
+		{pid: 11, start: 900, env: "HOME=/root\x00"},              // a daemon
+		{pid: 12, start: 700, fgTTY: true, env: "HOME=/root\x00"}, // vim in a terminal
+		{pid: 13, start: 800, env: "WAYLAND_DISPLAY=wayland-0\x00"},
+	}
+	for _, p := range procs {
+		dir := fmt.Sprintf("%s/%d", root, p.pid)
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		pgrp, tty, tpgid := p.pid, 0, -1
+		if p.fgTTY {
+			tty, tpgid = 34816, p.pid
+		}
+		stat := fmt.Sprintf("%d (a) b) S 1 %d %d %d %d 0 0 0 0 0 0 0 0 0 20 0 1 0 %d 0 0\n",
+			p.pid, pgrp, pgrp, tty, tpgid, p.start)
+		if err := os.WriteFile(dir+"/stat", []byte(stat), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(dir+"/environ", []byte(p.env), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := &CaptureEngine{root: root}
+	if pid, err := c.guessForeground(); err != nil || pid != 13 {
+		t.Fatalf("pid = %d, %v; want 13", pid, err)
+	}
+	_ = os.RemoveAll(root + "/13")
+	if pid, err := c.guessForeground(); err != nil || pid != 12 {
+		t.Fatalf("pid = %d, %v; want 12", pid, err)
+	}
+	_ = os.RemoveAll(root + "/12")
+	_ = os.RemoveAll(root + "/10")
+	if _, err := c.guessForeground(); !errors.Is(err, ErrNoForegroundApp) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestX11Provider(t *testing.T) {
+	out := map[string]string{
+		"xprop -root _NET_ACTIVE_WINDOW":        "_NET_ACTIVE_WINDOW(WINDOW): window id # 0x3a00007\n",
+		"xprop -root _NET_CLIENT_LIST_STACKING": "_NET_CLIENT_LIST_STACKING(WINDOW): window id # 0x3a00007, 0x2c00003, 0x3a00012\n",
+		"xprop -id 0x3a00007 _NET_WM_PID WM_CLASS _NET_WM_NAME WM_NAME _NET_WM_STATE _NET_WM_DESKTOP": `_NET_WM_PID(CARDINAL) = 4242
+WM_CLASS(STRING) = "gedit", "Gedit"
+_NET_WM_NAME(UTF8_STRING) = "notes \"draft\".txt - gedit"
+WM_NAME(STRING) = "notes.txt - gedit"
+_NET_WM_STATE(ATOM) = _NET_WM_STATE_MAXIMIZED_VERT, _NET_WM_STATE_MAXIMIZED_HORZ
+_NET_WM_DESKTOP(CARDINAL) = 1
+`,
+		"xprop -id 0x2c00003 _NET_WM_PID": "_NET_WM_PID(CARDINAL) = 99\n",
+		"xprop -id 0x3a00012 _NET_WM_PID": "_NET_WM_PID(CARDINAL) = 4242\n",
+		"xprop -id 0x3a00012 _NET_WM_PID WM_CLASS _NET_WM_NAME WM_NAME _NET_WM_STATE _NET_WM_DESKTOP": `_NET_WM_PID(CARDINAL) = 4242
+WM_CLASS(STRING) = "gedit", "Gedit"
+_NET_WM_NAME:  not found.
+WM_NAME(STRING) = "Preferences"
+_NET_WM_STATE(ATOM) = _NET_WM_STATE_HIDDEN
+`,
+		"xwininfo -id 0x3a00007": "  Absolute upper-left X:  0\n  Absolute upper-left Y:  27\n  Width: 1920\n  Height: 1053\n",
+		"xwininfo -id 0x3a00012": "  Absolute upper-left X:  300\n  Absolute upper-left Y:  200\n  Width: 640\n  Height: 480\n",
+	}
+	now := time.Unix(1700000000, 0)
+	calls := map[string]int{}
+	p := &x11Provider{now: func() time.Time { return now }, run: func(name string, args ...string) ([]byte, error) {
+		key := strings.Join(append([]string{name}, args...), " ")
+		calls[key]++
+		if s, ok := out[key]; ok {
+			return []byte(s), nil
+		}
+		return nil, fmt.Errorf("unexpected %q", key)
+	}}
+
+	pid, fg, err := p.Foreground()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := WindowState{
+		HWND: 0x3a00007, Rect: Rect{Left: 0, Top: 27, Right: 1920, Bottom: 1080},
+		IsForeground: true, IsMaximized: true, VirtualDesktop: "1",
+		ClassName: "Gedit", Title: `notes "draft".txt - gedit`,
+	}
+	if pid != 4242 || fg != want {
+		t.Fatalf("foreground = %d %+v", pid, fg)
+	}
+
+	ws, err := p.Windows(4242)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ws) != 2 {
+		t.Fatalf("windows = %+v", ws)
+	}
+	// Top of the stack first.
+	if w := ws[0]; w.HWND != 0x3a00012 || w.ZOrder != 0 || !w.IsMinimized || w.IsForeground || w.Title != "Preferences" ||
+		w.Rect != (Rect{Left: 300, Top: 200, Right: 940, Bottom: 680}) {
+		t.Fatalf("top window = %+v", w)
+	}
+	if w := ws[1]; w.HWND != 0x3a00007 || w.ZOrder != 2 || !w.IsForeground {
+		t.Fatalf("bottom window = %+v", w)
+	}
+	// The active window comes from Foreground and xterm's properties are
+	// never fetched.
+	if calls["xprop -root _NET_ACTIVE_WINDOW"] != 1 {
+		t.Fatalf("active window asked %d times", calls["xprop -root _NET_ACTIVE_WINDOW"])
+	}
+	for key, n := range calls {
+		if strings.HasSuffix(key, " _NET_WM_PID") && n > 1 || strings.Contains(key, "0x2c00003 _NET_WM_PID WM_CLASS") {
+			t.Fatalf("%q run %d times", key, n)
+		}
+	}
+
+	// PIDs are remembered; a stale active window is asked again.
+	now = now.Add(2 * x11ActiveTTL)
+	if _, err := p.Windows(4242); err != nil {
+		t.Fatal(err)
+	}
+	if calls["xprop -id 0x2c00003 _NET_WM_PID"] != 1 || calls["xprop -id 0x3a00012 _NET_WM_PID"] != 1 {
+		t.Fatalf("pid lookups = %v", calls)
+	}
+	if calls["xprop -root _NET_ACTIVE_WINDOW"] != 2 {
+		t.Fatalf("active window asked %d times", calls["xprop -root _NET_ACTIVE_WINDOW"])
+	}
+
+	// A closed window is forgotten.
+	out["xprop -root _NET_CLIENT_LIST_STACKING"] = "_NET_CLIENT_LIST_STACKING(WINDOW): window id # 0x3a00007\n"
+	if _, err := p.Windows(4242); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.pids[0x2c00003]; ok {
+		t.Fatalf("pids = %v", p.pids)
+	}
+
+	out["xprop -root _NET_ACTIVE_WINDOW"] = "_NET_ACTIVE_WINDOW(WINDOW): window id # 0x0\n"
+	if _, _, err := p.Foreground(); !errors.Is(err, ErrNoForegroundWindow) {
+		t.Fatalf("err = %v", err)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !windows && !linux
+
+package state
+
+import "errors"
+
+type CaptureEngine struct{}
+
+func NewCaptureEngine() *CaptureEngine {
+	return &CaptureEngine{}
+}
+
+func (c *CaptureEngine) CaptureForeground() (*AppState, error) {
+	return nil, errors.New("foreground capture is not supported on this platform")
+}
@@ -3,12 +3,9 @@
 package state
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
 	"errors"
 	"fmt"
 	"path/filepath"
-	"strings"
 	"time"
 	"unsafe"
 
@@ -44,7 +41,12 @@ func (c *CaptureEngine) CaptureForeground() (*AppState, error) {
 	wins := enumerateWindows(pid, hwnd)
 	files := enumerateOpenFilesBestEffort(pid)
 
-	clipHash := hashClipboardTextBestEffort()
+	clipText := readClipboardTextBestEffort()
+	clipHash := hashClipboardText(clipText)
+	var clipSource string
+	if clipText != "" {
+		clipSource = clipboardOwnerExe()
+	}
 
 	st := &AppState{
 		AppID:                 appID,
@@ -56,21 +58,14 @@ func (c *CaptureEngine) CaptureForeground() (*AppState, error) {
 		Windows:               wins,
 		OpenFiles:             files,
 		ClipboardHash:         clipHash,
+		ClipboardText:         clipText,
+		ClipboardSource:       clipSource,
 		InputState:            InputState{InputLanguage: getInputLanguageTag()},
 		Timestamp:             time.Now(),
 	}
 	return st, nil
 }
 
-func stableAppID(exePath string) string {
-	if exePath == "" {
-		return "unknown"
-	}
-	base := strings.ToLower(filepath.Base(exePath))
-	sum := sha256.Sum256([]byte(strings.ToLower(exePath)))
-	return fmt.Sprintf("%s:%s", base, hex.EncodeToString(sum[:8]))
-}
-
 type wmiProcCache struct {
 	last map[int]cachedProc
 }
@@ -143,6 +138,7 @@ var (
 	procOpenClipboard              = u32.NewProc("OpenClipboard")
 	procCloseClipboard             = u32.NewProc("CloseClipboard")
 	procGetClipboardData           = u32.NewProc("GetClipboardData")
+	procGetClipboardOwner          = u32.NewProc("GetClipboardOwner")
 	procGlobalLock                 = k32.NewProc("GlobalLock")
 	procGlobalUnlock               = k32.NewProc("GlobalUnlock")
 	procQueryFullProcessImageNameW = k32.NewProc("QueryFullProcessImageNameW")
@@ -299,7 +295,21 @@ func getInputLanguageTag() string {
 	return fmt.Sprintf("0x%04x", langID)
 }
 
-func hashClipboardTextBestEffort() string {
+// clipboardOwnerExe returns the executable of the app that put the current
+// clipboard content there, if it is known.
+func clipboardOwnerExe() string {
+	hwnd, _, _ := procGetClipboardOwner.Call()
+	if hwnd == 0 {
+		return ""
+	}
+	pid := int(getWindowPID(hwnd))
+	if pid <= 0 {
+		return ""
+	}
+	return queryFullProcessImageName(pid)
+}
+
+func readClipboardTextBestEffort() string {
 	r1, _, _ := procOpenClipboard.Call(0)
 	if r1 == 0 {
 		return ""
@@ -316,7 +326,7 @@ func hashClipboardTextBestEffort() string {
 	defer procGlobalUnlock.Call(h)
 	u16 := (*[1 << 20]uint16)(unsafe.Pointer(ptr)) // ~1M
 	var b []uint16
-	for i := 0; i < len(u16) && i < 8192; i++ {
+	for i := 0; i < len(u16) && i < 32768; i++ {
 		if u16[i] == 0 {
 			break
 		}
@@ -325,7 +335,5 @@ func hashClipboardTextBestEffort() string {
 	if len(b) == 0 {
 		return ""
 	}
-	s := windows.UTF16ToString(b)
-	sum := sha256.Sum256([]byte(s))
-	return hex.EncodeToString(sum[:16])
+	return windows.UTF16ToString(b)
 }
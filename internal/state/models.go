@@ -35,14 +35,25 @@ type AppState struct {
 	PID            int    `json:"pid"`
 	ExecutablePath string `json:"executablePath"`
 	CommandLine    string `json:"commandLine,omitempty"`
+	// RawCommandLine is CommandLine before redaction, set only when redaction
+	// changed it. It is never serialized; the snapshot engine keeps it in its
+	// vault under LaunchRef so a restore can relaunch with the real arguments.
+	RawCommandLine string `json:"-"`
+	LaunchRef      string `json:"launchRef,omitempty"`
 	WorkingDir     string `json:"workingDir,omitempty"`
-
-	ForegroundWindowClass string        `json:"foregroundWindowClass,omitempty"`
-	Windows               []WindowState `json:"windows"`
-	OpenFiles             []FileRef     `json:"openFiles"`
-	ClipboardHash         string        `json:"clipboardHash,omitempty"`
+	// Environment holds the desktop session variables a relaunch needs, not
+	// the whole environment. Only filled on Linux.
+	Environment map[string]string `json:"environment,omitempty"`
+
+	ForegroundWindowClass string         `json:"foregroundWindowClass,omitempty"`
+	Windows               []WindowState  `json:"windows"`
+	OpenFiles             []FileRef      `json:"openFiles"`
+	ClipboardHash         string         `json:"clipboardHash,omitempty"`
+	ClipboardText         string         `json:"-"`
+	ClipboardSource       string         `json:"clipboardSource,omitempty"` // exe of the app that owns the clipboard
+	Redactions            map[string]int `json:"-"`                         // replacements made by redaction, per field
+	Settled               bool           `json:"-"`                         // last capture of a burst of moves
 	PluginData            map[string]any `json:"pluginData,omitempty"`
-	InputState            InputState    `json:"inputState"`
-	Timestamp             time.Time     `json:"timestamp"`
+	InputState            InputState     `json:"inputState"`
+	Timestamp             time.Time      `json:"timestamp"`
 }
-
@@ -0,0 +1,17 @@
+package state
+
+import "errors"
+
+// ErrNoForegroundWindow is returned by a WindowProvider that sees no focused
+// window, e.g. an X11 provider while a native Wayland window has the focus.
+var ErrNoForegroundWindow = errors.New("no foreground window")
+
+// WindowProvider fills in the window side of a capture where the OS has no
+// single window API to ask, e.g. an X11 or Wayland desktop on Linux. Without
+// one only the process and its files are captured.
+type WindowProvider interface {
+	// Foreground returns the focused window and the PID that owns it.
+	Foreground() (pid int, win WindowState, err error)
+	// Windows lists the top-level windows of pid.
+	Windows(pid int) ([]WindowState, error)
+}
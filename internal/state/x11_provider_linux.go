@@ -0,0 +1,288 @@
+package state
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// x11ActiveTTL is how long the active window read by Foreground is reused,
+// so the Windows call of the same capture does not ask again.
+const x11ActiveTTL = time.Second
+
+// x11Provider asks the X server through xprop and xwininfo, which every X
+// desktop ships, instead of linking an X client library. Under Wayland it
+// only sees XWayland windows; a native one in the foreground shows up as
+// ErrNoForegroundWindow.
+//
+// Every query is a process, so the owner PID of each window is remembered:
+// it never changes, and Windows fetches the other properties only for the
+// windows of the PID asked for.
+type x11Provider struct {
+	run func(name string, args ...string) ([]byte, error)
+	now func() time.Time // nil means time.Now
+
+	mu       sync.Mutex
+	pids     map[uintptr]int
+	active   uintptr
+	activeAt time.Time
+}
+
+// newX11Provider returns a provider for the session's display, or false when
+// there is no display or the tools are missing.
+func newX11Provider() (*x11Provider, bool) {
+	if os.Getenv("DISPLAY") == "" {
+		return nil, false
+	}
+	for _, tool := range []string{"xprop", "xwininfo"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			return nil, false
+		}
+	}
+	return &x11Provider{run: runTool}, true
+}
+
+func runTool(name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return exec.CommandContext(ctx, name, args...).Output()
+}
+
+func (p *x11Provider) Foreground() (int, WindowState, error) {
+	id, err := p.activeWindow()
+	if err != nil {
+		return 0, WindowState{}, err
+	}
+	p.mu.Lock()
+	p.active, p.activeAt = id, p.clock()
+	p.mu.Unlock()
+	if id == 0 {
+		return 0, WindowState{}, ErrNoForegroundWindow
+	}
+	pid, w, err := p.window(id)
+	if err != nil {
+		return 0, WindowState{}, err
+	}
+	w.IsForeground = true
+	return pid, w, nil
+}
+
+func (p *x11Provider) Windows(pid int) ([]WindowState, error) {
+	out, err := p.run("xprop", "-root", "_NET_CLIENT_LIST_STACKING")
+	if err != nil {
+		return nil, err
+	}
+	ids := parseWindowIDs(propValue(string(out), "_NET_CLIENT_LIST_STACKING"))
+	p.forgetClosed(ids)
+	p.mu.Lock()
+	active, fresh := p.active, p.clock().Sub(p.activeAt) < x11ActiveTTL
+	p.mu.Unlock()
+	if !fresh {
+		active, _ = p.activeWindow()
+	}
+	var res []WindowState
+	// The stacking list runs bottom to top.
+	for i, id := range ids {
+		if p.pidOf(id) != pid {
+			continue
+		}
+		wpid, w, err := p.window(id)
+		if err != nil || wpid != pid {
+			continue
+		}
+		w.ZOrder = len(ids) - 1 - i
+		w.IsForeground = id == active
+		res = append(res, w)
+	}
+	// Как и в Windows-версии: сверху вниз
+	for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+		res[i], res[j] = res[j], res[i]
+	}
+	return res, nil
+}
+
+func (p *x11Provider) clock() time.Time {
+	if p.now != nil {
+		return p.now()
+	}
+	return time.Now()
+}
+
+// pidOf returns the owner PID of window id, asking xprop only the first
+// time. 0 means it could not be read.
+func (p *x11Provider) pidOf(id uintptr) int {
+	p.mu.Lock()
+	pid, ok := p.pids[id]
+	p.mu.Unlock()
+	if ok {
+		return pid
+	}
+	out, err := p.run("xprop", "-id", windowHex(id), "_NET_WM_PID")
+	if err != nil {
+		return 0
+	}
+	pid, _ = strconv.Atoi(propValue(string(out), "_NET_WM_PID"))
+	p.rememberPID(id, pid)
+	return pid
+}
+
+func (p *x11Provider) rememberPID(id uintptr, pid int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pids == nil {
+		p.pids = map[uintptr]int{}
+	}
+	p.pids[id] = pid
+}
+
+// forgetClosed drops the PIDs of windows that are no longer listed; X
+// reuses window IDs.
+func (p *x11Provider) forgetClosed(ids []uintptr) {
+	listed := make(map[uintptr]bool, len(ids))
+	for _, id := range ids {
+		listed[id] = true
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id := range p.pids {
+		if !listed[id] {
+			delete(p.pids, id)
+		}
+	}
+}
+
+func windowHex(id uintptr) string {
+	return "0x" + strconv.FormatUint(uint64(id), 16)
+}
+
+func (p *x11Provider) activeWindow() (uintptr, error) {
+	out, err := p.run("xprop", "-root", "_NET_ACTIVE_WINDOW")
+	if err != nil {
+		return 0, err
+	}
+	ids := parseWindowIDs(propValue(string(out), "_NET_ACTIVE_WINDOW"))
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	return ids[0], nil
+}
+
+// window reads the owner PID and the state of window id.
+func (p *x11Provider) window(id uintptr) (int, WindowState, error) {
+	hex := windowHex(id)
+	out, err := p.run("xprop", "-id", hex,
+		"_NET_WM_PID", "WM_CLASS", "_NET_WM_NAME", "WM_NAME", "_NET_WM_STATE", "_NET_WM_DESKTOP")
+	if err != nil {
+		return 0, WindowState{}, err
+	}
+	props := string(out)
+	w := WindowState{HWND: id}
+	pid, _ := strconv.Atoi(propValue(props, "_NET_WM_PID"))
+	if pid > 0 {
+		p.rememberPID(id, pid)
+	}
+	// WM_CLASS is "instance", "class"; the class is what Windows reports.
+	if cls := splitQuoted(propValue(props, "WM_CLASS")); len(cls) > 0 {
+		w.ClassName = cls[len(cls)-1]
+	}
+	if t := splitQuoted(propValue(props, "_NET_WM_NAME")); len(t) > 0 {
+		w.Title = t[0]
+	} else if t := splitQuoted(propValue(props, "WM_NAME")); len(t) > 0 {
+		w.Title = t[0]
+	}
+	st := propValue(props, "_NET_WM_STATE")
+	w.IsMinimized = strings.Contains(st, "_NET_WM_STATE_HIDDEN")
+	w.IsMaximized = strings.Contains(st, "_NET_WM_STATE_MAXIMIZED_VERT") &&
+		strings.Contains(st, "_NET_WM_STATE_MAXIMIZED_HORZ")
+	if d, err := strconv.Atoi(propValue(props, "_NET_WM_DESKTOP")); err == nil {
+		w.VirtualDesktop = strconv.Itoa(d)
+	}
+
+	if geo, err := p.run("xwininfo", "-id", hex); err == nil {
+		var x, y, width, height int
+		for _, line := range strings.Split(string(geo), "\n") {
+			k, v, ok := strings.Cut(strings.TrimSpace(line), ":")
+			if !ok {
+				continue
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				continue
+			}
+			switch k {
+			case "Absolute upper-left X":
+				x = n
+			case "Absolute upper-left Y":
+				y = n
+			case "Width":
+				width = n
+			case "Height":
+				height = n
+			}
+		}
+		w.Rect = Rect{Left: int32(x), Top: int32(y), Right: int32(x + width), Bottom: int32(y + height)}
+	}
+	return pid, w, nil
+}
+
+// propValue returns what xprop prints after "=" for name, e.g. "123" for
+// "_NET_WM_PID(CARDINAL) = 123". Unset properties give "".
+func propValue(out, name string) string {
+	for _, line := range strings.Split(out, "\n") {
+		if !strings.HasPrefix(line, name+"(") && !strings.HasPrefix(line, name+":") {
+			continue
+		}
+		if _, v, ok := strings.Cut(line, "="); ok {
+			return strings.TrimSpace(v)
+		}
+		// _NET_ACTIVE_WINDOW(WINDOW): window id # 0x3a00007
+		if _, v, ok := strings.Cut(line, "#"); ok {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+// parseWindowIDs parses a list like "0x1a00003, 0x2c00007". Zero ids are
+// dropped.
+func parseWindowIDs(v string) []uintptr {
+	var ids []uintptr
+	for _, f := range strings.Split(v, ",") {
+		f = strings.TrimSpace(f)
+		n, err := strconv.ParseUint(strings.TrimPrefix(f, "0x"), 16, 64)
+		if err != nil || n == 0 {
+			continue
+		}
+		ids = append(ids, uintptr(n))
+	}
+	return ids
+}
+
+// splitQuoted splits xprop's `"a", "b"` string lists, undoing its escapes.
+func splitQuoted(v string) []string {
+	var res []string
+	for {
+		i := strings.IndexByte(v, '"')
+		if i < 0 {
+			return res
+		}
+		v = v[i+1:]
+		var sb strings.Builder
+		j := 0
+		for ; j < len(v) && v[j] != '"'; j++ {
+			if v[j] == '\\' && j+1 < len(v) {
+				j++
+			}
+			sb.WriteByte(v[j])
+		}
+		res = append(res, sb.String())
+		if j >= len(v) {
+			return res
+		}
+		v = v[j+1:]
+	}
+}
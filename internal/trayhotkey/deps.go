@@ -0,0 +1,16 @@
+package trayhotkey
+
+import "time"
+
+type Dependencies struct {
+	OnOpenTimeline   func()
+	OnPauseTracking  func()
+	OnResumeTracking func()
+	OnOpenSettings   func()
+	OnExit           func()
+	OnOverlayHotkey  func()
+
+	OnPauseTrackingFor   func(d time.Duration)
+	OnPauseUntilTomorrow func()
+	OnSelectProfile      func(name string) // "" selects the base settings
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package trayhotkey
+
+// Manager has no tray or global hotkey outside Windows.
+type Manager struct {
+	deps Dependencies
+}
+
+func NewManager(deps Dependencies) *Manager {
+	return &Manager{deps: deps}
+}
+
+func (m *Manager) Start() {}
+
+func (m *Manager) Stop() {}
+
+func (m *Manager) SetProfiles(names []string, active string) {}
@@ -14,19 +14,19 @@ import (
 
 var trayIcon []byte
 
-type Dependencies struct {
-	OnOpenTimeline   func()
-	OnPauseTracking  func()
-	OnResumeTracking func()
-	OnOpenSettings   func()
-	OnExit           func()
-	OnOverlayHotkey  func()
-}
-
 type Manager struct {
 	deps Dependencies
 	once sync.Once
 	stop chan struct{}
+
+	// Profile submenu. Items are reused and hidden when profiles go away,
+	// systray cannot remove them.
+	profMu      sync.Mutex
+	menuProfile *systray.MenuItem
+	profItems   []*systray.MenuItem
+	profNames   []string // shown by profItems, starting with ""
+	profList    []string
+	profActive  string
 }
 
 func (m *Manager) setTrayIcon() {
@@ -88,8 +88,16 @@ func (m *Manager) onReady() {
 	m.setTrayIcon()
 
 	itemOpen := systray.AddMenuItem("Open Timeline", "Open timeline window")
-	itemPause := systray.AddMenuItem("Pause Tracking", "Pause tracking")
+	menuPause := systray.AddMenuItem("Pause Tracking", "Pause tracking")
+	itemPause15 := menuPause.AddSubMenuItem("For 15 minutes", "Pause tracking for 15 minutes")
+	itemPause60 := menuPause.AddSubMenuItem("For 1 hour", "Pause tracking for 1 hour")
+	itemPauseTomorrow := menuPause.AddSubMenuItem("Until tomorrow", "Pause tracking until midnight")
+	itemPause := menuPause.AddSubMenuItem("Until resumed", "Pause tracking until resumed")
 	itemResume := systray.AddMenuItem("Resume Tracking", "Resume tracking")
+	m.profMu.Lock()
+	m.menuProfile = systray.AddMenuItem("Profile", "Switch configuration profile")
+	m.renderProfilesLocked()
+	m.profMu.Unlock()
 	itemSettings := systray.AddMenuItem("Settings", "Open settings")
 	systray.AddSeparator()
 	itemExit := systray.AddMenuItem("Exit", "Exit")
@@ -103,6 +111,18 @@ func (m *Manager) onReady() {
 				if m.deps.OnOpenTimeline != nil {
 					m.deps.OnOpenTimeline()
 				}
+			case <-itemPause15.ClickedCh:
+				if m.deps.OnPauseTrackingFor != nil {
+					m.deps.OnPauseTrackingFor(15 * time.Minute)
+				}
+			case <-itemPause60.ClickedCh:
+				if m.deps.OnPauseTrackingFor != nil {
+					m.deps.OnPauseTrackingFor(time.Hour)
+				}
+			case <-itemPauseTomorrow.ClickedCh:
+				if m.deps.OnPauseUntilTomorrow != nil {
+					m.deps.OnPauseUntilTomorrow()
+				}
 			case <-itemPause.ClickedCh:
 				if m.deps.OnPauseTracking != nil {
 					m.deps.OnPauseTracking()
@@ -128,6 +148,71 @@ func (m *Manager) onReady() {
 
 func (m *Manager) onExit() {}
 
+// SetProfiles updates the profile submenu. It may be called before the tray
+// is ready.
+func (m *Manager) SetProfiles(names []string, active string) {
+	m.profMu.Lock()
+	defer m.profMu.Unlock()
+	m.profList = append([]string(nil), names...)
+	m.profActive = active
+	if m.menuProfile != nil {
+		m.renderProfilesLocked()
+	}
+}
+
+func (m *Manager) renderProfilesLocked() {
+	names := append([]string{""}, m.profList...)
+	for len(m.profItems) < len(names) {
+		i := len(m.profItems)
+		item := m.menuProfile.AddSubMenuItemCheckbox("", "", false)
+		m.profItems = append(m.profItems, item)
+		go m.profileClicks(item, i)
+	}
+	m.profNames = names
+	for i, item := range m.profItems {
+		if i >= len(names) {
+			item.Hide()
+			continue
+		}
+		title := names[i]
+		if title == "" {
+			title = "Default"
+		}
+		item.SetTitle(title)
+		item.SetTooltip("Use the " + title + " profile")
+		if names[i] == m.profActive {
+			item.Check()
+		} else {
+			item.Uncheck()
+		}
+		item.Show()
+	}
+	if len(m.profList) == 0 {
+		m.menuProfile.Hide()
+	} else {
+		m.menuProfile.Show()
+	}
+}
+
+func (m *Manager) profileClicks(item *systray.MenuItem, i int) {
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-item.ClickedCh:
+			m.profMu.Lock()
+			name, ok := "", i < len(m.profNames)
+			if ok {
+				name = m.profNames[i]
+			}
+			m.profMu.Unlock()
+			if ok && m.deps.OnSelectProfile != nil {
+				m.deps.OnSelectProfile(name)
+			}
+		}
+	}
+}
+
 func (m *Manager) hotkeyLoop() {
 	const (
 		MOD_ALT     = 0x0001
@@ -3,6 +3,10 @@ package main
 import (
 	"context"
 	"embed"
+	"flag"
+	"os"
+
+	"Rewinder/internal/policy"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
@@ -13,6 +17,7 @@ import (
 var assets embed.FS
 
 func main() {
+	applyFlags()
 	app := NewApp()
 
 	err := wails.Run(&options.App{
@@ -39,3 +44,21 @@ func main() {
 		println("Error:", err.Error())
 	}
 }
+
+// applyFlags turns the location flags into their environment variables, which
+// the config and storage code read.
+func applyFlags() {
+	fs := flag.NewFlagSet("rewinder", flag.ContinueOnError)
+	home := fs.String("home", "", "directory for config.json and snapshots (env "+policy.EnvHome+")")
+	config := fs.String("config", "", "config file path (env "+policy.EnvConfigPath+")")
+	lock := fs.String("lock", "", `when another instance owns the store: "readonly" or "fail" (env `+policy.EnvLockMode+")")
+	record := fs.String("record", "", "record the session to this file for replay (env "+policy.EnvRecord+")")
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		println("Error:", err.Error())
+	}
+	for env, v := range map[string]string{policy.EnvHome: *home, policy.EnvConfigPath: *config, policy.EnvLockMode: *lock, policy.EnvRecord: *record} {
+		if v != "" {
+			_ = os.Setenv(env, v)
+		}
+	}
+}